@@ -0,0 +1,222 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"stockexchange/store"
+)
+
+// API key authentication
+//
+// Every authenticated request carries the following headers
+//
+//	X-API-Key:   the api key
+//	X-Timestamp: the unix time in seconds the request was signed at
+//	X-Signature: hex encoded HMAC-SHA256 of the request, keyed with the api key's secret
+//
+// The signed payload is the timestamp, method, request URI (path and query) and body, separated by new lines
+//
+//	1622539800\nPOST\n/v1/users/user1/orders\n{"asset_id":"COIN","buy_or_sell":"BUY","size":10,"limit":100}
+//
+// Requests signed more than maxSignatureAge away from the server's time are rejected to limit replays.
+
+const (
+	apiKeyHeader    = "X-API-Key"
+	timestampHeader = "X-Timestamp"
+	signatureHeader = "X-Signature"
+)
+
+// maxSignatureAge is the max difference between the time a request was signed at and the server's time
+const maxSignatureAge = 5 * time.Minute
+
+// maxSignedBodySize is the max size of a request body read to verify its signature
+const maxSignedBodySize = 1 << 20
+
+type Role string
+
+const (
+	TraderRole Role = "TRADER" // can only act on the orders of the key's user
+	AdminRole  Role = "ADMIN"  // can manage the exchange and act on every user's orders
+)
+
+// APIKey represents an api key, the secret used to sign requests with it and who it authenticates
+type APIKey struct {
+	Key    string       `json:"key"`
+	Secret string       `json:"secret"`
+	UserId store.UserId `json:"user_id"` // user the key acts as
+	Role   Role         `json:"role"`    // TRADER by default
+}
+
+// Principal represents who an authenticated request acts as
+type Principal struct {
+	UserId store.UserId
+	Role   Role
+}
+
+type contextKey string
+
+const principalContextKey contextKey = "principal"
+
+// Authenticator authenticates requests signed with api keys and authorizes them by role and user
+type Authenticator struct {
+	keys map[string]APIKey // key -> api key
+	now  func() time.Time
+}
+
+// NewAuthenticator returns an authenticator for the given api keys.
+// Authentication is disabled, and every request allowed, if there are no keys.
+func NewAuthenticator(keys []APIKey) *Authenticator {
+	a := &Authenticator{
+		keys: make(map[string]APIKey),
+		now:  time.Now,
+	}
+	for _, key := range keys {
+		if key.Role == "" {
+			key.Role = TraderRole
+		}
+		a.keys[key.Key] = key
+	}
+	return a
+}
+
+// LoadAPIKeys reads api keys from a JSON file
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid api keys file %s: %v", path, err)
+	}
+	for _, key := range keys {
+		if key.Key == "" || key.Secret == "" {
+			return nil, fmt.Errorf("api keys file %s: every key needs a key and a secret", path)
+		}
+		if key.Role != "" && key.Role != TraderRole && key.Role != AdminRole {
+			return nil, fmt.Errorf("api keys file %s: unknown role %s", path, key.Role)
+		}
+		if key.Role != AdminRole && key.UserId == "" {
+			return nil, fmt.Errorf("api keys file %s: key %s needs a user_id", path, key.Key)
+		}
+	}
+	return keys, nil
+}
+
+// Enabled returns if requests need to be authenticated
+func (a *Authenticator) Enabled() bool {
+	return len(a.keys) > 0
+}
+
+// Authenticate is a middleware that verifies the signature of a request and adds its Principal to the request context
+func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.verify(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey, principal)))
+	})
+}
+
+// RequireUser is a middleware that only allows requests acting on the authenticated user's own resources.
+// The user is taken from the userId path variable. Admins can act on every user.
+func (a *Authenticator) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal := getPrincipal(r)
+		userId := store.UserId(mux.Vars(r)["userId"])
+		if principal.Role != AdminRole && principal.UserId != userId {
+			http.Error(w, fmt.Sprintf("not allowed to act on user %s", userId), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdmin is a middleware that only allows requests authenticated with an admin key
+func (a *Authenticator) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if getPrincipal(r).Role != AdminRole {
+			http.Error(w, "admin role required", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verify checks the api key, timestamp and signature of a request and returns who it acts as
+func (a *Authenticator) verify(r *http.Request) (Principal, error) {
+	key, ok := a.keys[r.Header.Get(apiKeyHeader)]
+	if !ok {
+		return Principal{}, fmt.Errorf("missing or unknown %s", apiKeyHeader)
+	}
+
+	timestamp := r.Header.Get(timestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return Principal{}, fmt.Errorf("missing or invalid %s", timestampHeader)
+	}
+	age := a.now().Sub(time.Unix(seconds, 0))
+	if age > maxSignatureAge || age < -maxSignatureAge {
+		return Principal{}, fmt.Errorf("%s is too far from the server time", timestampHeader)
+	}
+
+	// read the body to sign it and put it back for the handler
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxSignedBodySize))
+	if err != nil {
+		return Principal{}, fmt.Errorf("unable to read request body: %v", err)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	signature, err := hex.DecodeString(r.Header.Get(signatureHeader))
+	if err != nil || !hmac.Equal(signature, signRequest(key.Secret, timestamp, r.Method, r.URL.RequestURI(), body)) {
+		return Principal{}, fmt.Errorf("invalid %s", signatureHeader)
+	}
+
+	return Principal{UserId: key.UserId, Role: key.Role}, nil
+}
+
+// signRequest returns the HMAC-SHA256 signature of a request
+func signRequest(secret, timestamp, method, requestURI string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + method + "\n" + requestURI + "\n"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// getPrincipal returns who an authenticated request acts as
+func getPrincipal(r *http.Request) Principal {
+	principal, _ := r.Context().Value(principalContextKey).(Principal)
+	return principal
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/engine"
+)
+
+var traderKey = APIKey{Key: "trader-key", Secret: "trader-secret", UserId: userId1}
+
+var adminKey = APIKey{Key: "admin-key", Secret: "admin-secret", Role: AdminRole}
+
+func TestAuthenticator(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	now := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	auth := NewAuthenticator([]APIKey{traderKey, adminKey})
+	auth.now = func() time.Time { return now }
+	router := NewRouter(s, auth)
+
+	orderBody := `{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 1, "limit": 100}`
+	usersBody := `[{"user_id": "userId3", "cash": 100}]`
+	depositBody := `{"type": "DEPOSIT", "amount": 1000000, "idempotency_key": "deposit-1"}`
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		statusCode int
+	}{
+		{"unsigned request", httptest.NewRequest("POST", "/users/userId1/orders", strings.NewReader(orderBody)), http.StatusUnauthorized},
+		{"own orders", signedRequest(traderKey, now, "POST", "/users/userId1/orders", orderBody), http.StatusOK},
+		{"own orders with query", signedRequest(traderKey, now, "GET", "/users/userId1/orders?status=complete", ""), http.StatusOK},
+		{"other user's orders", signedRequest(traderKey, now, "POST", "/users/userId2/orders", orderBody), http.StatusForbidden},
+		{"other user's cancel", signedRequest(traderKey, now, "DELETE", "/users/userId2/orders/1", ""), http.StatusForbidden},
+		{"admin acts on user", signedRequest(adminKey, now, "GET", "/users/userId2/orders", ""), http.StatusOK},
+		{"trader inits exchange", signedRequest(traderKey, now, "POST", "/users", usersBody), http.StatusForbidden},
+		{"admin inits exchange", signedRequest(adminKey, now, "POST", "/users", usersBody), http.StatusOK},
+		{"trader deposits to own account", signedRequest(traderKey, now, "POST", "/users/userId1/transfers", depositBody), http.StatusForbidden},
+		{"trader uses admin transfers", signedRequest(traderKey, now, "POST", "/admin/users/userId1/transfers", depositBody), http.StatusForbidden},
+		{"admin deposits", signedRequest(adminKey, now, "POST", "/admin/users/userId1/transfers", depositBody), http.StatusCreated},
+		{"trader changes phase", signedRequest(traderKey, now, "PUT", "/assets/COIN/phase", `{"phase": "HALTED"}`), http.StatusForbidden},
+		{"stale timestamp", signedRequest(traderKey, now.Add(-10*time.Minute), "GET", "/users/userId1/orders", ""), http.StatusUnauthorized},
+		{"unknown key", signedRequest(APIKey{Key: "unknown", Secret: "trader-secret"}, now, "GET", "/users/userId1/orders", ""), http.StatusUnauthorized},
+		{"wrong secret", signedRequest(APIKey{Key: "trader-key", Secret: "wrong"}, now, "GET", "/users/userId1/orders", ""), http.StatusUnauthorized},
+		{"public market data", httptest.NewRequest("GET", "/tickers", nil), http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, test.req)
+		assert.Equal(t, test.statusCode, w.Code, test.name)
+	}
+
+	// body is signed, so it can't be tampered with
+	req := signedRequest(traderKey, now, "POST", "/users/userId1/orders", orderBody)
+	req.Body = httptest.NewRequest("POST", "/", strings.NewReader(strings.Replace(orderBody, `"size": 1`, `"size": 2`, 1))).Body
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthenticator_Disabled(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/users", strings.NewReader(`[{"user_id": "userId1", "cash": 100}]`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/userId1/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func signedRequest(key APIKey, at time.Time, method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	req.Header.Set(apiKeyHeader, key.Key)
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(signatureHeader, hex.EncodeToString(signRequest(key.Secret, timestamp, method, target, []byte(body))))
+	return req
+}
@@ -0,0 +1,888 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"stockexchange/book"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+// defaultPageLimit and maxPageLimit are the default and max number of items in a page
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// defaultDepthLevels is the default number of price levels of each side of an order book's depth
+const defaultDepthLevels = 10
+
+type OrderResp struct {
+	OrderId       store.OrderId     `json:"order_id"`                  // id of val
+	ClientOrderId string            `json:"client_order_id,omitempty"` // id of val chosen by the user
+	UserId        store.UserId      `json:"user_id"`                   // id of user who owns the val
+	Limit         store.Usd         `json:"limit"`                     // Limit price, in Usd cents
+	AssetId       store.AssetId     `json:"asset_id"`                  // asset to trade
+	Size          int               `json:"size"`                      // number of assets
+	BuyOrSell     store.BuyOrSell   `json:"buy_or_sell"`               // BUY or SELL
+	OrderType     store.OrderType   `json:"order_type"`                // LIMIT
+	TimeInForce   store.TimeInForce `json:"time_in_force"`             // GTC, IOC or FOK
+	EventAt       time.Time         `json:"event_at"`                  // time when val was created
+	Status        store.OrderStatus `json:"status"`                    // Status of the val
+	Filled        int               `json:"filled"`                    // total number of assets filled during a trade
+	Seq           uint64            `json:"seq"`                       // sequence number of the command that created the val
+	UpdateSeq     uint64            `json:"update_seq"`                // sequence number of the last fill or cancel of the val
+}
+
+type UserResp struct {
+	UserId     store.UserId     `json:"user_id"`          // id of user
+	Status     store.UserStatus `json:"status"`           // ACTIVE or SUSPENDED
+	Cash       store.Usd        `json:"cash"`             // cash available to trade, in Usd cents
+	Assets     []store.Asset    `json:"assets"`           // assets available to trade, sorted by asset id
+	OpenOrders int              `json:"open_orders"`      // number of working orders
+	Orders     []OrderResp      `json:"orders,omitempty"` // every order of the user, oldest first
+}
+
+type TransferResp struct {
+	TransferId     store.TransferId   `json:"transfer_id"`        // id of transfer
+	UserId         store.UserId       `json:"user_id"`            // id of user who owns the transfer
+	Type           store.TransferType `json:"type"`               // DEPOSIT or WITHDRAWAL
+	AssetId        store.AssetId      `json:"asset_id,omitempty"` // asset transferred, empty for cash
+	Amount         int                `json:"amount"`             // cash in Usd cents or number of assets
+	IdempotencyKey string             `json:"idempotency_key"`    // unique key of the transfer
+	EventAt        time.Time          `json:"event_at"`           // time when transfer was made
+	Seq            uint64             `json:"seq"`                // sequence number of the transfer
+}
+
+type BalancesResp struct {
+	UserId       store.UserId       `json:"user_id"`       // id of user
+	Cash         store.Usd          `json:"cash"`          // cash available to trade and withdraw, in Usd cents
+	ReservedCash store.Usd          `json:"reserved_cash"` // cash reserved by working buy orders, in Usd cents
+	Assets       []AssetBalanceResp `json:"assets"`        // balance of every asset, sorted by asset id
+}
+
+type AssetBalanceResp struct {
+	AssetId   store.AssetId `json:"asset_id"`  // asset
+	Available int           `json:"available"` // number of assets available to trade and withdraw
+	Reserved  int           `json:"reserved"`  // number of assets reserved by working sell orders
+}
+
+type RiskRejectionResp struct {
+	Code   engine.RiskRejectCode `json:"code"`   // risk rule the order breaks
+	Reason string                `json:"reason"` // why the order breaks the rule
+}
+
+type OrderDetailResp struct {
+	OrderResp
+	Remaining    int        `json:"remaining"`        // number of assets left to fill, 0 if the order isn't working
+	AvgFillPrice float64    `json:"avg_fill_price"`   // average price of the fills, in Usd cents, 0 if there are none
+	Fills        []FillResp `json:"fills"`            // fills of the order, oldest first
+	Reason       string     `json:"reason,omitempty"` // why the order was canceled or rejected
+}
+
+type FillResp struct {
+	Price      store.Usd `json:"price"`       // price the assets traded at, in Usd cents
+	Size       int       `json:"size"`        // number of assets traded
+	ExecutedAt time.Time `json:"executed_at"` // time the trade was executed
+	Fee        store.Usd `json:"fee"`         // fee charged for the fill, in Usd cents
+}
+
+type TradeResp struct {
+	AssetId     store.AssetId `json:"asset_id"`      // asset traded
+	Price       store.Usd     `json:"price"`         // price the assets traded at, in Usd cents
+	Size        int           `json:"size"`          // number of assets traded
+	BuyOrderId  store.OrderId `json:"buy_order_id"`  // id of the buy order
+	SellOrderId store.OrderId `json:"sell_order_id"` // id of the sell order
+	BuyerId     store.UserId  `json:"buyer_id"`      // id of the buyer
+	SellerId    store.UserId  `json:"seller_id"`     // id of the seller
+	ExecutedAt  time.Time     `json:"executed_at"`   // time the trade was executed
+	Seq         uint64        `json:"seq"`           // sequence number of the trade
+}
+
+type UserTradeResp struct {
+	OrderId       store.OrderId   `json:"order_id"`                  // id of the user's order
+	ClientOrderId string          `json:"client_order_id,omitempty"` // id of the order chosen by the user
+	AssetId       store.AssetId   `json:"asset_id"`                  // asset traded
+	BuyOrSell     store.BuyOrSell `json:"buy_or_sell"`               // side of the user's order, BUY or SELL
+	Price         store.Usd       `json:"price"`                     // price the assets traded at, in Usd cents
+	Size          int             `json:"size"`                      // number of assets traded
+	Fee           store.Usd       `json:"fee"`                       // fee charged for the fill, in Usd cents
+	ExecutedAt    time.Time       `json:"executed_at"`               // time the trade was executed
+}
+
+type DepthResp struct {
+	AssetId store.AssetId     `json:"asset_id"` // asset of the order book
+	Phase   book.TradingPhase `json:"phase"`    // current trading phase of the order book
+	Bids    []PriceLevelResp  `json:"bids"`     // price levels of buy orders, highest price first
+	Asks    []PriceLevelResp  `json:"asks"`     // price levels of sell orders, lowest price first
+}
+
+type PriceLevelResp struct {
+	Price  store.Usd `json:"price"`  // limit of the orders, in Usd cents
+	Size   int       `json:"size"`   // total number of assets of the orders
+	Orders int       `json:"orders"` // number of orders
+}
+
+type AuctionResp struct {
+	AssetId   store.AssetId     `json:"asset_id"`  // asset in auction
+	Phase     book.TradingPhase `json:"phase"`     // trading phase of the asset's order book
+	Price     store.Usd         `json:"price"`     // indicative or equilibrium price, in Usd cents
+	Volume    int               `json:"volume"`    // number of assets executable at price
+	Imbalance int               `json:"imbalance"` // unmatched buy(+) or sell(-) volume at price
+}
+
+type PhaseReq struct {
+	Phase book.TradingPhase `json:"phase"` // trading phase to move to
+}
+
+type PhaseResp struct {
+	AssetId store.AssetId     `json:"asset_id"`          // asset of the order book
+	Phase   book.TradingPhase `json:"phase"`             // current trading phase of the order book
+	Auction *AuctionResp      `json:"auction,omitempty"` // result of the uncross if the phase change ended an auction
+}
+
+type AssetStatusResp struct {
+	AssetId  store.AssetId     `json:"asset_id"`            // asset of the order book
+	Phase    book.TradingPhase `json:"phase"`               // current trading phase of the order book
+	RefPrice store.Usd         `json:"reference_price"`     // reference price of the price band, in Usd cents
+	BandLow  store.Usd         `json:"band_low"`            // lowest price the asset can trade at, 0 if unbounded
+	BandHigh store.Usd         `json:"band_high"`           // highest price the asset can trade at, 0 if unbounded
+	HaltedAt *time.Time        `json:"halted_at,omitempty"` // time the asset was halted by the circuit breaker
+	ResumeAt *time.Time        `json:"resume_at,omitempty"` // time the resumption auction starts
+	Events   []MarketEventResp `json:"events"`              // halt and resume events of the asset, oldest first
+}
+
+type MarketEventResp struct {
+	Type     engine.MarketEventType `json:"type"`            // HALT or RESUME
+	AssetId  store.AssetId          `json:"asset_id"`        // asset of the event
+	Phase    book.TradingPhase      `json:"phase"`           // trading phase after the event
+	RefPrice store.Usd              `json:"reference_price"` // reference price after the event, in Usd cents
+	EventAt  time.Time              `json:"event_at"`        // time of the event
+	Seq      uint64                 `json:"seq"`             // sequence number of the event
+}
+
+type CandleResp struct {
+	Start  time.Time `json:"start"`  // start of the candle period
+	Open   store.Usd `json:"open"`   // price of the first trade, in Usd cents
+	High   store.Usd `json:"high"`   // highest trade price, in Usd cents
+	Low    store.Usd `json:"low"`    // lowest trade price, in Usd cents
+	Close  store.Usd `json:"close"`  // price of the last trade, in Usd cents
+	Volume int       `json:"volume"` // number of assets traded
+	VWAP   store.Usd `json:"vwap"`   // volume weighted average price, in Usd cents
+	Trades int       `json:"trades"` // number of trades
+}
+
+type TickerResp struct {
+	AssetId          store.AssetId `json:"asset_id"`                // asset of the ticker
+	LastPrice        store.Usd     `json:"last_price"`              // price of the last trade, in Usd cents
+	LastTradeAt      *time.Time    `json:"last_trade_at,omitempty"` // time of the last trade
+	BestBid          store.Usd     `json:"best_bid"`                // limit price of the top buy order, 0 if there is none
+	BestBidSize      int           `json:"best_bid_size"`           // size of the top buy order
+	BestAsk          store.Usd     `json:"best_ask"`                // limit price of the top sell order, 0 if there is none
+	BestAskSize      int           `json:"best_ask_size"`           // size of the top sell order
+	High24h          store.Usd     `json:"high_24h"`                // highest trade price in the last 24h, in Usd cents
+	Low24h           store.Usd     `json:"low_24h"`                 // lowest trade price in the last 24h, in Usd cents
+	Volume24h        int           `json:"volume_24h"`              // number of assets traded in the last 24h
+	Change24h        store.Usd     `json:"change_24h"`              // price change over the last 24h, in Usd cents
+	ChangePercent24h float64       `json:"change_percent_24h"`      // price change over the last 24h, in percent
+	Trades24h        int           `json:"trades_24h"`              // number of trades in the last 24h
+}
+
+// InitExchangeHandler handles requests to initialize the stock exchange with users and their assets
+func (s *Server) InitExchangeHandler(w http.ResponseWriter, r *http.Request) {
+	var req []store.InitExchangeReq
+	err := s.decodeJSON(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.Logger.Warn("invalid init exchange request", "request_id", getRequestId(r.Context()), "error", err)
+		return
+	}
+	for i, user := range req {
+		if err := engine.ValidateInitExchangeReq(user); err != nil {
+			http.Error(w, fmt.Sprintf("users[%d]: %s", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.InitExchange(req)
+	s.writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// CreateUserHandler handles requests to create a user with their cash and assets.
+// Creating an existing user returns the user unchanged.
+func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req store.InitExchangeReq
+	err := s.decodeJSON(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := engine.ValidateInitExchangeReq(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userData, created := s.CreateUser(req)
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	s.writeJSON(w, code, userDataToUserResp(userData, s.Store.CountOpenOrders(userData.UserId), false))
+}
+
+// GetUsersHandler handles requests to list all users
+func (s *Server) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	resp := []UserResp{}
+	for _, userId := range s.Store.GetUserIds() {
+		resp = append(resp, userDataToUserResp(s.Store.GetUserData(userId), s.Store.CountOpenOrders(userId), false))
+	}
+
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetUserHandler handles requests to get a user's cash, assets and orders
+func (s *Server) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	if !s.Store.HasUser(userId) {
+		http.Error(w, fmt.Sprintf("userId: %s not an actual user", userId), http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, userDataToUserResp(s.Store.GetUserData(userId), s.Store.CountOpenOrders(userId), true))
+}
+
+// SuspendUserHandler handles requests to suspend a user's trading and cancel their open orders
+func (s *Server) SuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	if err := s.SuspendUser(userId); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, userDataToUserResp(s.Store.GetUserData(userId), s.Store.CountOpenOrders(userId), false))
+}
+
+// ResumeUserHandler handles requests to resume a suspended user's trading
+func (s *Server) ResumeUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	if err := s.ResumeUser(userId); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, userDataToUserResp(s.Store.GetUserData(userId), s.Store.CountOpenOrders(userId), false))
+}
+
+// DeleteUserHandler handles requests to delete a user without cash, assets and open orders
+func (s *Server) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	if !s.Store.HasUser(userId) {
+		http.Error(w, fmt.Sprintf("userId: %s not an actual user", userId), http.StatusNotFound)
+		return
+	}
+	if err := s.DeleteUser(userId); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateOrderHandler handles request to process buy and sell orders
+func (s *Server) CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var or engine.OrderReq
+	err := s.decodeJSON(r, &or)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	or.UserId = store.UserId(mux.Vars(r)["userId"])
+	or.RequestId = getRequestId(r.Context())
+	s.placeOrder(w, or)
+}
+
+// placeOrder places an order and replies with the accepted order
+func (s *Server) placeOrder(w http.ResponseWriter, or engine.OrderReq) {
+	placed, duplicate, err := s.PlaceOrder(or)
+	if err != nil {
+		orderRejectionResponse(w, err)
+		return
+	}
+
+	// a retry of an open order gets the open order
+	if order, ok := s.Store.GetOrder(placed.UserId, placed.OrderId); ok && duplicate {
+		s.writeJSON(w, http.StatusOK, orderToOrderResp(order))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, orderReqToOrderResp(placed)) // order is still queued
+}
+
+// orderRejectionResponse replies to an order request rejected by the exchange
+func orderRejectionResponse(w http.ResponseWriter, err error) {
+	var riskRejection *engine.RiskRejection
+	if errors.As(err, &riskRejection) {
+		JSONResponse(w, http.StatusUnprocessableEntity, RiskRejectionResp{Code: riskRejection.Code, Reason: riskRejection.Reason})
+		return
+	}
+
+	code := http.StatusBadRequest
+	var rejection *engine.OrderRejection
+	if errors.As(err, &rejection) {
+		switch rejection.Reason {
+		case engine.RejectSuspended:
+			code = http.StatusForbidden
+		case engine.RejectPhase, engine.RejectDuplicate:
+			code = http.StatusConflict
+		case engine.RejectMaxOpenOrders:
+			tooManyRequests(w, time.Second, err.Error())
+			return
+		case engine.RejectQueueFull:
+			w.Header().Set("Retry-After", "1")
+			code = http.StatusServiceUnavailable
+		case engine.RejectShuttingDown:
+			code = http.StatusServiceUnavailable
+		}
+	}
+	http.Error(w, err.Error(), code)
+}
+
+// CancelOrderHandler handles request to cancel order
+func (s *Server) CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+	orderId := mux.Vars(r)["orderId"]
+
+	s.CancelUserOrder(store.UserId(userId), store.OrderId(orderId))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AmendOrderHandler handles request to amend the limit or size of a user's working order, see AmendOrder
+func (s *Server) AmendOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req engine.AmendOrderReq
+	err := s.decodeJSON(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userId := store.UserId(mux.Vars(r)["userId"])
+	orderId := store.OrderId(mux.Vars(r)["orderId"])
+	req.RequestId = getRequestId(r.Context())
+	or, err := s.AmendOrder(userId, orderId, req)
+	switch {
+	case errors.Is(err, engine.ErrOrderNotFound):
+		http.Error(w, fmt.Sprintf("order %s not found", orderId), http.StatusNotFound)
+	case errors.Is(err, engine.ErrOrderNotWorking):
+		http.Error(w, fmt.Sprintf("order %s is not working anymore", orderId), http.StatusConflict)
+	case err != nil:
+		orderRejectionResponse(w, err)
+	default:
+		s.writeJSON(w, http.StatusOK, orderReqToOrderResp(or))
+	}
+}
+
+// GetOrderHandler handles request to get a user's order with its fills
+func (s *Server) GetOrderHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	orderId := store.OrderId(mux.Vars(r)["orderId"])
+
+	order, ok := s.Store.GetOrder(userId, orderId)
+	if !ok {
+		http.Error(w, fmt.Sprintf("order %s not found", orderId), http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, orderToOrderDetailResp(order, s.Store.GetFills(userId, orderId)))
+}
+
+// GetClientOrderHandler handles request to get a user's latest order with a client order id
+func (s *Server) GetClientOrderHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	clientOrderId := mux.Vars(r)["clientOrderId"]
+
+	or, _ := s.ClientOrders.Get(userId, clientOrderId)
+	order, ok := s.Store.GetOrder(userId, or.OrderId)
+	if !ok {
+		http.Error(w, fmt.Sprintf("order with client_order_id %s not found", clientOrderId), http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, orderToOrderDetailResp(order, s.Store.GetFills(userId, order.OrderId)))
+}
+
+// CancelClientOrderHandler handles request to cancel a user's order by client order id
+func (s *Server) CancelClientOrderHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	clientOrderId := mux.Vars(r)["clientOrderId"]
+
+	or, ok := s.ClientOrders.Get(userId, clientOrderId)
+	if !ok {
+		http.Error(w, fmt.Sprintf("order with client_order_id %s not found", clientOrderId), http.StatusNotFound)
+		return
+	}
+
+	s.CancelUserOrder(userId, or.OrderId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CancelOrdersHandler handles request to cancel all of a user's working orders, optionally only of an asset and side
+func (s *Server) CancelOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	filter, err := s.parseOrderFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, ordersToOrderResps(s.CancelUserOrders(userId, filter, "canceled by user")))
+}
+
+// CancelAssetOrdersHandler handles request to halt an asset and cancel every order in its order book, see CancelAssetOrders
+func (s *Server) CancelAssetOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	assetId := store.AssetId(mux.Vars(r)["assetId"])
+
+	s.writeJSON(w, http.StatusOK, ordersToOrderResps(s.CancelAssetOrders(assetId)))
+}
+
+// StreamHandler handles request to stream a user's trades as server-sent events.
+// With cancel_on_disconnect=true, the user's working orders, optionally only of an asset and side, are canceled
+// when the stream disconnects, e.g so a market maker's quotes are pulled when its connection drops.
+func (s *Server) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	userId := store.UserId(mux.Vars(r)["userId"])
+	filter, err := s.parseOrderFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cancelOnDisconnect := r.URL.Query().Get("cancel_on_disconnect") == "true"
+
+	trades := s.TradeStream.Subscribe()
+	defer s.TradeStream.Unsubscribe(trades)
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for err == nil {
+		select {
+		case <-r.Context().Done():
+			err = r.Context().Err()
+		case trade := <-trades:
+			if trade.BuyerId != userId && trade.SellerId != userId {
+				continue
+			}
+			data, _ := json.Marshal(tradeToTradeResp(trade))
+			_, err = fmt.Fprintf(w, "event: trade\ndata: %s\n\n", data)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+	}
+
+	if cancelOnDisconnect {
+		canceled := s.CancelUserOrders(userId, filter, "stream disconnected")
+		s.Logger.Info("stream disconnected, canceled orders", "request_id", getRequestId(r.Context()), "user_id", userId, "canceled", len(canceled))
+	}
+}
+
+// GetOrdersHandler handles request to get a page of a user's orders filtered by status, asset, side and time.
+// Orders are sorted in the order they were created, the cursor of the next page is returned in the X-Next-Cursor header.
+func (s *Server) GetOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	query := r.URL.Query()
+
+	filter, err := s.parseOrderFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// return active orders by default
+	filter.Status = query.Get("status")
+	if filter.Status == "" {
+		filter.Status = engine.ActiveOrders
+	}
+	if !engine.IsValidStatusFilter(filter.Status) {
+		http.Error(w, fmt.Sprintf("invalid status %s", filter.Status), http.StatusBadRequest)
+		return
+	}
+	if filter.From, err = parseTimeParam(query.Get("from"), time.Time{}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to"), time.Time{}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := parseLimitParam("limit", query.Get("limit"), defaultPageLimit, maxPageLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sortOrder := query.Get("sort")
+	if sortOrder != "" && sortOrder != "asc" && sortOrder != "desc" {
+		http.Error(w, fmt.Sprintf("invalid sort %s, must be asc or desc", sortOrder), http.StatusBadRequest)
+		return
+	}
+	desc := sortOrder == "desc"
+
+	orders, next, err := s.GetUserOrders(userId, filter, query.Get("cursor"), limit, desc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	s.writeJSON(w, http.StatusOK, ordersToOrderResps(orders))
+}
+
+// GetTradesHandler handles request to get the latest fills of a user's orders, optionally only of an asset and side
+func (s *Server) GetTradesHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	filter, err := s.parseOrderFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseLimitParam("limit", r.URL.Query().Get("limit"), defaultPageLimit, maxPageLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := []UserTradeResp{}
+	for _, trade := range s.GetUserTrades(userId, filter, limit) {
+		resp = append(resp, userTradeToUserTradeResp(trade))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetDepthHandler handles request to get the price levels of an asset's order book
+func (s *Server) GetDepthHandler(w http.ResponseWriter, r *http.Request) {
+	assetId, ok := s.getTradedAssetId(w, r)
+	if !ok {
+		return
+	}
+	levels, err := parseLimitParam("levels", r.URL.Query().Get("levels"), defaultDepthLevels, maxPageLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bids, asks := s.OrderBooks.GetDepth(assetId, levels)
+	s.writeJSON(w, http.StatusOK, depthToDepthResp(assetId, s.OrderBooks.GetPhase(assetId), bids, asks))
+}
+
+// CreateTransferHandler handles the requests of users to withdraw cash or assets.
+// Deposits credit the user's balance, so only admins can make them, see CreateAdminTransferHandler.
+func (s *Server) CreateTransferHandler(w http.ResponseWriter, r *http.Request) {
+	s.createTransfer(w, r, false)
+}
+
+// CreateAdminTransferHandler handles the requests of admins to deposit or withdraw cash or assets for a user
+func (s *Server) CreateAdminTransferHandler(w http.ResponseWriter, r *http.Request) {
+	s.createTransfer(w, r, true)
+}
+
+// createTransfer creates a deposit or withdrawal for the user of a request, deposits are rejected unless allowed
+func (s *Server) createTransfer(w http.ResponseWriter, r *http.Request, allowDeposits bool) {
+	var tr engine.TransferReq
+	err := s.decodeJSON(r, &tr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		tr.IdempotencyKey = key
+	}
+	if err := engine.ValidateTransferReq(tr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if tr.Type == store.Deposit && !allowDeposits {
+		http.Error(w, "deposits can only be made by admins", http.StatusForbidden)
+		return
+	}
+
+	userId := store.UserId(mux.Vars(r)["userId"])
+	if !s.Store.HasUser(userId) {
+		http.Error(w, fmt.Sprintf("userId: %s not an actual user", userId), http.StatusNotFound)
+		return
+	}
+
+	transfer, created, err := s.CreateTransfer(userId, tr)
+	if errors.Is(err, store.ErrIdempotencyKeyReused) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	s.writeJSON(w, code, transferToTransferResp(transfer))
+}
+
+// GetTransfersHandler handles requests to get a user's deposits and withdrawals
+func (s *Server) GetTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	resp := []TransferResp{}
+	for _, transfer := range s.Store.GetTransfers(userId) {
+		resp = append(resp, transferToTransferResp(transfer))
+	}
+
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetBalancesHandler handles requests to get a user's available and reserved cash and assets
+func (s *Server) GetBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	userId := store.UserId(mux.Vars(r)["userId"])
+	if !s.Store.HasUser(userId) {
+		http.Error(w, fmt.Sprintf("userId: %s not an actual user", userId), http.StatusNotFound)
+		return
+	}
+
+	reservedCash, reservedAssets := s.Store.GetReserved(userId)
+	s.writeJSON(w, http.StatusOK, balancesToBalancesResp(s.Store.GetUserAccount(userId), reservedCash, reservedAssets))
+}
+
+// StartAuctionHandler handles request to start the call period of an auction for an asset
+func (s *Server) StartAuctionHandler(w http.ResponseWriter, r *http.Request) {
+	assetId := store.AssetId(mux.Vars(r)["assetId"])
+	if _, err := s.SetAssetPhase(assetId, book.Auction); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// GetAuctionHandler handles request to get the indicative price and volume of an asset's auction
+func (s *Server) GetAuctionHandler(w http.ResponseWriter, r *http.Request) {
+	assetId, ok := s.getTradedAssetId(w, r)
+	if !ok {
+		return
+	}
+	phase := s.OrderBooks.GetPhase(assetId)
+	if phase != book.Auction {
+		http.Error(w, fmt.Sprintf("asset %s is not in auction", assetId), http.StatusConflict)
+		return
+	}
+
+	result, _ := s.GetIndicativeAuction(assetId)
+	s.writeJSON(w, http.StatusOK, auctionResultToAuctionResp(assetId, phase, result))
+}
+
+// UncrossAuctionHandler handles request to uncross an asset's auction and resume continuous trading
+func (s *Server) UncrossAuctionHandler(w http.ResponseWriter, r *http.Request) {
+	assetId := store.AssetId(mux.Vars(r)["assetId"])
+	if phase := s.OrderBooks.GetPhase(assetId); phase != book.Auction {
+		http.Error(w, fmt.Sprintf("asset %s is not in auction", assetId), http.StatusConflict)
+		return
+	}
+
+	transition, err := s.SetAssetPhase(assetId, book.Continuous)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	var result book.AuctionResult
+	if transition.Auction != nil {
+		result = *transition.Auction
+	}
+	s.writeJSON(w, http.StatusOK, auctionResultToAuctionResp(assetId, transition.To, result))
+}
+
+// GetPhaseHandler handles request to get the trading phase of an asset
+func (s *Server) GetPhaseHandler(w http.ResponseWriter, r *http.Request) {
+	assetId, ok := s.getTradedAssetId(w, r)
+	if !ok {
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, PhaseResp{AssetId: assetId, Phase: s.OrderBooks.GetPhase(assetId)})
+}
+
+// SetPhaseHandler handles request to move an asset to a new trading phase
+func (s *Server) SetPhaseHandler(w http.ResponseWriter, r *http.Request) {
+	var req PhaseReq
+	err := s.decodeJSON(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !book.IsValidPhase(req.Phase) {
+		http.Error(w, fmt.Sprintf("unknown phase %s", req.Phase), http.StatusBadRequest)
+		return
+	}
+
+	assetId := store.AssetId(mux.Vars(r)["assetId"])
+	transition, err := s.SetAssetPhase(assetId, req.Phase)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, phaseTransitionToPhaseResp(transition))
+}
+
+// GetAssetStatusHandler handles request to get the trading status and circuit breaker events of an asset
+func (s *Server) GetAssetStatusHandler(w http.ResponseWriter, r *http.Request) {
+	assetId, ok := s.getTradedAssetId(w, r)
+	if !ok {
+		return
+	}
+	status := s.OrderBooks.GetStatus(assetId)
+	events := s.MarketEvents.GetAssetEvents(assetId)
+
+	s.writeJSON(w, http.StatusOK, assetStatusToAssetStatusResp(status, events))
+}
+
+// GetCandlesHandler handles request to get the candles of an asset for an interval and time range
+func (s *Server) GetCandlesHandler(w http.ResponseWriter, r *http.Request) {
+	assetId, ok := s.getTradedAssetId(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = "1m"
+	}
+	from, err := parseTimeParam(query.Get("from"), time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	candles, err := s.Candles.GetCandles(assetId, interval, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := []CandleResp{}
+	for _, c := range candles {
+		resp = append(resp, candleToCandleResp(c))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetTickerHandler handles request to get the ticker of an asset
+func (s *Server) GetTickerHandler(w http.ResponseWriter, r *http.Request) {
+	assetId, ok := s.getTradedAssetId(w, r)
+	if !ok {
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, tickerToTickerResp(s.GetTicker(assetId)))
+}
+
+// GetTickersHandler handles request to get the tickers of all assets
+func (s *Server) GetTickersHandler(w http.ResponseWriter, r *http.Request) {
+	resp := []TickerResp{}
+	for _, assetId := range s.GetTradedAssetIds() {
+		resp = append(resp, tickerToTickerResp(s.GetTicker(assetId)))
+	}
+
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// getTradedAssetId returns the asset of a request, it responds with a 404 if the asset isn't traded on the exchange
+func (s *Server) getTradedAssetId(w http.ResponseWriter, r *http.Request) (store.AssetId, bool) {
+	assetId := store.AssetId(mux.Vars(r)["assetId"])
+	if !s.IsTradedAsset(assetId) {
+		http.Error(w, fmt.Sprintf("asset %s not found", assetId), http.StatusNotFound)
+		return assetId, false
+	}
+	return assetId, true
+}
+
+// parseOrderFilter parses the asset_id and side query params of a request
+func (s *Server) parseOrderFilter(r *http.Request) (engine.OrderFilter, error) {
+	query := r.URL.Query()
+	side, err := parseSideParam(query.Get("side"), s.numericSides)
+	if err != nil {
+		return engine.OrderFilter{}, err
+	}
+	return engine.OrderFilter{AssetId: store.AssetId(query.Get("asset_id")), BuyOrSell: side}, nil
+}
+
+// JSONResponse writes output as JSON with the status code
+func JSONResponse(w http.ResponseWriter, code int, output interface{}) {
+	response, _ := json.Marshal(output)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
+
+// writeJSON writes output as JSON with the status code, with numeric sides if the server is configured with them
+func (s *Server) writeJSON(w http.ResponseWriter, code int, output interface{}) {
+	if !s.numericSides {
+		JSONResponse(w, code, output)
+		return
+	}
+	value, err := toJSONValue(output)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rewriteSides(value, sideNumbers)
+	JSONResponse(w, code, value)
+}
+
+// decodeJSON decodes the JSON body of a request into req, accepting numeric sides if the server is configured with them
+func (s *Server) decodeJSON(r *http.Request, req interface{}) error {
+	if !s.numericSides {
+		return json.NewDecoder(r.Body).Decode(req)
+	}
+	var value interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	rewriteSides(value, sideNames)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, req)
+}
+
+// MetricsHandler handles request to get the metrics of the exchange in the Prometheus text format
+func (s *Server) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.WriteMetrics(w)
+}
+
+// streamHeartbeat is how often an idle stream sends a heartbeat, so clients and proxies keep the connection open
+const streamHeartbeat = 15 * time.Second
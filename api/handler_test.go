@@ -0,0 +1,615 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/book"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+func TestAdminUserHandlers(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	router := NewRouter(s, NewAuthenticator(nil))
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	userBody := `{"user_id": "userId1", "cash": 1000, "assets": [{"asset_id": "COIN", "size": 10}]}`
+	assert.Equal(t, http.StatusCreated, serve("POST", "/admin/users", userBody).Code)
+	assert.Equal(t, http.StatusOK, serve("POST", "/admin/users", userBody).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/admin/users", `{"cash": 1000}`).Code)
+	assert.Equal(t, http.StatusCreated, serve("POST", "/admin/users", `{"user_id": "userId2"}`).Code)
+
+	// initializing the exchange validates every user before creating any
+	w := serve("POST", "/users", `[{"user_id": "userId3", "cash": 100}, {"user_id": "userId4", "cash": -1}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "users[1]: cash can't be negative\n", w.Body.String())
+	assert.False(t, s.Store.HasUser("userId3"))
+
+	w = serve("GET", "/admin/users", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var users []UserResp
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&users))
+	assert.Equal(t, []UserResp{
+		{UserId: userId1, Status: store.Active, Cash: 1000, Assets: []store.Asset{{AssetId: assetId1, Size: 10}}},
+		{UserId: userId2, Status: store.Active, Assets: []store.Asset{}},
+	}, users)
+
+	assert.Equal(t, http.StatusOK, serve("POST", "/users/userId1/orders", `{"asset_id": "COIN", "buy_or_sell": "SELL", "size": 5, "limit": 100}`).Code)
+	s.Flush() // wait for the engine to process the order
+
+	w = serve("POST", "/admin/users/userId1/suspend", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var user UserResp
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&user))
+	assert.Equal(t, store.Suspended, user.Status)
+	assert.Equal(t, 0, user.OpenOrders)
+
+	// suspended users can't place orders
+	assert.Equal(t, http.StatusForbidden, serve("POST", "/users/userId1/orders", `{"asset_id": "COIN", "buy_or_sell": "SELL", "size": 5, "limit": 100}`).Code)
+	assert.Equal(t, http.StatusOK, serve("POST", "/admin/users/userId1/resume", "").Code)
+
+	w = serve("GET", "/admin/users/userId1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&user))
+	assert.Equal(t, store.Active, user.Status)
+	assert.Equal(t, 10, user.Assets[0].Size)
+	assert.Equal(t, 1, len(user.Orders))
+	assert.Equal(t, store.Canceled, user.Orders[0].Status)
+
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/admin/users/unknown", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/admin/users/unknown/suspend", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", "/admin/users/unknown", "").Code)
+	assert.Equal(t, http.StatusConflict, serve("DELETE", "/admin/users/userId1", "").Code)
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/admin/users/userId2", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/admin/users/userId2", "").Code)
+}
+
+func TestGetOrdersHandler(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	for i := 0; i < 3; i++ {
+		s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: store.Usd(90 + i), AssetId: assetId1, Size: 1, BuyOrSell: store.BUY})
+	}
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 1, BuyOrSell: store.SELL})
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY})
+	s.Flush()
+
+	getOrders := func(target string) ([]OrderResp, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		var resp []OrderResp
+		if w.Code == http.StatusOK {
+			json.NewDecoder(w.Body).Decode(&resp)
+		}
+		return resp, w
+	}
+
+	orders, w := getOrders("/users/userId1/orders?limit=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []store.Usd{90, 91}, []store.Usd{orders[0].Limit, orders[1].Limit})
+	cursor := w.Header().Get("X-Next-Cursor")
+	assert.NotEmpty(t, cursor)
+
+	orders, w = getOrders("/users/userId1/orders?limit=2&cursor=" + cursor)
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, store.Usd(92), orders[0].Limit)
+	assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+
+	orders, _ = getOrders("/users/userId1/orders?status=complete&side=SELL")
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, store.Usd(100), orders[0].Limit)
+
+	orders, _ = getOrders("/users/userId1/orders?status=all&sort=desc&asset_id=COIN")
+	assert.Equal(t, 4, len(orders))
+	assert.Equal(t, store.SELL, orders[0].BuyOrSell)
+
+	orders, w = getOrders("/users/userId1/orders?status=canceled")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []OrderResp{}, orders)
+
+	for _, query := range []string{"status=unknown", "side=up", "side=sell", "side=1", "limit=0", "limit=1001", "sort=up", "from=yesterday", "cursor=@"} {
+		_, w = getOrders("/users/userId1/orders?" + query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetOrderHandler(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.SELL})
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 102, AssetId: assetId1, Size: 2, BuyOrSell: store.SELL})
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 105, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.Flush()
+
+	getOrder := func(userId store.UserId, orderId store.OrderId) (OrderDetailResp, int) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/users/%s/orders/%s", userId, orderId), nil))
+		var resp OrderDetailResp
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp, w.Code
+	}
+
+	buyOrder := s.GetUserActiveOrders(userId1)[0]
+	resp, code := getOrder(userId1, buyOrder.OrderId)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, buyOrder.OrderId, resp.OrderId)
+	assert.Equal(t, store.Working, resp.Status)
+	assert.Equal(t, 6, resp.Filled)
+	assert.Equal(t, 4, resp.Remaining)
+	assert.InDelta(t, 100.67, resp.AvgFillPrice, 0.01)
+	assert.Equal(t, 2, len(resp.Fills))
+	assert.Equal(t, FillResp{Price: 100, Size: 4, ExecutedAt: resp.Fills[0].ExecutedAt}, resp.Fills[0])
+	assert.Equal(t, FillResp{Price: 102, Size: 2, ExecutedAt: resp.Fills[1].ExecutedAt}, resp.Fills[1])
+	assert.Empty(t, resp.Reason)
+
+	s.CancelUserOrder(userId1, buyOrder.OrderId)
+	resp, _ = getOrder(userId1, buyOrder.OrderId)
+	assert.Equal(t, store.Canceled, resp.Status)
+	assert.Equal(t, 0, resp.Remaining)
+	assert.Equal(t, "canceled by user", resp.Reason)
+
+	sellOrder := s.GetUserCompleteOrders(userId2)[0]
+	resp, _ = getOrder(userId2, sellOrder.OrderId)
+	assert.Equal(t, 1, len(resp.Fills))
+
+	_, code = getOrder(userId1, "unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+	_, code = getOrder(userId1, sellOrder.OrderId) // order of another user
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestCreateOrderHandler_ClientOrderId(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+	serve := func(method, target, body string) (OrderDetailResp, int) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var resp OrderDetailResp
+		json.NewDecoder(w.Body).Decode(&resp)
+		s.Flush() // wait for the engine to process the order
+		return resp, w.Code
+	}
+
+	body := `{"client_order_id": "mm-1", "asset_id": "COIN", "buy_or_sell": "BUY", "size": 10, "limit": 100}`
+	order, code := serve("POST", "/users/userId1/orders", body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, order.OrderId)
+	assert.Equal(t, "mm-1", order.ClientOrderId)
+
+	// retries return the original order
+	retry, code := serve("POST", "/users/userId1/orders", body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, order.OrderId, retry.OrderId)
+	assert.Equal(t, 1, len(s.GetUserActiveOrders(userId1)))
+	assert.Equal(t, store.Usd(9000), s.Store.GetUserData(userId1).Cash)
+
+	// a different order can't reuse the id of an open order
+	_, code = serve("POST", "/users/userId1/orders", `{"client_order_id": "mm-1", "asset_id": "COIN", "buy_or_sell": "BUY", "size": 5, "limit": 100}`)
+	assert.Equal(t, http.StatusConflict, code)
+
+	// client order ids are per user
+	other, code := serve("POST", "/users/userId2/orders", `{"client_order_id": "mm-1", "asset_id": "COIN", "buy_or_sell": "BUY", "size": 5, "limit": 90}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, order.OrderId, other.OrderId)
+
+	lookup, code := serve("GET", "/users/userId1/client-orders/mm-1", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, order.OrderId, lookup.OrderId)
+	assert.Equal(t, 10, lookup.Remaining)
+
+	_, code = serve("DELETE", "/users/userId1/client-orders/mm-1", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	lookup, _ = serve("GET", "/users/userId1/client-orders/mm-1", "")
+	assert.Equal(t, store.Canceled, lookup.Status)
+
+	// the id can be reused once its order is closed
+	reused, code := serve("POST", "/users/userId1/orders", body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, order.OrderId, reused.OrderId)
+	lookup, _ = serve("GET", "/users/userId1/client-orders/mm-1", "")
+	assert.Equal(t, reused.OrderId, lookup.OrderId)
+
+	_, code = serve("GET", "/users/userId1/client-orders/unknown", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	_, code = serve("DELETE", "/users/userId1/client-orders/unknown", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	_, code = serve("POST", "/users/userId1/orders", `{"client_order_id": "`+strings.Repeat("x", 65)+`", "asset_id": "COIN", "buy_or_sell": "BUY", "size": 1, "limit": 100}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestCreateOrderHandler_Enums(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+	serve := func(body string) (map[string]interface{}, int) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/users/userId2/orders", strings.NewReader(body)))
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp, w.Code
+	}
+
+	resp, code := serve(`{"asset_id": "COIN", "buy_or_sell": "SELL", "time_in_force": "IOC", "size": 1, "limit": 100}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "SELL", resp["buy_or_sell"])
+	assert.Equal(t, "LIMIT", resp["order_type"])
+	assert.Equal(t, "IOC", resp["time_in_force"])
+
+	// unknown values are rejected instead of defaulting to a side
+	for _, body := range []string{
+		`{"asset_id": "COIN", "buy_or_sell": 1, "size": 1, "limit": 100}`,
+		`{"asset_id": "COIN", "buy_or_sell": "sell", "size": 1, "limit": 100}`,
+		`{"asset_id": "COIN", "buy_or_sell": "SHORT", "size": 1, "limit": 100}`,
+		`{"asset_id": "COIN", "buy_or_sell": "SELL", "order_type": "MARKET", "size": 1, "limit": 100}`,
+		`{"asset_id": "COIN", "buy_or_sell": "SELL", "time_in_force": "DAY", "size": 1, "limit": 100}`,
+	} {
+		_, code = serve(body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+
+	// numeric sides are accepted and emitted for older clients by a server configured with them
+	router = NewRouter(s, NewAuthenticator(nil), WithNumericSides())
+	resp, code = serve(`{"asset_id": "COIN", "buy_or_sell": 1, "size": 1, "limit": 100}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1), resp["buy_or_sell"])
+	resp, code = serve(`{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 1, "limit": 1}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(0), resp["buy_or_sell"])
+	for _, body := range []string{
+		`{"asset_id": "COIN", "buy_or_sell": 2, "size": 1, "limit": 100}`,
+		`{"asset_id": "COIN", "buy_or_sell": "1", "size": 1, "limit": 100}`,
+	} {
+		_, code = serve(body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/userId2/orders?status=all&side=1", nil))
+	var orders []map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&orders)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, len(orders))
+	assert.Equal(t, float64(1), orders[0]["buy_or_sell"])
+}
+
+func TestCreateOrderHandler_ShuttingDown(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+	s.Close()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/users/userId1/orders",
+		strings.NewReader(`{"client_order_id": "mm-1", "asset_id": "COIN", "buy_or_sell": "BUY", "size": 10, "limit": 100}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	_, ok := s.ClientOrders.Get(userId1, "mm-1")
+	assert.False(t, ok) // the client order id isn't taken by an order that was never queued
+}
+
+func TestAmendOrderHandler(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+	serve := func(method, target, body string) (OrderDetailResp, int) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var resp OrderDetailResp
+		json.NewDecoder(w.Body).Decode(&resp)
+		s.Flush() // wait for the engine to process the order
+		return resp, w.Code
+	}
+
+	order, _ := serve("POST", "/users/userId1/orders", `{"client_order_id": "mm-1", "asset_id": "COIN", "buy_or_sell": "BUY", "size": 10, "limit": 100}`)
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.SELL})
+	s.Flush()
+
+	// the remaining size is kept unless it's amended
+	amended, code := serve("PATCH", "/users/userId1/orders/"+string(order.OrderId), `{"limit": 95}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, order.OrderId, amended.OrderId)
+	assert.Equal(t, "mm-1", amended.ClientOrderId)
+	assert.Equal(t, store.Usd(95), amended.Limit)
+	assert.Equal(t, 6, amended.Size)
+
+	original, _ := serve("GET", "/users/userId1/orders/"+string(order.OrderId), "")
+	assert.Equal(t, store.Canceled, original.Status)
+	assert.Equal(t, "amended by user", original.Reason)
+	lookup, _ := serve("GET", "/users/userId1/client-orders/mm-1", "")
+	assert.Equal(t, amended.OrderId, lookup.OrderId)
+	assert.Equal(t, store.Usd(10000-400-95*6), s.Store.GetUserData(userId1).Cash)
+
+	amended, code = serve("PATCH", "/users/userId1/orders/"+string(amended.OrderId), `{"size": 20}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 20, amended.Size)
+	assert.Equal(t, store.Usd(95), amended.Limit)
+
+	// a rejected amend leaves the order working with its cash reserved
+	_, code = serve("PATCH", "/users/userId1/orders/"+string(amended.OrderId), `{"size": 1000}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	working, _ := serve("GET", "/users/userId1/orders/"+string(amended.OrderId), "")
+	assert.Equal(t, store.Working, working.Status)
+	assert.Equal(t, store.Usd(10000-400-95*20), s.Store.GetUserData(userId1).Cash)
+
+	_, code = serve("PATCH", "/users/userId1/orders/"+string(order.OrderId), `{"size": 5}`)
+	assert.Equal(t, http.StatusConflict, code) // the order isn't working anymore
+	_, code = serve("PATCH", "/users/userId1/orders/unknown", `{"size": 5}`)
+	assert.Equal(t, http.StatusNotFound, code)
+	_, code = serve("PATCH", "/users/userId1/orders/"+string(amended.OrderId), `{"size": -5}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGetTradesHandler(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.SELL})
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 102, AssetId: assetId1, Size: 2, BuyOrSell: store.SELL})
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 105, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 50, AssetId: assetId2, Size: 1, BuyOrSell: store.SELL})
+	s.Flush()
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 50, AssetId: assetId2, Size: 1, BuyOrSell: store.BUY})
+	s.Flush()
+
+	getTrades := func(target string) ([]UserTradeResp, int) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		var resp []UserTradeResp
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp, w.Code
+	}
+
+	trades, code := getTrades("/users/userId1/trades")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, len(trades))
+	assert.Equal(t, assetId2, trades[0].AssetId) // newest first
+	assert.Equal(t, store.BUY, trades[0].BuyOrSell)
+
+	trades, _ = getTrades("/users/userId1/trades?asset_id=COIN")
+	assert.Equal(t, 2, len(trades))
+	assert.Equal(t, store.Usd(102), trades[0].Price)
+	assert.Equal(t, 2, trades[0].Size)
+	assert.Equal(t, store.Usd(100), trades[1].Price)
+
+	trades, _ = getTrades("/users/userId2/trades?side=SELL&limit=1")
+	assert.Equal(t, 1, len(trades))
+	assert.Equal(t, store.SELL, trades[0].BuyOrSell)
+
+	trades, _ = getTrades("/users/userId1/trades?side=SELL")
+	assert.Equal(t, 0, len(trades))
+	_, code = getTrades("/users/userId1/trades?limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGetDepthHandler(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 99, AssetId: assetId1, Size: 4, BuyOrSell: store.BUY})
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 98, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY})
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 99, AssetId: assetId1, Size: 2, BuyOrSell: store.BUY})
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 101, AssetId: assetId1, Size: 3, BuyOrSell: store.SELL})
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 103, AssetId: assetId1, Size: 5, BuyOrSell: store.SELL})
+	s.Flush()
+
+	getDepth := func(target string) (DepthResp, int) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		var resp DepthResp
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp, w.Code
+	}
+
+	depth, code := getDepth("/assets/COIN/depth")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, book.Continuous, depth.Phase)
+	assert.Equal(t, []PriceLevelResp{{Price: 99, Size: 6, Orders: 2}, {Price: 98, Size: 1, Orders: 1}}, depth.Bids)
+	assert.Equal(t, []PriceLevelResp{{Price: 101, Size: 3, Orders: 1}, {Price: 103, Size: 5, Orders: 1}}, depth.Asks)
+
+	depth, _ = getDepth("/assets/COIN/depth?levels=1")
+	assert.Equal(t, []PriceLevelResp{{Price: 99, Size: 6, Orders: 2}}, depth.Bids)
+	assert.Equal(t, []PriceLevelResp{{Price: 101, Size: 3, Orders: 1}}, depth.Asks)
+
+	depth, _ = getDepth("/assets/GAME/depth")
+	assert.Empty(t, depth.Bids)
+	assert.Empty(t, depth.Asks)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/assets/COIN/depth?levels=lots", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "levels must be between 1 and"))
+}
+
+func TestMarketDataHandlers_UnknownAssets(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+	getTickers := func() []store.AssetId {
+		var tickers []TickerResp
+		json.NewDecoder(get("/tickers").Body).Decode(&tickers)
+		assetIds := []store.AssetId{}
+		for _, ticker := range tickers {
+			assetIds = append(assetIds, ticker.AssetId)
+		}
+		return assetIds
+	}
+
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 99, AssetId: assetId1, Size: 4, BuyOrSell: store.BUY})
+	s.Flush()
+
+	// reads of unknown assets don't create their order book
+	for _, target := range []string{"ticker", "depth", "candles", "status", "phase", "auction"} {
+		assert.Equal(t, http.StatusNotFound, get("/assets/NOPE/"+target).Code, target)
+	}
+	assert.Equal(t, http.StatusOK, get("/assets/COIN/ticker").Code)
+	assert.Equal(t, []store.AssetId{assetId1}, s.OrderBooks.GetAssetIds())
+	assert.Equal(t, []store.AssetId{assetId1}, getTickers())
+
+	// once instruments are listed only listed assets are traded
+	assert.NoError(t, s.ListInstrument(engine.Instrument{AssetId: assetId2}))
+	assert.Equal(t, http.StatusNotFound, get("/assets/COIN/ticker").Code)
+	assert.Equal(t, http.StatusOK, get("/assets/GAME/ticker").Code)
+	assert.Equal(t, []store.AssetId{assetId2}, getTickers())
+}
+
+func TestCreateOrderHandler_RiskRejection(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	s.Risk.Config = engine.RiskConfig{Default: engine.RiskLimits{MaxOrderNotional: 1000}}
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	w := httptest.NewRecorder()
+	body := `{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 11, "limit": 100}`
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/users/userId1/orders", strings.NewReader(body)))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var resp RiskRejectionResp
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, engine.MaxOrderNotionalExceeded, resp.Code)
+	assert.Equal(t, "order notional 1100 is over the max of 1000", resp.Reason)
+
+	w = httptest.NewRecorder()
+	body = `{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 10, "limit": 100}`
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/users/userId1/orders", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestStreamHandler_CancelOnDisconnect(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 90, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 100, AssetId: assetId2, Size: 10, BuyOrSell: store.SELL})
+	s.Flush()
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	ctx, disconnect := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/users/userId1/stream?cancel_on_disconnect=true&asset_id=COIN", nil)
+	resp, err := http.DefaultClient.Do(req) // returns once the stream is subscribed to the trades
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	s.SubmitOrder(engine.OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.BUY})
+	events := bufio.NewReader(resp.Body)
+	event, _ := events.ReadString('\n')
+	data, _ := events.ReadString('\n')
+	assert.Equal(t, "event: trade\n", event)
+	assert.True(t, strings.HasPrefix(data, "data: {\"asset_id\":\"COIN\",\"price\":100,\"size\":4,"))
+
+	disconnect()
+	resp.Body.Close()
+
+	// only the orders in COIN were canceled
+	assert.Eventually(t, func() bool { return len(s.GetUserActiveOrders(userId1)) == 1 }, time.Second, time.Millisecond)
+	activeOrders := s.GetUserActiveOrders(userId1)
+	assert.Equal(t, 1, len(activeOrders))
+	assert.Equal(t, assetId2, activeOrders[0].AssetId)
+	assert.Empty(t, s.OrderBooks.GetOrders(assetId1))
+}
+
+func TestTransferHandlers(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"type": "DEPOSIT", "asset_id": "COIN", "amount": 25}`
+	req := httptest.NewRequest("POST", "/admin/users/userId1/transfers", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", "deposit-1")
+	w := serve(req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var transfer TransferResp
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&transfer))
+	assert.Equal(t, "deposit-1", transfer.IdempotencyKey)
+	assert.Equal(t, store.Deposit, transfer.Type)
+
+	assert.Equal(t, http.StatusOK, serve(httptest.NewRequest("POST", "/admin/users/userId1/transfers",
+		strings.NewReader(`{"type": "DEPOSIT", "asset_id": "COIN", "amount": 25, "idempotency_key": "deposit-1"}`))).Code)
+	assert.Equal(t, http.StatusConflict, serve(httptest.NewRequest("POST", "/admin/users/userId1/transfers",
+		strings.NewReader(`{"type": "DEPOSIT", "amount": 25, "idempotency_key": "deposit-1"}`))).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(httptest.NewRequest("POST", "/admin/users/userId1/transfers",
+		strings.NewReader(`{"type": "DEPOSIT", "amount": 25}`))).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(httptest.NewRequest("POST", "/users/userId1/transfers",
+		strings.NewReader(`{"type": "WITHDRAWAL", "amount": 10001, "idempotency_key": "withdrawal-1"}`))).Code)
+	assert.Equal(t, http.StatusNotFound, serve(httptest.NewRequest("POST", "/admin/users/unknown/transfers",
+		strings.NewReader(`{"type": "DEPOSIT", "amount": 25, "idempotency_key": "deposit-1"}`))).Code)
+
+	// users can't credit their own balance
+	assert.Equal(t, http.StatusForbidden, serve(httptest.NewRequest("POST", "/users/userId1/transfers",
+		strings.NewReader(`{"type": "DEPOSIT", "amount": 25, "idempotency_key": "deposit-2"}`))).Code)
+
+	w = serve(httptest.NewRequest("GET", "/users/userId1/transfers", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var transfers []TransferResp
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&transfers))
+	assert.Equal(t, []TransferResp{transfer}, transfers)
+
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.Flush()
+
+	w = serve(httptest.NewRequest("GET", "/users/userId1/balances", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var balances BalancesResp
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&balances))
+	assert.Equal(t, BalancesResp{
+		UserId:       userId1,
+		Cash:         9000,
+		ReservedCash: 1000,
+		Assets:       []AssetBalanceResp{{AssetId: assetId1, Available: 125}, {AssetId: assetId2, Available: 100}},
+	}, balances)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+var userId1 = store.UserId("userId1")
+
+var userId2 = store.UserId("userId2")
+
+var assetId1 = store.AssetId("COIN")
+
+var assetId2 = store.AssetId("GAME")
+
+func setupTestUsers(s *engine.OrderMatchingService) {
+	req1 := store.InitExchangeReq{
+		UserId: userId1,
+		Assets: []store.Asset{{AssetId: assetId1, Size: 100}, {AssetId: assetId2, Size: 100}},
+		Cash:   10000,
+	}
+
+	req2 := store.InitExchangeReq{
+		UserId: userId2,
+		Assets: []store.Asset{{AssetId: assetId1, Size: 100}, {AssetId: assetId2, Size: 100}},
+		Cash:   10000,
+	}
+
+	s.InitExchange([]store.InitExchangeReq{req1, req2})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"stockexchange/store"
+)
+
+// requestIdHeader is the header of the id of a request, set by clients or generated
+const requestIdHeader = "X-Request-Id"
+
+// maxRequestIdLength is the max length of a request id set by a client
+const maxRequestIdLength = 64
+
+type requestIdKey struct{}
+
+// getRequestId returns the id of the request of a context, empty if there is none
+func getRequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// LogRequests is a middleware giving every request an id and logging it once it's served.
+// The id is taken from the X-Request-Id header when the client sets one, and is returned in the same header.
+func (s *Server) LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(requestIdHeader)
+		if requestId == "" || len(requestId) > maxRequestIdLength {
+			requestId = store.RandomIds()
+		}
+		w.Header().Set(requestIdHeader, requestId)
+		r = r.WithContext(context.WithValue(r.Context(), requestIdKey{}, requestId))
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		s.Logger.Info("request served", "request_id", requestId, "method", r.Method, "path", r.URL.Path,
+			"code", sw.code, "duration_ms", float64(time.Since(start).Microseconds())/1000)
+	})
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/engine"
+)
+
+func TestLogRequests_TracesOrders(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	var out bytes.Buffer
+	s.Logger = engine.NewLogger(&out, engine.InfoLevel)
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	req := httptest.NewRequest("POST", "/users/userId1/orders", strings.NewReader(`{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 10, "limit": 100}`))
+	req.Header.Set(requestIdHeader, "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "req-1", w.Header().Get(requestIdHeader))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/users/userId2/orders", strings.NewReader(`{"asset_id": "COIN", "buy_or_sell": "SELL", "size": 4, "limit": 100}`)))
+	assert.NotEmpty(t, w.Header().Get(requestIdHeader))
+	s.Flush()
+
+	// the order of the first request is logged from the request to its fill
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var log map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &log))
+		if log["request_id"] == "req-1" {
+			msgs = append(msgs, log["msg"].(string))
+		}
+	}
+	assert.ElementsMatch(t, []string{"order queued", "request served", "order matched", "order filled"}, msgs)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/engine"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	router := NewRouter(s, NewAuthenticator(nil))
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		s.Flush()
+		return w
+	}
+
+	serve("POST", "/users/userId1/orders", `{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 10, "limit": 100}`)
+	serve("POST", "/users/userId2/orders", `{"asset_id": "COIN", "buy_or_sell": "SELL", "size": 4, "limit": 100}`)
+	serve("POST", "/users/userId2/orders", `{"asset_id": "COIN", "buy_or_sell": "SELL", "size": 1000, "limit": 100}`)
+
+	w := serve("GET", "/metrics", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `exchange_orders_accepted_total{asset_id="COIN"} 2`)
+	assert.Contains(t, body, `exchange_fills_total{asset_id="COIN",side="BUY"} 1`)
+	assert.Contains(t, body, `exchange_traded_volume_total{asset_id="COIN"} 4`)
+	assert.Contains(t, body, `exchange_matching_latency_seconds_count{asset_id="COIN"} 2`)
+	assert.Contains(t, body, `exchange_http_requests_total{method="POST",route="/users/{userId}/orders",code="200"} 2`)
+	assert.Contains(t, body, `exchange_orders_rejected_total{reason="invalid"} 1`)
+	assert.Contains(t, body, `exchange_traded_notional_total{asset_id="COIN"} 400`)
+	assert.Contains(t, body, `exchange_http_request_duration_seconds_count{method="POST",route="/users/{userId}/orders"} 3`)
+	assert.Contains(t, body, "exchange_order_queue_depth 0")
+	assert.Contains(t, body, `exchange_engine_queue_depth{asset_id="COIN"} 0`)
+	assert.Contains(t, body, `exchange_book_depth{asset_id="COIN",side="BUY"} 6`)
+	assert.Contains(t, body, `exchange_book_depth{asset_id="COIN",side="SELL"} 0`)
+}
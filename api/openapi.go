@@ -0,0 +1,354 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"stockexchange/book"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+// OpenAPI document
+//
+// The OpenAPI 3 document of the api is generated from its routes and the Go types of their request and response
+// bodies, so it can't drift from the handlers. Properties are named after the json tags of the types, the ones
+// without omitempty are required in responses, and the types with a fixed set of values are enums.
+
+// enums are the values of the types with a fixed set of values
+var enums = map[reflect.Type][]interface{}{
+	reflect.TypeOf(store.BUY):                  {store.BUY, store.SELL},
+	reflect.TypeOf(store.Limit):                {store.Limit},
+	reflect.TypeOf(store.GTC):                  {store.GTC, store.IOC, store.FOK},
+	reflect.TypeOf(store.Working):              {store.Working, store.Complete, store.Canceled, store.Rejected},
+	reflect.TypeOf(store.Active):               {store.Active, store.Suspended},
+	reflect.TypeOf(store.Deposit):              {store.Deposit, store.Withdrawal},
+	reflect.TypeOf(book.Continuous):            {book.Closed, book.PreOpen, book.Auction, book.Continuous, book.Halted, book.PostClose},
+	reflect.TypeOf(engine.HaltEvent):           {engine.HaltEvent, engine.ResumeEvent},
+	reflect.TypeOf(engine.MaxPositionExceeded): {engine.MaxOrderNotionalExceeded, engine.MaxPositionExceeded, engine.PriceDeviationExceeded, engine.MaxDailyNotionalExceeded},
+}
+
+// tags group the operations of the document by who can call them
+var tags = map[access]string{
+	publicAccess: "Market data",
+	userAccess:   "Trading",
+	adminAccess:  "Admin",
+}
+
+type openAPIDoc struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       openAPIInfo                     `json:"info"`
+	Servers    []openAPIServer                 `json:"servers"`
+	Paths      map[string]map[string]operation `json:"paths"` // path -> lower case method -> operation
+	Components components                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type operation struct {
+	OperationId string                    `json:"operationId"`
+	Summary     string                    `json:"summary"`
+	Tags        []string                  `json:"tags"`
+	Parameters  []parameter               `json:"parameters,omitempty"`
+	RequestBody *requestBody              `json:"requestBody,omitempty"`
+	Responses   map[string]responseObject `json:"responses"` // status code -> response
+	Security    []map[string][]string     `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"` // content type -> body
+}
+
+type responseObject struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"` // content type -> body
+}
+
+type mediaType struct {
+	Schema schema `json:"schema"`
+}
+
+type components struct {
+	Schemas         map[string]*schema        `json:"schemas"`
+	SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// schema is a JSON schema of the document
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"` // reference to a schema of the components
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              int                `json:"minimum,omitempty"`
+	Maximum              int                `json:"maximum,omitempty"`
+	Items                *schema            `json:"items,omitempty"`                // schema of the items of an array
+	Properties           map[string]*schema `json:"properties,omitempty"`           // schemas of the properties of an object
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"` // schema of the values of a map
+	Required             []string           `json:"required,omitempty"`             // properties always present
+}
+
+// securityRequirement is the security of authenticated operations, requests are signed with all three headers
+var securityRequirement = []map[string][]string{{"apiKey": {}, "timestamp": {}, "signature": {}}}
+
+// pathParamRegexp matches the params of a path template, e.g {userId}
+var pathParamRegexp = regexp.MustCompile(`{(\w+)}`)
+
+// newOpenAPIDoc returns the OpenAPI document of the versioned routes of the api
+func newOpenAPIDoc(routes []route) openAPIDoc {
+	doc := openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "limited-stock-exchange",
+			Version:     strings.TrimPrefix(apiVersion, "/v"),
+			Description: "Exchange matching limit orders to buy and sell assets. Prices and cash are in Usd cents.",
+		},
+		Servers: []openAPIServer{{URL: apiVersion}},
+		Paths:   make(map[string]map[string]operation),
+		Components: components{
+			Schemas: make(map[string]*schema),
+			SecuritySchemes: map[string]securityScheme{
+				"apiKey":    {Type: "apiKey", In: "header", Name: apiKeyHeader, Description: "api key, when authentication is enabled"},
+				"timestamp": {Type: "apiKey", In: "header", Name: timestampHeader, Description: "unix time in seconds the request was signed at"},
+				"signature": {Type: "apiKey", In: "header", Name: signatureHeader, Description: "hex encoded HMAC-SHA256, keyed with the api key's secret, of the timestamp, method, request URI and body separated by new lines"},
+			},
+		},
+	}
+
+	g := &schemaGenerator{schemas: doc.Components.Schemas, requests: make(map[reflect.Type]bool)}
+	for _, rt := range routes {
+		if rt.request != nil {
+			g.addRequestType(reflect.TypeOf(rt.request))
+		}
+	}
+
+	for _, rt := range routes {
+		if doc.Paths[rt.path] == nil {
+			doc.Paths[rt.path] = make(map[string]operation)
+		}
+		doc.Paths[rt.path][strings.ToLower(rt.method)] = g.operation(rt)
+	}
+	return doc
+}
+
+// schemaGenerator generates the schemas of Go types, named struct types are added to the schemas of the components
+type schemaGenerator struct {
+	schemas  map[string]*schema
+	requests map[reflect.Type]bool // struct types of request bodies, their properties aren't required
+}
+
+// operation returns the operation of a route
+func (g *schemaGenerator) operation(rt route) operation {
+	op := operation{
+		OperationId: operationId(rt.handler),
+		Summary:     rt.summary,
+		Tags:        []string{tags[rt.access]},
+		Responses:   make(map[string]responseObject),
+	}
+
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(rt.path, -1) {
+		op.Parameters = append(op.Parameters, parameter{Name: match[1], In: "path", Required: true, Schema: schema{Type: "string"}})
+	}
+	for _, p := range rt.params {
+		op.Parameters = append(op.Parameters, parameter{Name: p.name, In: p.in, Description: p.description, Schema: p.schema})
+	}
+
+	if rt.request != nil {
+		op.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]mediaType{"application/json": {Schema: g.schemaOf(reflect.TypeOf(rt.request))}},
+		}
+	}
+
+	responses := rt.responses
+	if rt.access != publicAccess {
+		op.Security = securityRequirement
+		responses = append(responses, response{code: http.StatusUnauthorized}, response{code: http.StatusForbidden})
+	}
+	responses = append(responses, response{code: http.StatusTooManyRequests})
+	for _, resp := range responses {
+		code := strconv.Itoa(resp.code)
+		if _, ok := op.Responses[code]; ok {
+			continue
+		}
+		op.Responses[code] = g.responseObject(resp)
+	}
+	return op
+}
+
+// responseObject returns the response object of a response, errors without a body are plain text messages
+func (g *schemaGenerator) responseObject(resp response) responseObject {
+	obj := responseObject{Description: http.StatusText(resp.code)}
+	switch {
+	case resp.body != nil:
+		obj.Content = map[string]mediaType{"application/json": {Schema: g.schemaOf(reflect.TypeOf(resp.body))}}
+	case resp.contentType != "":
+		obj.Content = map[string]mediaType{resp.contentType: {Schema: schema{Type: "string"}}}
+	case resp.code >= http.StatusBadRequest:
+		obj.Content = map[string]mediaType{"text/plain": {Schema: schema{Type: "string"}}}
+	}
+	return obj
+}
+
+// addRequestType marks the struct types of a request body, and of its properties, as request types
+func (g *schemaGenerator) addRequestType(t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		g.addRequestType(t.Elem())
+	case reflect.Struct:
+		if g.requests[t] {
+			return
+		}
+		g.requests[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			g.addRequestType(t.Field(i).Type)
+		}
+	}
+}
+
+// schemaOf returns the schema of a type, named struct types are referenced from the components
+func (g *schemaGenerator) schemaOf(t reflect.Type) schema {
+	if values, ok := enums[t]; ok {
+		s := enumSchema(values)
+		s.Enum = values
+		return s
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem())
+	case reflect.Slice, reflect.Array:
+		items := g.schemaOf(t.Elem())
+		return schema{Type: "array", Items: &items}
+	case reflect.Map:
+		values := g.schemaOf(t.Elem())
+		return schema{Type: "object", AdditionalProperties: &values}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			g.schemas[t.Name()] = &schema{} // added before its fields, in case a field references the type
+			*g.schemas[t.Name()] = g.structSchema(t)
+		}
+		return schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	return kindSchema(t.Kind())
+}
+
+// structSchema returns the schema of the JSON object of a struct type
+func (g *schemaGenerator) structSchema(t reflect.Type) schema {
+	s := schema{Type: "object", Properties: make(map[string]*schema)}
+	g.addProperties(&s, t, !g.requests[t])
+	return s
+}
+
+// addProperties adds the properties of the exported fields of a struct type to an object schema,
+// the fields of embedded structs are properties of the object like they are in JSON
+func (g *schemaGenerator) addProperties(s *schema, t reflect.Type, required bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addProperties(s, field.Type, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaOf(field.Type)
+		s.Properties[name] = &property
+		if required && !hasOption(tag[1:], "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// enumSchema returns the schema of the JSON values of an enum, of the kind of values they're marshalled to,
+// e.g sides are marshalled to strings
+func enumSchema(values []interface{}) schema {
+	data, err := json.Marshal(values[0])
+	if err == nil && strings.HasPrefix(string(data), `"`) {
+		return schema{Type: "string"}
+	}
+	return kindSchema(reflect.TypeOf(values[0]).Kind())
+}
+
+// kindSchema returns the schema of the JSON value of a kind of Go value
+func kindSchema(kind reflect.Kind) schema {
+	switch kind {
+	case reflect.String:
+		return schema{Type: "string"}
+	case reflect.Bool:
+		return schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{Type: "number"}
+	}
+	return schema{}
+}
+
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// operationId returns the id of the operation of a handler, named after the handler, e.g getOrders for GetOrdersHandler
+func operationId(handler http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name() // e.g stockexchange/api.(*Server).GetOrdersHandler-fm
+	name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+	name = strings.TrimSuffix(name, "Handler")
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// OpenAPIHandler handles request to get the OpenAPI document of the api
+func (s *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.openAPI)
+}
@@ -0,0 +1,363 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+// contract calls the api and checks requests and responses conform to the OpenAPI document it serves
+type contract struct {
+	t       *testing.T
+	router  http.Handler
+	doc     map[string]interface{}
+	covered map[string]bool // "METHOD path" of the operations called
+}
+
+func newContract(t *testing.T, router http.Handler) *contract {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	c := &contract{t: t, router: router, covered: make(map[string]bool)}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &c.doc))
+	return c
+}
+
+// call sends a request to a versioned route and checks its response has the expected code and conforms to the document
+func (c *contract) call(method, target, body string, code int) *httptest.ResponseRecorder {
+	c.t.Helper()
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	assert.Equal(c.t, code, w.Code, "%s %s: %s", method, target, w.Body.String())
+	c.check(method, target, body, w)
+	return w
+}
+
+func (c *contract) check(method, target, body string, w *httptest.ResponseRecorder) {
+	c.t.Helper()
+	path, op := c.operation(method, target)
+	if op == nil {
+		c.t.Errorf("%s %s: no operation in the document", method, target)
+		return
+	}
+	c.covered[method+" "+path] = true
+
+	// bodies of accepted requests conform to the document
+	if reqBody, ok := op["requestBody"].(map[string]interface{}); ok && w.Code < http.StatusBadRequest {
+		var value interface{}
+		assert.NoError(c.t, json.Unmarshal([]byte(body), &value))
+		schema := reqBody["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+		assert.Empty(c.t, c.validate(schema, value, "request"), "%s %s", method, target)
+	}
+
+	resp, ok := op["responses"].(map[string]interface{})[strconv.Itoa(w.Code)].(map[string]interface{})
+	if !ok {
+		c.t.Errorf("%s %s: response %d isn't documented", method, target, w.Code)
+		return
+	}
+	content, _ := resp["content"].(map[string]interface{})
+	if len(content) == 0 {
+		assert.Empty(c.t, w.Body.String(), "%s %s", method, target)
+		return
+	}
+	for contentType, media := range content {
+		assert.True(c.t, strings.HasPrefix(w.Header().Get("Content-Type"), contentType), "%s %s: content type %s", method, target, w.Header().Get("Content-Type"))
+		if contentType != "application/json" {
+			continue
+		}
+		var value interface{}
+		assert.NoError(c.t, json.Unmarshal(w.Body.Bytes(), &value))
+		schema := media.(map[string]interface{})["schema"].(map[string]interface{})
+		assert.Empty(c.t, c.validate(schema, value, "response"), "%s %s", method, target)
+	}
+}
+
+// operation returns the path template and operation of the document matching a request to a versioned route
+func (c *contract) operation(method, target string) (string, map[string]interface{}) {
+	path := strings.TrimPrefix(strings.SplitN(target, "?", 2)[0], apiVersion)
+	for template, ops := range c.doc["paths"].(map[string]interface{}) {
+		pattern := "^" + regexp.MustCompile(`{\w+}`).ReplaceAllString(template, "[^/]+") + "$"
+		if !regexp.MustCompile(pattern).MatchString(path) {
+			continue
+		}
+		if op, ok := ops.(map[string]interface{})[strings.ToLower(method)].(map[string]interface{}); ok {
+			return template, op
+		}
+	}
+	return "", nil
+}
+
+// validate returns where a JSON value doesn't conform to a schema of the document.
+// Objects can't have properties the schema doesn't list.
+func (c *contract) validate(schema map[string]interface{}, value interface{}, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		return c.validate(c.doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})[name].(map[string]interface{}), value, at)
+	}
+
+	var errs []string
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, v := range enum {
+			found = found || v == value
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v isn't one of %v", at, value, enum))
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected an object, got %v", at, value))
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing %s", at, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, v := range object {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				errs = append(errs, c.validate(property, v, at+"."+name)...)
+			} else if values, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				errs = append(errs, c.validate(values, v, at+"."+name)...)
+			} else {
+				errs = append(errs, fmt.Sprintf("%s: %s isn't in the document", at, name))
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected an array, got %v", at, value))
+		}
+		for i, v := range array {
+			errs = append(errs, c.validate(schema["items"].(map[string]interface{}), v, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected a string, got %v", at, value))
+		}
+		if _, err := time.Parse(time.RFC3339Nano, s); schema["format"] == "date-time" && err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s isn't a date-time", at, s))
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			errs = append(errs, fmt.Sprintf("%s: expected an integer, got %v", at, value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected a number, got %v", at, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected a boolean, got %v", at, value))
+		}
+	}
+	return errs
+}
+
+func TestOpenAPI_Contract(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	router := NewRouter(s, NewAuthenticator(nil))
+	c := newContract(t, router)
+
+	// users
+	c.call("POST", "/v1/users", `[
+		{"user_id": "userId1", "cash": 10000, "assets": [{"asset_id": "COIN", "size": 100}]},
+		{"user_id": "userId2", "cash": 10000, "assets": [{"asset_id": "COIN", "size": 100}]}
+	]`, http.StatusOK)
+	c.call("POST", "/v1/users", `{"user_id": "userId1"}`, http.StatusBadRequest)
+	c.call("POST", "/v1/admin/users", `{"user_id": "userId3"}`, http.StatusCreated)
+	c.call("POST", "/v1/admin/users", `{"user_id": "userId3"}`, http.StatusOK)
+	c.call("POST", "/v1/admin/users", `{"cash": 100}`, http.StatusBadRequest)
+	c.call("GET", "/v1/admin/users", "", http.StatusOK)
+	c.call("DELETE", "/v1/admin/users/userId3", "", http.StatusNoContent)
+	c.call("DELETE", "/v1/admin/users/userId3", "", http.StatusNotFound)
+	c.call("DELETE", "/v1/admin/users/userId1", "", http.StatusConflict)
+
+	// orders
+	var ask OrderResp
+	w := c.call("POST", "/v1/users/userId2/orders", `{"client_order_id": "ask-1", "asset_id": "COIN", "buy_or_sell": "SELL", "size": 10, "limit": 100}`, http.StatusOK)
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&ask))
+	c.call("POST", "/v1/users/userId1/orders", `{"client_order_id": "bid-1", "asset_id": "COIN", "buy_or_sell": "BUY", "size": 4, "limit": 100}`, http.StatusOK)
+	c.call("POST", "/v1/users/userId1/orders", `{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 1000, "limit": 100}`, http.StatusBadRequest)
+	s.Flush()
+
+	c.call("GET", "/v1/users/userId2/orders?status=all&asset_id=COIN&side=SELL&limit=10&sort=desc", "", http.StatusOK)
+	c.call("GET", "/v1/users/userId2/orders?status=unknown", "", http.StatusBadRequest)
+	c.call("GET", "/v1/users/userId2/orders/"+string(ask.OrderId), "", http.StatusOK)
+	c.call("GET", "/v1/users/userId2/orders/unknown", "", http.StatusNotFound)
+	c.call("GET", "/v1/users/userId1/client-orders/bid-1", "", http.StatusOK)
+	c.call("GET", "/v1/users/userId1/client-orders/unknown", "", http.StatusNotFound)
+	c.call("DELETE", "/v1/users/userId1/client-orders/unknown", "", http.StatusNotFound)
+
+	var amended OrderResp
+	w = c.call("PATCH", "/v1/users/userId2/orders/"+string(ask.OrderId), `{"limit": 101}`, http.StatusOK)
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&amended))
+	c.call("PATCH", "/v1/users/userId2/orders/unknown", `{"limit": 101}`, http.StatusNotFound)
+	s.Flush()
+	c.call("DELETE", "/v1/users/userId2/orders/"+string(amended.OrderId), "", http.StatusNoContent)
+	c.call("DELETE", "/v1/users/userId2/client-orders/ask-1", "", http.StatusNoContent)
+	c.call("POST", "/v1/users/userId2/orders", `{"asset_id": "COIN", "buy_or_sell": "SELL", "size": 5, "limit": 110}`, http.StatusOK)
+	s.Flush()
+	c.call("DELETE", "/v1/users/userId2/orders?asset_id=COIN&side=SELL", "", http.StatusOK)
+	c.call("DELETE", "/v1/users/userId2/orders?side=HOLD", "", http.StatusBadRequest)
+	c.call("GET", "/v1/users/userId1/trades?asset_id=COIN&limit=10", "", http.StatusOK)
+	c.call("GET", "/v1/users/userId1/trades?limit=0", "", http.StatusBadRequest)
+
+	// transfers and balances
+	c.call("POST", "/v1/admin/users/userId1/transfers", `{"type": "DEPOSIT", "amount": 500, "idempotency_key": "d-1"}`, http.StatusCreated)
+	c.call("POST", "/v1/admin/users/userId1/transfers", `{"type": "DEPOSIT", "amount": 500, "idempotency_key": "d-1"}`, http.StatusOK)
+	c.call("POST", "/v1/admin/users/userId1/transfers", `{"type": "DEPOSIT", "amount": 600, "idempotency_key": "d-1"}`, http.StatusConflict)
+	c.call("POST", "/v1/admin/users/userId1/transfers", `{"type": "GIFT", "amount": 500, "idempotency_key": "d-2"}`, http.StatusBadRequest)
+	c.call("POST", "/v1/admin/users/unknown/transfers", `{"type": "DEPOSIT", "amount": 500, "idempotency_key": "d-3"}`, http.StatusNotFound)
+	c.call("POST", "/v1/users/userId1/transfers", `{"type": "WITHDRAWAL", "amount": 100, "idempotency_key": "w-1"}`, http.StatusCreated)
+	c.call("POST", "/v1/users/userId1/transfers", `{"type": "WITHDRAWAL", "amount": 100, "idempotency_key": "w-1"}`, http.StatusOK)
+	c.call("POST", "/v1/users/userId1/transfers", `{"type": "DEPOSIT", "amount": 500, "idempotency_key": "d-4"}`, http.StatusForbidden)
+	c.call("POST", "/v1/users/userId1/transfers", `{"type": "WITHDRAWAL", "amount": 100, "idempotency_key": "w-1", "asset_id": "COIN"}`, http.StatusConflict)
+	c.call("POST", "/v1/users/userId1/transfers", `{"type": "WITHDRAWAL", "amount": 0, "idempotency_key": "w-2"}`, http.StatusBadRequest)
+	c.call("POST", "/v1/users/unknown/transfers", `{"type": "WITHDRAWAL", "amount": 100, "idempotency_key": "w-3"}`, http.StatusNotFound)
+	c.call("GET", "/v1/users/userId1/transfers", "", http.StatusOK)
+	c.call("GET", "/v1/users/userId1/balances", "", http.StatusOK)
+	c.call("GET", "/v1/users/unknown/balances", "", http.StatusNotFound)
+
+	// market data
+	c.call("GET", "/v1/assets/COIN/ticker", "", http.StatusOK)
+	c.call("GET", "/v1/tickers", "", http.StatusOK)
+	c.call("GET", "/v1/assets/COIN/depth?levels=5", "", http.StatusOK)
+	c.call("GET", "/v1/assets/COIN/depth?levels=0", "", http.StatusBadRequest)
+	c.call("GET", "/v1/assets/COIN/candles?interval=1m&from=0", "", http.StatusOK)
+	c.call("GET", "/v1/assets/COIN/candles?interval=2m", "", http.StatusBadRequest)
+	c.call("GET", "/v1/assets/COIN/status", "", http.StatusOK)
+	c.call("GET", "/v1/assets/COIN/phase", "", http.StatusOK)
+	for _, target := range []string{"ticker", "depth", "candles", "status", "phase", "auction"} {
+		c.call("GET", "/v1/assets/UNKNOWN/"+target, "", http.StatusNotFound)
+	}
+
+	// auctions and phases
+	c.call("GET", "/v1/assets/COIN/auction", "", http.StatusConflict)
+	c.call("POST", "/v1/assets/COIN/auction/uncross", "", http.StatusConflict)
+	c.call("POST", "/v1/assets/COIN/auction", "", http.StatusOK)
+	c.call("POST", "/v1/users/userId1/orders", `{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 2, "limit": 105}`, http.StatusOK)
+	c.call("POST", "/v1/users/userId2/orders", `{"asset_id": "COIN", "buy_or_sell": "SELL", "size": 2, "limit": 100}`, http.StatusOK)
+	s.Flush()
+	c.call("GET", "/v1/assets/COIN/auction", "", http.StatusOK)
+	c.call("POST", "/v1/assets/COIN/auction/uncross", "", http.StatusOK)
+	c.call("PUT", "/v1/assets/COIN/phase", `{"phase": "HALTED"}`, http.StatusOK)
+	c.call("PUT", "/v1/assets/COIN/phase", `{"phase": "LUNCH"}`, http.StatusBadRequest)
+	c.call("PUT", "/v1/assets/COIN/phase", `{"phase": "POST_CLOSE"}`, http.StatusConflict)
+	c.call("DELETE", "/v1/admin/assets/COIN/orders", "", http.StatusOK)
+
+	// admin
+	c.call("GET", "/v1/admin/users/userId1", "", http.StatusOK)
+	c.call("GET", "/v1/admin/users/unknown", "", http.StatusNotFound)
+	c.call("POST", "/v1/admin/users/userId1/suspend", "", http.StatusOK)
+	c.call("POST", "/v1/admin/users/unknown/suspend", "", http.StatusNotFound)
+	c.call("POST", "/v1/admin/users/userId1/resume", "", http.StatusOK)
+	c.call("POST", "/v1/admin/users/unknown/resume", "", http.StatusNotFound)
+
+	// streams end when the request is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/users/userId1/stream?cancel_on_disconnect=true", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	c.check("GET", "/v1/users/userId1/stream", "", w)
+
+	for path, ops := range c.doc["paths"].(map[string]interface{}) {
+		for method := range ops.(map[string]interface{}) {
+			assert.True(t, c.covered[strings.ToUpper(method)+" "+path], "%s %s isn't covered", method, path)
+		}
+	}
+}
+
+func TestOpenAPI_Schemas(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+	c := newContract(t, NewRouter(s, NewAuthenticator(nil)))
+	schemas := c.doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+	// the properties of a schema are the JSON fields of its type
+	for name, value := range map[string]interface{}{
+		"OrderReq":        engine.OrderReq{OrderId: "o1", AssetId: assetId1, Size: 10, Limit: 100, OrderType: store.Limit, TimeInForce: store.IOC, RequestId: "r1"},
+		"OrderResp":       OrderResp{OrderId: "o1", ClientOrderId: "c1", OrderType: store.Limit, TimeInForce: store.GTC, Status: store.Working},
+		"InitExchangeReq": store.InitExchangeReq{UserId: userId1, Assets: []store.Asset{{AssetId: assetId1, Size: 10}}},
+	} {
+		data, _ := json.Marshal(value)
+		var fields map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &fields))
+
+		schema := schemas[name].(map[string]interface{})
+		var properties []string
+		for property := range schema["properties"].(map[string]interface{}) {
+			properties = append(properties, property)
+		}
+		var names []string
+		for field := range fields {
+			names = append(names, field)
+		}
+		assert.ElementsMatch(t, names, properties, name)
+		assert.Empty(t, c.validate(schema, fields, name))
+	}
+
+	// request properties are optional, response properties without omitempty are always present
+	assert.Nil(t, schemas["OrderReq"].(map[string]interface{})["required"])
+	assert.Contains(t, schemas["OrderResp"].(map[string]interface{})["required"], "order_id")
+	assert.NotContains(t, schemas["OrderResp"].(map[string]interface{})["required"], "client_order_id")
+	assert.Equal(t, []interface{}{"WORKING", "COMPLETE", "CANCELED", "REJECTED"},
+		schemas["OrderResp"].(map[string]interface{})["properties"].(map[string]interface{})["status"].(map[string]interface{})["enum"])
+	assert.NotEmpty(t, c.validate(schemas["OrderResp"].(map[string]interface{}), map[string]interface{}{"order_id": "o1"}, "OrderResp"))
+
+	// sides, order types and times in force are strings
+	properties := schemas["OrderReq"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"BUY", "SELL"}}, properties["buy_or_sell"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"LIMIT"}}, properties["order_type"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"GTC", "IOC", "FOK"}}, properties["time_in_force"])
+
+	_, op := c.operation("POST", "/v1/users/userId1/orders")
+	assert.Equal(t, "createOrder", op["operationId"])
+	assert.NotNil(t, op["security"])
+	assert.Contains(t, op["responses"], "422")
+	assert.Contains(t, op["responses"], "429")
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tickers", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+
+	// unversioned routes are deprecated
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tickers", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v2/tickers", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"stockexchange/store"
+)
+
+// RateLimit is a middleware rejecting requests over the rate limit of their user and endpoint with 429 Too Many Requests.
+// It must run after authentication.
+func (s *Server) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId, client := getRateLimitClient(r)
+		allowed, retryAfter := s.RateLimiter.Allow(userId, client, getEndpoint(r))
+		if !allowed {
+			tooManyRequests(w, retryAfter, "rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getRateLimitClient returns the user a request is attributed to, if any, and the client the request is limited as.
+// Requests are attributed to the authenticated user, or to the path's user when authentication is disabled,
+// and otherwise limited by the client's ip address.
+func getRateLimitClient(r *http.Request) (store.UserId, string) {
+	userId := getPrincipal(r).UserId
+	if userId == "" && getPrincipal(r).Role == "" {
+		userId = store.UserId(mux.Vars(r)["userId"])
+	}
+	if userId != "" {
+		return userId, "user:" + string(userId)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "", "ip:" + host
+}
+
+// getEndpoint returns the method and path template of the route matching a request, e.g "POST /users/{userId}/orders".
+// Versioned and unversioned routes are the same endpoint, so they share their limits.
+func getEndpoint(r *http.Request) string {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			path = strings.TrimPrefix(template, apiVersion)
+		}
+	}
+	return r.Method + " " + path
+}
+
+// tooManyRequests replies with 429 Too Many Requests and a Retry-After header in whole seconds
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/engine"
+)
+
+func TestRateLimiter_Limit(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	s.RateLimiter = engine.NewRateLimiter(engine.RateLimitConfig{
+		Tiers:       map[string]engine.RateLimitTier{"default": {RateLimit: engine.RateLimit{RequestsPerSecond: 0.5, Burst: 1}}},
+		DefaultTier: "default",
+	})
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tickers", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tickers", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// versioned and unversioned routes share their limits
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tickers", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// other clients have their own buckets
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/userId1/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCreateOrderHandler_MaxOpenOrders(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	s.RateLimiter = engine.NewRateLimiter(engine.RateLimitConfig{
+		Tiers:       map[string]engine.RateLimitTier{"default": {RateLimit: engine.RateLimit{RequestsPerSecond: 100, Burst: 100}, MaxOpenOrders: 2}},
+		DefaultTier: "default",
+	})
+	router := NewRouter(s, NewAuthenticator(nil))
+
+	createOrder := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := `{"asset_id": "COIN", "buy_or_sell": "BUY", "size": 1, "limit": 100}`
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/users/userId1/orders", strings.NewReader(body)))
+		s.Flush()
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, createOrder().Code)
+	assert.Equal(t, http.StatusOK, createOrder().Code)
+
+	w := createOrder()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, 2, s.Store.CountOpenOrders(userId1))
+}
@@ -0,0 +1,356 @@
+// Package api serves the http api of an exchange. It's a thin adapter over an engine.OrderMatchingService:
+// handlers decode requests, call the service and encode its results, with authentication, rate limiting,
+// request logs and metrics as middlewares.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+// apiVersion prefixes the routes of the current version of the api
+const apiVersion = "/v1"
+
+// Server serves the http api of an exchange, its handlers are thin adapters translating requests to the
+// calls of the matching service and its results to responses
+type Server struct {
+	*engine.OrderMatchingService
+	openAPI      []byte // OpenAPI document of the api
+	numericSides bool   // sides are accepted and returned as 0 and 1, see WithNumericSides
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithNumericSides makes the api accept and return the sides of orders as 0 for a buy and 1 for a sell,
+// for the clients of the numeric sides of earlier versions. BUY and SELL are still accepted.
+func WithNumericSides() Option {
+	return func(s *Server) {
+		s.numericSides = true
+	}
+}
+
+// access is who can call a route
+type access int
+
+const (
+	publicAccess access = iota // anyone, e.g market data
+	userAccess                 // the keys of the path's user and admin keys
+	adminAccess                // admin keys
+)
+
+// route is an endpoint of the api. Routes are served under apiVersion and documented in the OpenAPI document.
+type route struct {
+	method    string
+	path      string // path template, relative to apiVersion
+	access    access
+	handler   http.HandlerFunc
+	summary   string
+	params    []param     // query and header params
+	request   interface{} // value of the type of the JSON body of the request, nil if there is none
+	responses []response  // success and error responses, the ones of authentication and rate limiting are added
+}
+
+// param is a query or header param of a route
+type param struct {
+	name        string
+	in          string // query or header
+	schema      schema
+	description string
+}
+
+// response is a response of a route
+type response struct {
+	code        int
+	body        interface{} // value of the type of the JSON body, nil if there is none, or a plain text error
+	contentType string      // content type of a body that isn't JSON, e.g a stream
+}
+
+// NewRouter returns the router of the exchange's http api.
+// Market data is public, user routes can only be used by the user's api keys and admin routes by admin keys.
+// Every route is rate limited, except for the metrics scraped by monitoring.
+// Routes are served under /v1, and without a version for existing clients.
+func NewRouter(service *engine.OrderMatchingService, auth *Authenticator, options ...Option) *mux.Router {
+	s := &Server{OrderMatchingService: service}
+	for _, option := range options {
+		option(s)
+	}
+	routes := s.routes()
+	s.openAPI, _ = json.Marshal(newOpenAPIDoc(routes))
+
+	r := mux.NewRouter()
+	r.Use(s.LogRequests, s.Instrument)
+	r.HandleFunc("/metrics", s.MetricsHandler).Methods("GET")
+
+	for _, prefix := range []string{apiVersion, ""} {
+		public := r.NewRoute().Subrouter()
+		public.Use(s.RateLimit)
+		users := r.NewRoute().Subrouter()
+		users.Use(auth.Authenticate, auth.RequireUser, s.RateLimit)
+		admin := r.NewRoute().Subrouter()
+		admin.Use(auth.Authenticate, auth.RequireAdmin, s.RateLimit)
+		if prefix == "" {
+			for _, sub := range []*mux.Router{public, users, admin} {
+				sub.Use(deprecated)
+			}
+		} else {
+			public.HandleFunc(prefix+"/openapi.json", s.OpenAPIHandler).Methods("GET")
+		}
+
+		subrouters := map[access]*mux.Router{publicAccess: public, userAccess: users, adminAccess: admin}
+		for _, rt := range routes {
+			subrouters[rt.access].HandleFunc(prefix+rt.path, rt.handler).Methods(rt.method)
+		}
+	}
+	return r
+}
+
+// deprecated is a middleware flagging the responses of unversioned routes as deprecated
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routes returns the routes of the api, public routes first
+func (s *Server) routes() []route {
+	assetFilter := []param{
+		{name: "asset_id", in: "query", schema: schema{Type: "string"}, description: "only orders of the asset"},
+		{name: "side", in: "query", schema: schema{Type: "string", Enum: []interface{}{"BUY", "SELL"}}, description: "only orders of the side"},
+	}
+	timeRange := []param{
+		{name: "from", in: "query", schema: schema{Type: "string"}, description: "inclusive start, an RFC3339 time or unix seconds"},
+		{name: "to", in: "query", schema: schema{Type: "string"}, description: "exclusive end, an RFC3339 time or unix seconds"},
+	}
+	limit := param{name: "limit", in: "query", schema: schema{Type: "integer", Minimum: 1, Maximum: maxPageLimit}, description: "max number of items, 100 by default"}
+
+	return []route{
+		{
+			method: "GET", path: "/assets/{assetId}/auction", access: publicAccess, handler: s.GetAuctionHandler,
+			summary:   "Get the indicative price, volume and imbalance of an asset's auction",
+			responses: []response{{code: http.StatusOK, body: AuctionResp{}}, {code: http.StatusNotFound}, {code: http.StatusConflict}},
+		},
+		{
+			method: "GET", path: "/assets/{assetId}/phase", access: publicAccess, handler: s.GetPhaseHandler,
+			summary:   "Get the trading phase of an asset",
+			responses: []response{{code: http.StatusOK, body: PhaseResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "GET", path: "/assets/{assetId}/status", access: publicAccess, handler: s.GetAssetStatusHandler,
+			summary:   "Get the trading phase, price band and halt and resume events of an asset",
+			responses: []response{{code: http.StatusOK, body: AssetStatusResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "GET", path: "/assets/{assetId}/candles", access: publicAccess, handler: s.GetCandlesHandler,
+			summary: "Get the candles of an asset for an interval and time range",
+			params: append([]param{
+				{name: "interval", in: "query", schema: schema{Type: "string", Enum: []interface{}{"1m", "5m", "1h", "1d"}}, description: "1m by default"},
+			}, timeRange...),
+			responses: []response{{code: http.StatusOK, body: []CandleResp{}}, {code: http.StatusBadRequest}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "GET", path: "/assets/{assetId}/ticker", access: publicAccess, handler: s.GetTickerHandler,
+			summary:   "Get the last price, best bid and ask and 24h statistics of an asset",
+			responses: []response{{code: http.StatusOK, body: TickerResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "GET", path: "/assets/{assetId}/depth", access: publicAccess, handler: s.GetDepthHandler,
+			summary: "Get the price levels of an asset's order book, best price first",
+			params: []param{
+				{name: "levels", in: "query", schema: schema{Type: "integer", Minimum: 1, Maximum: maxPageLimit}, description: "max number of levels of each side, 10 by default"},
+			},
+			responses: []response{{code: http.StatusOK, body: DepthResp{}}, {code: http.StatusBadRequest}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "GET", path: "/tickers", access: publicAccess, handler: s.GetTickersHandler,
+			summary:   "Get the ticker of every traded asset, the listed assets once instruments are listed",
+			responses: []response{{code: http.StatusOK, body: []TickerResp{}}},
+		},
+
+		{
+			method: "POST", path: "/users/{userId}/orders", access: userAccess, handler: s.CreateOrderHandler,
+			summary: "Place an order. A retry with the client_order_id of an open order returns the open order",
+			request: engine.OrderReq{},
+			responses: []response{
+				{code: http.StatusOK, body: OrderResp{}},
+				{code: http.StatusBadRequest},
+				{code: http.StatusConflict},
+				{code: http.StatusUnprocessableEntity, body: RiskRejectionResp{}},
+				{code: http.StatusServiceUnavailable},
+			},
+		},
+		{
+			method: "GET", path: "/users/{userId}/orders", access: userAccess, handler: s.GetOrdersHandler,
+			summary: "Get a page of a user's orders, the cursor of the next page is returned in the X-Next-Cursor header",
+			params: append(append([]param{
+				{name: "status", in: "query", schema: schema{Type: "string", Enum: []interface{}{engine.ActiveOrders, engine.CompleteOrders, engine.CanceledOrders, engine.PartiallyFilledOrders, engine.RejectedOrders, engine.AllOrders}}, description: "active by default"},
+			}, assetFilter...), append(timeRange,
+				limit,
+				param{name: "cursor", in: "query", schema: schema{Type: "string"}, description: "cursor of the page, from the X-Next-Cursor header"},
+				param{name: "sort", in: "query", schema: schema{Type: "string", Enum: []interface{}{"asc", "desc"}}, description: "by creation time, asc by default"},
+			)...),
+			responses: []response{{code: http.StatusOK, body: []OrderResp{}}, {code: http.StatusBadRequest}},
+		},
+		{
+			method: "DELETE", path: "/users/{userId}/orders", access: userAccess, handler: s.CancelOrdersHandler,
+			summary:   "Cancel a user's working orders, optionally only of an asset and side",
+			params:    assetFilter,
+			responses: []response{{code: http.StatusOK, body: []OrderResp{}}, {code: http.StatusBadRequest}},
+		},
+		{
+			method: "GET", path: "/users/{userId}/orders/{orderId}", access: userAccess, handler: s.GetOrderHandler,
+			summary:   "Get a user's order with its fills",
+			responses: []response{{code: http.StatusOK, body: OrderDetailResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "DELETE", path: "/users/{userId}/orders/{orderId}", access: userAccess, handler: s.CancelOrderHandler,
+			summary:   "Cancel a user's order, the unfilled part of the order is given back",
+			responses: []response{{code: http.StatusNoContent}},
+		},
+		{
+			method: "PATCH", path: "/users/{userId}/orders/{orderId}", access: userAccess, handler: s.AmendOrderHandler,
+			summary: "Amend the limit or size of a working order, it's replaced by a new order",
+			request: engine.AmendOrderReq{},
+			responses: []response{
+				{code: http.StatusOK, body: OrderResp{}},
+				{code: http.StatusBadRequest},
+				{code: http.StatusNotFound},
+				{code: http.StatusConflict},
+				{code: http.StatusUnprocessableEntity, body: RiskRejectionResp{}},
+				{code: http.StatusServiceUnavailable},
+			},
+		},
+		{
+			method: "GET", path: "/users/{userId}/client-orders/{clientOrderId}", access: userAccess, handler: s.GetClientOrderHandler,
+			summary:   "Get a user's latest order with a client order id",
+			responses: []response{{code: http.StatusOK, body: OrderDetailResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "DELETE", path: "/users/{userId}/client-orders/{clientOrderId}", access: userAccess, handler: s.CancelClientOrderHandler,
+			summary:   "Cancel a user's latest order with a client order id",
+			responses: []response{{code: http.StatusNoContent}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "GET", path: "/users/{userId}/trades", access: userAccess, handler: s.GetTradesHandler,
+			summary:   "Get the latest fills of a user's orders, newest first",
+			params:    append(assetFilter, limit),
+			responses: []response{{code: http.StatusOK, body: []UserTradeResp{}}, {code: http.StatusBadRequest}},
+		},
+		{
+			method: "POST", path: "/users/{userId}/transfers", access: userAccess, handler: s.CreateTransferHandler,
+			summary: "Withdraw cash or an asset, deposits are made by admins. A retry with the same idempotency key returns the original transfer with a 200",
+			params: []param{
+				{name: "Idempotency-Key", in: "header", schema: schema{Type: "string"}, description: "unique key of the transfer, instead of idempotency_key"},
+			},
+			request: engine.TransferReq{},
+			responses: []response{
+				{code: http.StatusCreated, body: TransferResp{}},
+				{code: http.StatusOK, body: TransferResp{}},
+				{code: http.StatusBadRequest},
+				{code: http.StatusNotFound},
+				{code: http.StatusConflict},
+			},
+		},
+		{
+			method: "GET", path: "/users/{userId}/transfers", access: userAccess, handler: s.GetTransfersHandler,
+			summary:   "Get a user's deposits and withdrawals, oldest first",
+			responses: []response{{code: http.StatusOK, body: []TransferResp{}}},
+		},
+		{
+			method: "GET", path: "/users/{userId}/balances", access: userAccess, handler: s.GetBalancesHandler,
+			summary:   "Get a user's available cash and assets and what their open orders reserve",
+			responses: []response{{code: http.StatusOK, body: BalancesResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "GET", path: "/users/{userId}/stream", access: userAccess, handler: s.StreamHandler,
+			summary: "Stream a user's trades as server-sent events, with a trade event per trade",
+			params: append([]param{
+				{name: "cancel_on_disconnect", in: "query", schema: schema{Type: "boolean"}, description: "cancel the user's working orders, optionally only of an asset and side, when the stream disconnects"},
+			}, assetFilter...),
+			responses: []response{{code: http.StatusOK, contentType: "text/event-stream"}, {code: http.StatusBadRequest}},
+		},
+
+		{
+			method: "POST", path: "/users", access: adminAccess, handler: s.InitExchangeHandler,
+			summary:   "Initialise the exchange with users and their cash and assets, existing users are left unchanged",
+			request:   []store.InitExchangeReq{},
+			responses: []response{{code: http.StatusOK, body: struct{}{}}, {code: http.StatusBadRequest}},
+		},
+		{
+			method: "POST", path: "/admin/users", access: adminAccess, handler: s.CreateUserHandler,
+			summary: "Create a user, an existing user is returned unchanged with a 200",
+			request: store.InitExchangeReq{},
+			responses: []response{
+				{code: http.StatusCreated, body: UserResp{}},
+				{code: http.StatusOK, body: UserResp{}},
+				{code: http.StatusBadRequest},
+			},
+		},
+		{
+			method: "GET", path: "/admin/users", access: adminAccess, handler: s.GetUsersHandler,
+			summary:   "List all users with their status, cash, assets and number of open orders",
+			responses: []response{{code: http.StatusOK, body: []UserResp{}}},
+		},
+		{
+			method: "GET", path: "/admin/users/{userId}", access: adminAccess, handler: s.GetUserHandler,
+			summary:   "Get a user with all their orders",
+			responses: []response{{code: http.StatusOK, body: UserResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "DELETE", path: "/admin/users/{userId}", access: adminAccess, handler: s.DeleteUserHandler,
+			summary:   "Delete a user without cash, assets and open orders",
+			responses: []response{{code: http.StatusNoContent}, {code: http.StatusNotFound}, {code: http.StatusConflict}},
+		},
+		{
+			method: "POST", path: "/admin/users/{userId}/transfers", access: adminAccess, handler: s.CreateAdminTransferHandler,
+			summary: "Deposit or withdraw cash or an asset for a user. A retry with the same idempotency key returns the original transfer with a 200",
+			params: []param{
+				{name: "Idempotency-Key", in: "header", schema: schema{Type: "string"}, description: "unique key of the transfer, instead of idempotency_key"},
+			},
+			request: engine.TransferReq{},
+			responses: []response{
+				{code: http.StatusCreated, body: TransferResp{}},
+				{code: http.StatusOK, body: TransferResp{}},
+				{code: http.StatusBadRequest},
+				{code: http.StatusNotFound},
+				{code: http.StatusConflict},
+			},
+		},
+		{
+			method: "POST", path: "/admin/users/{userId}/suspend", access: adminAccess, handler: s.SuspendUserHandler,
+			summary:   "Stop a user from placing orders and cancel their open orders",
+			responses: []response{{code: http.StatusOK, body: UserResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "POST", path: "/admin/users/{userId}/resume", access: adminAccess, handler: s.ResumeUserHandler,
+			summary:   "Let a suspended user trade again",
+			responses: []response{{code: http.StatusOK, body: UserResp{}}, {code: http.StatusNotFound}},
+		},
+		{
+			method: "POST", path: "/assets/{assetId}/auction", access: adminAccess, handler: s.StartAuctionHandler,
+			summary:   "Start the call period of an auction for an asset",
+			responses: []response{{code: http.StatusOK, body: struct{}{}}, {code: http.StatusConflict}},
+		},
+		{
+			method: "POST", path: "/assets/{assetId}/auction/uncross", access: adminAccess, handler: s.UncrossAuctionHandler,
+			summary:   "Uncross an asset's auction at its equilibrium price and resume continuous trading",
+			responses: []response{{code: http.StatusOK, body: AuctionResp{}}, {code: http.StatusConflict}},
+		},
+		{
+			method: "PUT", path: "/assets/{assetId}/phase", access: adminAccess, handler: s.SetPhaseHandler,
+			summary:   "Move an asset to a new trading phase",
+			request:   PhaseReq{},
+			responses: []response{{code: http.StatusOK, body: PhaseResp{}}, {code: http.StatusBadRequest}, {code: http.StatusConflict}},
+		},
+		{
+			method: "DELETE", path: "/admin/assets/{assetId}/orders", access: adminAccess, handler: s.CancelAssetOrdersHandler,
+			summary:   "Halt an asset and cancel every order in its order book",
+			responses: []response{{code: http.StatusOK, body: []OrderResp{}}},
+		},
+	}
+}
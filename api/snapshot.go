@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"stockexchange/engine"
+)
+
+// Snapshot is the state of every user of the exchange, it's written to a file on shutdown
+type Snapshot struct {
+	TakenAt time.Time  `json:"taken_at"` // time the snapshot was taken
+	LastSeq uint64     `json:"last_seq"` // sequence number of the last command or event in the snapshot
+	Users   []UserResp `json:"users"`    // cash, assets and orders of every user, sorted by user id
+}
+
+// TakeSnapshot returns the state of every user of an exchange
+func TakeSnapshot(s *engine.OrderMatchingService) Snapshot {
+	snapshot := Snapshot{TakenAt: s.Now(), LastSeq: s.LastSeq(), Users: []UserResp{}}
+	for _, userId := range s.Store.GetUserIds() {
+		snapshot.Users = append(snapshot.Users, userDataToUserResp(s.Store.GetUserData(userId), s.Store.CountOpenOrders(userId), true))
+	}
+	return snapshot
+}
+
+// WriteSnapshot writes a snapshot to a JSON file.
+// It's written to a temporary file first, so an existing snapshot is only replaced by a complete one.
+func WriteSnapshot(path string, snapshot Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
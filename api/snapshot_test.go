@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	s := engine.NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	s.SubmitOrder(engine.OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.Flush()
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	assert.NoError(t, WriteSnapshot(path, TakeSnapshot(s)))
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	var snapshot Snapshot
+	assert.NoError(t, json.Unmarshal(data, &snapshot))
+	assert.Equal(t, 3*uint64(store.EventsPerCommand), snapshot.LastSeq) // 2 users and an order
+	assert.Equal(t, 2, len(snapshot.Users))
+	assert.Equal(t, userId1, snapshot.Users[0].UserId)
+	assert.Equal(t, store.Usd(9000), snapshot.Users[0].Cash)
+	assert.Equal(t, 1, snapshot.Users[0].OpenOrders)
+	assert.Equal(t, 1, len(snapshot.Users[0].Orders))
+}
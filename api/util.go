@@ -0,0 +1,344 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"stockexchange/book"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+// parseSideParam parses a side query param, BUY or SELL, or 0 or 1 if numeric.
+// It returns nil if the param is empty.
+func parseSideParam(value string, numeric bool) (*store.BuyOrSell, error) {
+	var side store.BuyOrSell
+	switch {
+	case value == "":
+		return nil, nil
+	case value == "BUY", value == "0" && numeric:
+		side = store.BUY
+	case value == "SELL", value == "1" && numeric:
+		side = store.SELL
+	case numeric:
+		return nil, fmt.Errorf("invalid side %s, must be BUY, SELL, 0 or 1", value)
+	default:
+		return nil, fmt.Errorf("invalid side %s, must be BUY or SELL", value)
+	}
+	return &side, nil
+}
+
+// sideKey is the JSON key of the side of an order in requests and responses
+const sideKey = "buy_or_sell"
+
+// sideNames and sideNumbers are the values of the sides of orders, BUY and SELL, or 0 and 1 for numeric sides
+var (
+	sideNames   = map[interface{}]interface{}{json.Number("0"): "BUY", json.Number("1"): "SELL"}
+	sideNumbers = map[interface{}]interface{}{"BUY": json.Number("0"), "SELL": json.Number("1")}
+)
+
+// rewriteSides replaces the sides of orders in a JSON value decoded with json.Number numbers,
+// with the values of sides, e.g sideNames to accept numeric sides. Other values are left for the decoding to reject.
+func rewriteSides(value interface{}, sides map[interface{}]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			switch elem.(type) {
+			case string, json.Number:
+				if side, ok := sides[elem]; ok && key == sideKey {
+					v[key] = side
+				}
+			default:
+				rewriteSides(elem, sides)
+			}
+		}
+	case []interface{}:
+		for _, elem := range v {
+			rewriteSides(elem, sides)
+		}
+	}
+}
+
+// toJSONValue returns the JSON value of v, with json.Number numbers
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return value, decoder.Decode(&value)
+}
+
+func orderToOrderResp(order store.Order) OrderResp {
+	return OrderResp{
+		OrderId:       order.OrderId,
+		ClientOrderId: order.ClientOrderId,
+		UserId:        order.UserId,
+		Limit:         order.Limit,
+		AssetId:       order.AssetId,
+		Size:          order.Size,
+		BuyOrSell:     order.BuyOrSell,
+		OrderType:     order.OrderType,
+		TimeInForce:   order.TimeInForce,
+		EventAt:       order.EventAt,
+		Status:        order.Status,
+		Filled:        order.Filled,
+		Seq:           order.Seq,
+		UpdateSeq:     order.UpdateSeq,
+	}
+}
+
+func userDataToUserResp(userData store.UserData, openOrders int, withOrders bool) UserResp {
+	resp := UserResp{
+		UserId:     userData.UserId,
+		Status:     userData.Status,
+		Cash:       userData.Cash,
+		Assets:     []store.Asset{},
+		OpenOrders: openOrders,
+	}
+	for assetId, size := range userData.Assets {
+		resp.Assets = append(resp.Assets, store.Asset{AssetId: assetId, Size: size})
+	}
+	sort.Slice(resp.Assets, func(i, j int) bool { return resp.Assets[i].AssetId < resp.Assets[j].AssetId })
+
+	if withOrders {
+		resp.Orders = []OrderResp{}
+		for _, order := range userData.Orders {
+			resp.Orders = append(resp.Orders, orderToOrderResp(order))
+		}
+		sort.Slice(resp.Orders, func(i, j int) bool { return resp.Orders[i].EventAt.Before(resp.Orders[j].EventAt) })
+	}
+	return resp
+}
+
+func transferToTransferResp(transfer store.Transfer) TransferResp {
+	return TransferResp{
+		TransferId:     transfer.TransferId,
+		UserId:         transfer.UserId,
+		Type:           transfer.TransferType,
+		AssetId:        transfer.AssetId,
+		Amount:         transfer.Amount,
+		IdempotencyKey: transfer.IdempotencyKey,
+		EventAt:        transfer.EventAt,
+		Seq:            transfer.Seq,
+	}
+}
+
+func balancesToBalancesResp(userData store.UserData, reservedCash store.Usd, reservedAssets map[store.AssetId]int) BalancesResp {
+	resp := BalancesResp{
+		UserId:       userData.UserId,
+		Cash:         userData.Cash,
+		ReservedCash: reservedCash,
+		Assets:       []AssetBalanceResp{},
+	}
+	for assetId, size := range userData.Assets {
+		resp.Assets = append(resp.Assets, AssetBalanceResp{AssetId: assetId, Available: size, Reserved: reservedAssets[assetId]})
+	}
+	for assetId, size := range reservedAssets {
+		if _, ok := userData.Assets[assetId]; !ok {
+			resp.Assets = append(resp.Assets, AssetBalanceResp{AssetId: assetId, Reserved: size})
+		}
+	}
+	sort.Slice(resp.Assets, func(i, j int) bool { return resp.Assets[i].AssetId < resp.Assets[j].AssetId })
+	return resp
+}
+
+func orderToOrderDetailResp(order store.Order, fills []store.Fill) OrderDetailResp {
+	resp := OrderDetailResp{
+		OrderResp: orderToOrderResp(order),
+		Fills:     []FillResp{},
+		Reason:    order.Reason,
+	}
+	if order.Status == store.Working {
+		resp.Remaining = order.Size - order.Filled
+	}
+
+	notional, size := store.Usd(0), 0
+	for _, fill := range fills {
+		resp.Fills = append(resp.Fills, FillResp{Price: fill.Price, Size: fill.Size, ExecutedAt: fill.ExecutedAt, Fee: fill.Fee})
+		notional += store.GetTotalAssetCost(fill.Price, fill.Size)
+		size += fill.Size
+	}
+	if size > 0 {
+		resp.AvgFillPrice = float64(notional) / float64(size)
+	}
+	return resp
+}
+
+// orderReqToOrderResp returns the OrderResp of an accepted order request that may not be processed yet
+func orderReqToOrderResp(or engine.OrderReq) OrderResp {
+	return OrderResp{
+		OrderId:       or.OrderId,
+		ClientOrderId: or.ClientOrderId,
+		UserId:        or.UserId,
+		Limit:         or.Limit,
+		AssetId:       or.AssetId,
+		Size:          or.Size,
+		BuyOrSell:     or.BuyOrSell,
+		OrderType:     or.OrderType,
+		TimeInForce:   or.TimeInForce,
+		Status:        store.Working,
+	}
+}
+
+func ordersToOrderResps(orders []store.Order) []OrderResp {
+	resp := []OrderResp{}
+	for _, order := range orders {
+		resp = append(resp, orderToOrderResp(order))
+	}
+	return resp
+}
+
+func tradeToTradeResp(trade store.Trade) TradeResp {
+	return TradeResp{
+		AssetId:     trade.AssetId,
+		Price:       trade.Price,
+		Size:        trade.Size,
+		BuyOrderId:  trade.BuyOrderId,
+		SellOrderId: trade.SellOrderId,
+		BuyerId:     trade.BuyerId,
+		SellerId:    trade.SellerId,
+		ExecutedAt:  trade.ExecutedAt,
+		Seq:         trade.Seq,
+	}
+}
+
+func userTradeToUserTradeResp(trade engine.UserTrade) UserTradeResp {
+	return UserTradeResp{
+		OrderId:       trade.Order.OrderId,
+		ClientOrderId: trade.Order.ClientOrderId,
+		AssetId:       trade.Order.AssetId,
+		BuyOrSell:     trade.Order.BuyOrSell,
+		Price:         trade.Fill.Price,
+		Size:          trade.Fill.Size,
+		Fee:           trade.Fill.Fee,
+		ExecutedAt:    trade.Fill.ExecutedAt,
+	}
+}
+
+func depthToDepthResp(assetId store.AssetId, phase book.TradingPhase, bids []book.PriceLevel, asks []book.PriceLevel) DepthResp {
+	resp := DepthResp{AssetId: assetId, Phase: phase, Bids: []PriceLevelResp{}, Asks: []PriceLevelResp{}}
+	for _, level := range bids {
+		resp.Bids = append(resp.Bids, PriceLevelResp(level))
+	}
+	for _, level := range asks {
+		resp.Asks = append(resp.Asks, PriceLevelResp(level))
+	}
+	return resp
+}
+
+func auctionResultToAuctionResp(assetId store.AssetId, phase book.TradingPhase, result book.AuctionResult) AuctionResp {
+	return AuctionResp{
+		AssetId:   assetId,
+		Phase:     phase,
+		Price:     result.Price,
+		Volume:    result.Volume,
+		Imbalance: result.Imbalance,
+	}
+}
+
+func phaseTransitionToPhaseResp(transition book.PhaseTransition) PhaseResp {
+	resp := PhaseResp{
+		AssetId: transition.AssetId,
+		Phase:   transition.To,
+	}
+	if transition.Auction != nil {
+		auctionResp := auctionResultToAuctionResp(transition.AssetId, transition.To, *transition.Auction)
+		resp.Auction = &auctionResp
+	}
+	return resp
+}
+
+func assetStatusToAssetStatusResp(status book.AssetStatus, events []engine.MarketEvent) AssetStatusResp {
+	resp := AssetStatusResp{
+		AssetId:  status.AssetId,
+		Phase:    status.Phase,
+		RefPrice: status.RefPrice,
+		BandLow:  status.Band.Low,
+		BandHigh: status.Band.High,
+		Events:   []MarketEventResp{},
+	}
+	if !status.HaltedAt.IsZero() {
+		resp.HaltedAt = &status.HaltedAt
+		resp.ResumeAt = &status.ResumeAt
+	}
+	for _, e := range events {
+		resp.Events = append(resp.Events, MarketEventResp{
+			Type:     e.Type,
+			AssetId:  e.AssetId,
+			Phase:    e.Phase,
+			RefPrice: e.RefPrice,
+			Seq:      e.Seq,
+			EventAt:  e.EventAt,
+		})
+	}
+	return resp
+}
+
+func candleToCandleResp(candle engine.Candle) CandleResp {
+	return CandleResp{
+		Start:  candle.Start,
+		Open:   candle.Open,
+		High:   candle.High,
+		Low:    candle.Low,
+		Close:  candle.Close,
+		Volume: candle.Volume,
+		VWAP:   candle.VWAP(),
+		Trades: candle.Trades,
+	}
+}
+
+// parseLimitParam parses the query param name limiting the number of items returned, def if it's empty
+func parseLimitParam(name string, value string, def int, max int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > max {
+		return 0, fmt.Errorf("%s must be between 1 and %d", name, max)
+	}
+	return limit, nil
+}
+
+// parseTimeParam parses a query parameter given as an RFC3339 time or unix seconds.
+// It returns def if the parameter is empty.
+func parseTimeParam(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or unix seconds", value)
+	}
+	return t, nil
+}
+
+func tickerToTickerResp(stats engine.TickerStats, bestBid store.Order, bestAsk store.Order) TickerResp {
+	resp := TickerResp{
+		AssetId:          stats.AssetId,
+		LastPrice:        stats.LastPrice,
+		BestBid:          bestBid.Limit,
+		BestBidSize:      bestBid.Size,
+		BestAsk:          bestAsk.Limit,
+		BestAskSize:      bestAsk.Size,
+		High24h:          stats.High,
+		Low24h:           stats.Low,
+		Volume24h:        stats.Volume,
+		Change24h:        stats.Change(),
+		ChangePercent24h: stats.ChangePercent(),
+		Trades24h:        stats.Trades,
+	}
+	if !stats.LastTradeAt.IsZero() {
+		resp.LastTradeAt = &stats.LastTradeAt
+	}
+	return resp
+}
@@ -0,0 +1,179 @@
+package book
+
+import (
+	"sort"
+
+	"stockexchange/store"
+)
+
+// Call auctions
+//
+// While an order book is in the Auction phase, incoming orders are added to the book without being matched.
+// At the end of the call period the book is uncrossed: a single equilibrium price is computed and every
+// crossing order is filled at that price, after which the book moves back to continuous trading.
+//
+// The equilibrium price is picked among the limit prices present in the book using the standard tie-breaks
+// 1. the price that maximizes executable volume
+// 2. the price that minimizes the imbalance (unmatched volume) at that price
+// 3. market pressure: highest price if there is a buy surplus at every remaining price, lowest if a sell surplus
+// 4. the price closest to the reference price, or to the middle of the remaining prices if there is none
+
+// AuctionResult represents the outcome of an equilibrium price calculation for an order book
+type AuctionResult struct {
+	Price     store.Usd // equilibrium price, in Usd cents
+	Volume    int       // number of assets executable at the equilibrium price
+	Imbalance int       // unmatched buy(+) or sell(-) volume at the equilibrium price
+}
+
+// GetIndicativeAuction returns the price and volume the order book of an asset would uncross at right now.
+// It returns false if no orders in the book cross.
+func (ob *OrderBooks) GetIndicativeAuction(assetId store.AssetId) (AuctionResult, bool) {
+	orderBook := ob.getOrderBook(assetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	return computeEquilibrium(orderBook.BuyList, orderBook.SellList, orderBook.refPrice)
+}
+
+// uncrossOrderBook fills all crossing orders in the order book at the equilibrium price.
+// It returns false if no orders cross. Callers must hold the order book lock.
+func uncrossOrderBook(orderBook *OrderBook, store *store.Store, cmd *store.Command) (AuctionResult, bool) {
+	result, ok := computeEquilibrium(orderBook.BuyList, orderBook.SellList, orderBook.refPrice)
+	if ok {
+		uncrossOrders(orderBook, result, store, cmd)
+		orderBook.refPrice = result.Price
+	}
+	return result, ok
+}
+
+// uncrossOrders executes the auction result volume against the top of the buy and sell lists.
+// Since both lists are sorted by price-time priority, the top orders are always the crossing ones.
+func uncrossOrders(orderBook *OrderBook, result AuctionResult, s *store.Store, cmd *store.Command) {
+	for remaining := result.Volume; remaining > 0; {
+		buyOrder := orderBook.BuyList.GetTopOrder()
+		sellOrder := orderBook.SellList.GetTopOrder()
+
+		tradeAssetsSize := min(remaining, min(buyOrder.Size, sellOrder.Size))
+		orderBook.onTrade(store.NewTrade(buyOrder, sellOrder, result.Price, tradeAssetsSize), cmd)
+		fillAuctionOrder(orderBook.BuyList, buyOrder, result.Price, tradeAssetsSize, s)
+		fillAuctionOrder(orderBook.SellList, sellOrder, result.Price, tradeAssetsSize, s)
+
+		remaining -= tradeAssetsSize
+	}
+}
+
+// fillAuctionOrder fills part or all of an order in the list at the auction price and updates the user's assets in the store
+func fillAuctionOrder(orderList *OrdersList, order store.Order, price store.Usd, tradeAssetsSize int, s *store.Store) {
+	order.Size -= tradeAssetsSize
+	if order.Size == 0 {
+		orderList.DeleteOrder(order.OrderId)
+		s.UpdateUserAsset(order, price, tradeAssetsSize, order.BuyOrSell, store.Complete)
+		return
+	}
+
+	orderList.UpdateOrder(order)
+	s.UpdateUserAsset(order, price, tradeAssetsSize, order.BuyOrSell, store.Working)
+}
+
+// computeEquilibrium returns the auction result for the given buy and sell lists.
+// refPrice is used as the last tie-break, a refPrice of 0 means no reference price is available.
+// It returns false if no orders cross.
+func computeEquilibrium(buyList, sellList *OrdersList, refPrice store.Usd) (AuctionResult, bool) {
+	var candidates []AuctionResult
+	for _, price := range getCandidatePrices(buyList, sellList) {
+		buyVolume := getBuyVolumeAt(buyList, price)
+		sellVolume := getSellVolumeAt(sellList, price)
+		volume := min(buyVolume, sellVolume)
+		if volume == 0 {
+			continue
+		}
+
+		result := AuctionResult{Price: price, Volume: volume, Imbalance: buyVolume - sellVolume}
+		if len(candidates) == 0 || result.Volume > candidates[0].Volume {
+			candidates = []AuctionResult{result}
+		} else if result.Volume == candidates[0].Volume {
+			candidates = append(candidates, result)
+		}
+	}
+	if len(candidates) == 0 {
+		return AuctionResult{}, false
+	}
+
+	// keep the prices with the smallest imbalance
+	minImbalance := abs(candidates[0].Imbalance)
+	for _, c := range candidates {
+		minImbalance = min(minImbalance, abs(c.Imbalance))
+	}
+	var balanced []AuctionResult
+	for _, c := range candidates {
+		if abs(c.Imbalance) == minImbalance {
+			balanced = append(balanced, c)
+		}
+	}
+	candidates = balanced
+
+	// candidates are sorted by ascending price
+	lowest, highest := candidates[0], candidates[len(candidates)-1]
+	if allImbalances(candidates, func(imbalance int) bool { return imbalance > 0 }) {
+		return highest, true
+	}
+	if allImbalances(candidates, func(imbalance int) bool { return imbalance < 0 }) {
+		return lowest, true
+	}
+
+	target := refPrice
+	if target == 0 {
+		target = (lowest.Price + highest.Price) / 2
+	}
+	best := candidates[0]
+	for _, c := range candidates {
+		if abs(int(c.Price-target)) < abs(int(best.Price-target)) {
+			best = c
+		}
+	}
+	return best, true
+}
+
+// getCandidatePrices returns the distinct limit prices of both lists in ascending order
+func getCandidatePrices(buyList, sellList *OrdersList) []store.Usd {
+	seen := make(map[store.Usd]bool)
+	var prices []store.Usd
+	for _, list := range []*OrdersList{buyList, sellList} {
+		for t := list.front; t != nil; t = t.next {
+			if !seen[t.val.Limit] {
+				seen[t.val.Limit] = true
+				prices = append(prices, t.val.Limit)
+			}
+		}
+	}
+
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	return prices
+}
+
+// getBuyVolumeAt returns the total size of buy orders willing to buy at the given price
+func getBuyVolumeAt(buyList *OrdersList, price store.Usd) int {
+	volume := 0
+	for t := buyList.front; t != nil && t.val.Limit >= price; t = t.next {
+		volume += t.val.Size
+	}
+	return volume
+}
+
+// getSellVolumeAt returns the total size of sell orders willing to sell at the given price
+func getSellVolumeAt(sellList *OrdersList, price store.Usd) int {
+	volume := 0
+	for t := sellList.front; t != nil && t.val.Limit <= price; t = t.next {
+		volume += t.val.Size
+	}
+	return volume
+}
+
+func allImbalances(results []AuctionResult, pred func(imbalance int) bool) bool {
+	for _, r := range results {
+		if !pred(r.Imbalance) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package book
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestComputeEquilibrium_MaximizesVolume(t *testing.T) {
+	buyList := newOrdersList()
+	buyList.AddOrder(store.Order{OrderId: "b1", Limit: 102, Size: 10, BuyOrSell: store.BUY})
+	buyList.AddOrder(store.Order{OrderId: "b2", Limit: 101, Size: 10, BuyOrSell: store.BUY})
+	buyList.AddOrder(store.Order{OrderId: "b3", Limit: 99, Size: 10, BuyOrSell: store.BUY})
+
+	sellList := newOrdersList()
+	sellList.AddOrder(store.Order{OrderId: "s1", Limit: 100, Size: 15, BuyOrSell: store.SELL})
+	sellList.AddOrder(store.Order{OrderId: "s2", Limit: 101, Size: 5, BuyOrSell: store.SELL})
+	sellList.AddOrder(store.Order{OrderId: "s3", Limit: 103, Size: 10, BuyOrSell: store.SELL})
+
+	result, ok := computeEquilibrium(buyList, sellList, 0)
+
+	assert.True(t, ok)
+	assert.Equal(t, AuctionResult{Price: 101, Volume: 20, Imbalance: 0}, result)
+}
+
+func TestComputeEquilibrium_MinimizesImbalance(t *testing.T) {
+	buyList := newOrdersList()
+	buyList.AddOrder(store.Order{OrderId: "b1", Limit: 102, Size: 10, BuyOrSell: store.BUY})
+	buyList.AddOrder(store.Order{OrderId: "b2", Limit: 100, Size: 5, BuyOrSell: store.BUY})
+
+	sellList := newOrdersList()
+	sellList.AddOrder(store.Order{OrderId: "s1", Limit: 100, Size: 10, BuyOrSell: store.SELL})
+
+	// 10 assets execute at both 100 and 102, but 100 leaves a buy surplus of 5
+	result, ok := computeEquilibrium(buyList, sellList, 0)
+
+	assert.True(t, ok)
+	assert.Equal(t, AuctionResult{Price: 102, Volume: 10, Imbalance: 0}, result)
+}
+
+func TestComputeEquilibrium_MarketPressure(t *testing.T) {
+	buyList := newOrdersList()
+	buyList.AddOrder(store.Order{OrderId: "b1", Limit: 105, Size: 20, BuyOrSell: store.BUY})
+
+	sellList := newOrdersList()
+	sellList.AddOrder(store.Order{OrderId: "s1", Limit: 100, Size: 10, BuyOrSell: store.SELL})
+
+	// buy surplus at every price, equilibrium is the highest price
+	result, ok := computeEquilibrium(buyList, sellList, 0)
+	assert.True(t, ok)
+	assert.Equal(t, AuctionResult{Price: 105, Volume: 10, Imbalance: 10}, result)
+
+	buyList = newOrdersList()
+	buyList.AddOrder(store.Order{OrderId: "b1", Limit: 105, Size: 10, BuyOrSell: store.BUY})
+
+	sellList = newOrdersList()
+	sellList.AddOrder(store.Order{OrderId: "s1", Limit: 100, Size: 20, BuyOrSell: store.SELL})
+
+	// sell surplus at every price, equilibrium is the lowest price
+	result, ok = computeEquilibrium(buyList, sellList, 0)
+	assert.True(t, ok)
+	assert.Equal(t, AuctionResult{Price: 100, Volume: 10, Imbalance: -10}, result)
+}
+
+func TestComputeEquilibrium_ReferencePrice(t *testing.T) {
+	buyList := newOrdersList()
+	buyList.AddOrder(store.Order{OrderId: "b1", Limit: 110, Size: 10, BuyOrSell: store.BUY})
+
+	sellList := newOrdersList()
+	sellList.AddOrder(store.Order{OrderId: "s1", Limit: 100, Size: 10, BuyOrSell: store.SELL})
+
+	result, ok := computeEquilibrium(buyList, sellList, 109)
+	assert.True(t, ok)
+	assert.Equal(t, store.Usd(110), result.Price)
+
+	result, ok = computeEquilibrium(buyList, sellList, 0)
+	assert.True(t, ok)
+	assert.Equal(t, store.Usd(100), result.Price) // closest to the middle of 100 and 110, lowest price on ties
+}
+
+func TestComputeEquilibrium_NoCross(t *testing.T) {
+	buyList := newOrdersList()
+	buyList.AddOrder(store.Order{OrderId: "b1", Limit: 99, Size: 10, BuyOrSell: store.BUY})
+
+	sellList := newOrdersList()
+	sellList.AddOrder(store.Order{OrderId: "s1", Limit: 100, Size: 10, BuyOrSell: store.SELL})
+
+	_, ok := computeEquilibrium(buyList, sellList, 0)
+	assert.False(t, ok)
+
+	_, ok = computeEquilibrium(newOrdersList(), newOrdersList(), 0)
+	assert.False(t, ok)
+}
+
+func TestOrderBooks_Auction(t *testing.T) {
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	sellOrder2 := store.Order{OrderId: "so2", UserId: userId1, AssetId: assetId1, Limit: 101, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId2, AssetId: assetId1, Limit: 102, Size: 15, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{sellOrder1, sellOrder2}, []store.Order{buyOrder1})
+
+	ob := NewOrderBooks()
+	_, err := ob.SetPhase(assetId1, Auction, s, newCommand())
+	assert.NoError(t, err)
+	assert.Equal(t, Auction, ob.GetPhase(assetId1))
+
+	ob.ExecuteOrder(sellOrder1, s, newCommand())
+	ob.ExecuteOrder(sellOrder2, s, newCommand())
+	ob.ExecuteOrder(buyOrder1, s, newCommand())
+
+	// assert orders accumulated without matching
+	orderBook := ob.OrderBook(assetId1)
+	assert.Equal(t, 2, orderBook.SellList.Len())
+	assert.Equal(t, 1, orderBook.BuyList.Len())
+
+	indicative, ok := ob.GetIndicativeAuction(assetId1)
+	assert.True(t, ok)
+	assert.Equal(t, AuctionResult{Price: 101, Volume: 15, Imbalance: -5}, indicative)
+
+	transition, err := ob.SetPhase(assetId1, Continuous, s, newCommand())
+	assert.NoError(t, err)
+	assert.Equal(t, &indicative, transition.Auction)
+	assert.Equal(t, Continuous, ob.GetPhase(assetId1))
+
+	seller := s.GetUserData(userId1)
+	buyer := s.GetUserData(userId2)
+
+	assert.Equal(t, store.Usd(11515), seller.Cash) // all 15 assets sold at the equilibrium price
+	assert.Equal(t, 115, buyer.Assets[assetId1])
+	assert.Equal(t, store.Usd(10015), buyer.Cash) // the 15 assets reserved at the limit of 102 were bought at 101
+	assert.Equal(t, store.Complete, seller.Orders[sellOrder1.OrderId].Status)
+	assert.Equal(t, store.Working, seller.Orders[sellOrder2.OrderId].Status)
+	assert.Equal(t, 5, seller.Orders[sellOrder2.OrderId].Filled)
+	assert.Equal(t, store.Complete, buyer.Orders[buyOrder1.OrderId].Status)
+
+	assert.Equal(t, 0, orderBook.BuyList.Len())
+	assert.Equal(t, 1, orderBook.SellList.Len())
+	assert.Equal(t, 5, orderBook.SellList.GetTopOrder().Size)
+}
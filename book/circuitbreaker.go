@@ -0,0 +1,78 @@
+package book
+
+import (
+	"time"
+
+	"stockexchange/store"
+)
+
+// Circuit breakers
+//
+// Every order book has a price band around its reference price, the last traded or auction price.
+// If a fill while matching an incoming order would trade outside the band, matching stops, the rest
+// of the order is added to the book and the asset is halted. After the cooldown the asset moves
+// to an auction and is uncrossed at the end of the auction period, resuming continuous trading.
+
+// CircuitBreaker configures the price bands and halts of the order books
+type CircuitBreaker struct {
+	BandBps       int           // max distance of a fill from the reference price, in basis points. 0 disables the price bands
+	Cooldown      time.Duration // how long an asset stays halted before moving to the resumption auction
+	AuctionPeriod time.Duration // how long the resumption auction call period lasts before the asset is uncrossed
+}
+
+var DefaultCircuitBreaker = CircuitBreaker{
+	BandBps:       1000, // 10%
+	Cooldown:      5 * time.Minute,
+	AuctionPeriod: time.Minute,
+}
+
+// PriceBand represents the range of prices an order book can trade at.
+// The zero value allows any price.
+type PriceBand struct {
+	Low  store.Usd
+	High store.Usd
+}
+
+// priceBand returns the price band around a reference price. A reference price of 0 means the asset hasn't traded yet.
+func (cb CircuitBreaker) priceBand(refPrice store.Usd) PriceBand {
+	if cb.BandBps == 0 || refPrice == 0 {
+		return PriceBand{}
+	}
+
+	width := store.Usd(int(refPrice) * cb.BandBps / 10000)
+	return PriceBand{Low: refPrice - width, High: refPrice + width}
+}
+
+// contains returns if price is within the price band
+func (pb PriceBand) contains(price store.Usd) bool {
+	return pb == PriceBand{} || (price >= pb.Low && price <= pb.High)
+}
+
+// AssetStatus represents the trading status of an asset
+type AssetStatus struct {
+	AssetId  store.AssetId
+	Phase    TradingPhase
+	RefPrice store.Usd
+	Band     PriceBand
+	HaltedAt time.Time // zero if the asset is not halted by the circuit breaker
+	ResumeAt time.Time // time the resumption auction starts, zero if the asset is not halted by the circuit breaker
+}
+
+// GetStatus returns the trading status of an asset
+func (ob *OrderBooks) GetStatus(assetId store.AssetId) AssetStatus {
+	orderBook := ob.getOrderBook(assetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	status := AssetStatus{
+		AssetId:  assetId,
+		Phase:    orderBook.phase,
+		RefPrice: orderBook.refPrice,
+		Band:     ob.Breaker.priceBand(orderBook.refPrice),
+	}
+	if orderBook.phase == Halted && !orderBook.haltedAt.IsZero() {
+		status.HaltedAt = orderBook.haltedAt
+		status.ResumeAt = orderBook.haltedAt.Add(ob.Breaker.Cooldown)
+	}
+	return status
+}
@@ -0,0 +1,58 @@
+package book
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestCircuitBreaker_PriceBand(t *testing.T) {
+	cb := CircuitBreaker{BandBps: 500}
+
+	band := cb.priceBand(1000)
+	assert.Equal(t, PriceBand{Low: 950, High: 1050}, band)
+	assert.True(t, band.contains(950))
+	assert.True(t, band.contains(1050))
+	assert.False(t, band.contains(949))
+	assert.False(t, band.contains(1051))
+
+	assert.True(t, cb.priceBand(0).contains(1000000))                  // no reference price yet
+	assert.True(t, CircuitBreaker{}.priceBand(1000).contains(1000000)) // price bands disabled
+}
+
+func TestOrderBooks_ExecuteOrder_PriceBand(t *testing.T) {
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	sellOrder2 := store.Order{OrderId: "so2", UserId: userId1, AssetId: assetId1, Limit: 105, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	sellOrder3 := store.Order{OrderId: "so3", UserId: userId1, AssetId: assetId1, Limit: 120, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId2, AssetId: assetId1, Limit: 100, Size: 5, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	buyOrder2 := store.Order{OrderId: "bo2", UserId: userId2, AssetId: assetId1, Limit: 120, Size: 20, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{sellOrder1, sellOrder2, sellOrder3}, []store.Order{buyOrder1, buyOrder2})
+
+	ob := NewOrderBooks()
+	ob.Breaker = CircuitBreaker{BandBps: 1000}
+	ob.AddOrder(sellOrder1)
+	ob.AddOrder(sellOrder2)
+	ob.AddOrder(sellOrder3)
+
+	assert.False(t, ob.ExecuteOrder(buyOrder1, s, newCommand())) // first trade sets the reference price
+	assert.Equal(t, store.Usd(100), ob.GetStatus(assetId1).RefPrice)
+
+	// buy order walks the book up to 105, the fill at 120 is outside the band of 90-110
+	assert.True(t, ob.ExecuteOrder(buyOrder2, s, newCommand()))
+
+	status := ob.GetStatus(assetId1)
+	assert.Equal(t, Halted, status.Phase)
+	assert.Equal(t, store.Usd(105), status.RefPrice)
+	assert.False(t, status.HaltedAt.IsZero())
+
+	buyer := s.GetUserData(userId2)
+	assert.Equal(t, 15, buyer.Orders[buyOrder2.OrderId].Filled)
+	assert.Equal(t, store.Working, buyer.Orders[buyOrder2.OrderId].Status)
+
+	orderBook := ob.OrderBook(assetId1)
+	assert.Equal(t, 1, orderBook.SellList.Len())
+	assert.Equal(t, 1, orderBook.BuyList.Len()) // remaining buy order rests in the halted book
+	assert.Equal(t, 5, orderBook.BuyList.GetTopOrder().Size)
+}
@@ -0,0 +1,71 @@
+package book
+
+import (
+	"fmt"
+
+	"stockexchange/store"
+)
+
+// FeeRates are the fees charged on the notional of a user's fills, in basis points.
+// The maker fee is charged on fills of orders resting in the book, and on auction fills,
+// the taker fee on fills of orders that matched as they arrived.
+type FeeRates struct {
+	MakerBps int `yaml:"maker_bps"`
+	TakerBps int `yaml:"taker_bps"`
+}
+
+// FeeSchedule configures the fees of every user
+type FeeSchedule struct {
+	Default FeeRates                  `yaml:"default"` // fees of users without fees of their own
+	Users   map[store.UserId]FeeRates `yaml:"users"`   // userId -> fees
+}
+
+// validate returns an error if a fee is negative or over 100%
+func (r FeeRates) validate() error {
+	if r.MakerBps < 0 || r.TakerBps < 0 || r.MakerBps > 10000 || r.TakerBps > 10000 {
+		return fmt.Errorf("fees must be between 0 and 10000 bps")
+	}
+	return nil
+}
+
+// Validate returns an error if a default or user fee is invalid
+func (fs FeeSchedule) Validate() error {
+	if err := fs.Default.validate(); err != nil {
+		return fmt.Errorf("default: %v", err)
+	}
+	for userId, rates := range fs.Users {
+		if err := rates.validate(); err != nil {
+			return fmt.Errorf("user %s: %v", userId, err)
+		}
+	}
+	return nil
+}
+
+// getRates returns the fee rates of a user
+func (fs FeeSchedule) getRates(userId store.UserId) FeeRates {
+	if rates, ok := fs.Users[userId]; ok {
+		return rates
+	}
+	return fs.Default
+}
+
+// getFee returns the fee of a user's fill, in Usd cents rounded down
+func (fs FeeSchedule) getFee(userId store.UserId, notional store.Usd, taker bool) store.Usd {
+	bps := fs.getRates(userId).MakerBps
+	if taker {
+		bps = fs.getRates(userId).TakerBps
+	}
+	return notional * store.Usd(bps) / 10000
+}
+
+// tradeFees returns the fees of the buyer and the seller of a trade
+func (fs FeeSchedule) tradeFees(trade store.Trade) (store.Usd, store.Usd) {
+	notional := store.GetTotalAssetCost(trade.Price, trade.Size)
+	return fs.getFee(trade.BuyerId, notional, trade.BuyIsTaker), fs.getFee(trade.SellerId, notional, trade.SellIsTaker)
+}
+
+// MaxFee returns the highest fee a user can be charged for fills of a notional, at the higher of their
+// maker and taker rates. Fills of a buy order are at or under its limit, so the MaxFee of its cost covers its fees.
+func (fs FeeSchedule) MaxFee(userId store.UserId, notional store.Usd) store.Usd {
+	return fs.getFee(userId, notional, fs.getRates(userId).TakerBps > fs.getRates(userId).MakerBps)
+}
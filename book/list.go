@@ -1,7 +1,9 @@
-package main
+package book
 
 import (
 	"fmt"
+
+	"stockexchange/store"
 )
 
 // Chose to use a linked list data structure to order the order book over an array based list due to following reasons
@@ -15,7 +17,7 @@ import (
 // 4. In best case(order close to the start of the list), deletes, insert, updates and get best order work at ~O(1) since the best order will always be at the start of the list
 
 type OrderNode struct {
-	val  Order
+	val  store.Order
 	next *OrderNode
 }
 
@@ -31,7 +33,7 @@ func newOrdersList() *OrdersList {
 // AddOrder adds an order to the list, maintains order of price-time priority
 // Since this works in first come first serve, time priority is automatically maintained.
 // e.g [4,2,1], if another 2 comes in at a later time, it will be inserted before 1, -> [4,2,2,1].
-func (l *OrdersList) AddOrder(newOrder Order) {
+func (l *OrdersList) AddOrder(newOrder store.Order) {
 	newOrderNode := &OrderNode{
 		val: newOrder,
 	}
@@ -40,10 +42,10 @@ func (l *OrdersList) AddOrder(newOrder Order) {
 	} else {
 
 		// if buy order, order order book by highest buy price(limit)-time priority
-		if newOrder.buyOrSell == BUY {
+		if newOrder.BuyOrSell == store.BUY {
 			// check if new order is better than that at the front of the list
 			// if so set new front of list to new order
-			if newOrder.limit > l.front.val.limit {
+			if newOrder.Limit > l.front.val.Limit {
 				temp := l.front
 				l.front = newOrderNode
 				newOrderNode.next = temp
@@ -53,7 +55,7 @@ func (l *OrdersList) AddOrder(newOrder Order) {
 			// find best position to insert order by price-time priority
 			// insert newOrder
 			for t := l.front; t != nil; t = t.next {
-				if (t.next != nil && newOrder.limit > t.next.val.limit) || t.next == nil {
+				if (t.next != nil && newOrder.Limit > t.next.val.Limit) || t.next == nil {
 					temp := t.next
 					t.next = newOrderNode
 					newOrderNode.next = temp
@@ -63,7 +65,7 @@ func (l *OrdersList) AddOrder(newOrder Order) {
 
 			// do reverse for sell orders. sell order with lowest price should be at the head
 		} else {
-			if newOrder.limit < l.front.val.limit {
+			if newOrder.Limit < l.front.val.Limit {
 				temp := l.front
 				l.front = newOrderNode
 				newOrderNode.next = temp
@@ -73,7 +75,7 @@ func (l *OrdersList) AddOrder(newOrder Order) {
 			// find best position to insert order by price-time priority
 			// insert newOrder
 			for t := l.front; t != nil; t = t.next {
-				if (t.next != nil && newOrder.limit < t.next.val.limit) || t.next == nil {
+				if (t.next != nil && newOrder.Limit < t.next.val.Limit) || t.next == nil {
 					temp := t.next
 					t.next = newOrderNode
 					newOrderNode.next = temp
@@ -85,24 +87,24 @@ func (l *OrdersList) AddOrder(newOrder Order) {
 }
 
 // GetTopOrder returns the top order in the list, which is the front of the list
-func (l *OrdersList) GetTopOrder() Order {
+func (l *OrdersList) GetTopOrder() store.Order {
 	if l.front != nil {
 		return l.front.val
 	}
-	return Order{}
+	return store.Order{}
 }
 
 // DeleteOrder deletes an order from the list given an order id
-func (l *OrdersList) DeleteOrder(oid OrderId) {
+func (l *OrdersList) DeleteOrder(oid store.OrderId) {
 	// handle case order to delete at at the front of the list
 	if l.front != nil {
-		if l.front.val.orderId == oid {
+		if l.front.val.OrderId == oid {
 			l.front = l.front.next
 			return
 		}
 
 		for t := l.front; t != nil; t = t.next {
-			if t.next != nil && t.next.val.orderId == oid {
+			if t.next != nil && t.next.val.OrderId == oid {
 				temp := t.next.next
 				t.next = temp
 			}
@@ -111,26 +113,52 @@ func (l *OrdersList) DeleteOrder(oid OrderId) {
 }
 
 // UpdateOrder updates an order in the list. Only the size of the order can be updated.
-func (l *OrdersList) UpdateOrder(order Order) {
+func (l *OrdersList) UpdateOrder(order store.Order) {
 	for t := l.front; t != nil; t = t.next {
-		if t.val.orderId == order.orderId {
-			t.val.size = order.size
+		if t.val.OrderId == order.OrderId {
+			t.val.Size = order.Size
 		}
 	}
 }
 
 // getOrder finds an Order{} given an order id and returns the Order{} if found.
-func (l *OrdersList) getOrder(oid OrderId) Order {
+func (l *OrdersList) getOrder(oid store.OrderId) store.Order {
 	for t := l.front; t != nil; t = t.next {
-		if t.val.orderId == oid {
+		if t.val.OrderId == oid {
 			return t.val
 		}
 	}
-	return Order{}
+	return store.Order{}
+}
+
+// getOrders returns all orders in the list in price-time priority
+func (l *OrdersList) getOrders() []store.Order {
+	var orders []store.Order
+	for t := l.front; t != nil; t = t.next {
+		orders = append(orders, t.val)
+	}
+	return orders
+}
+
+// getDepth returns up to levels price levels of the list, best price first. Every level has the total size
+// and number of the orders at its price.
+func (l *OrdersList) getDepth(levels int) []PriceLevel {
+	var depth []PriceLevel
+	for t := l.front; t != nil; t = t.next {
+		if len(depth) == 0 || depth[len(depth)-1].Price != t.val.Limit {
+			if len(depth) == levels {
+				break
+			}
+			depth = append(depth, PriceLevel{Price: t.val.Limit})
+		}
+		depth[len(depth)-1].Size += t.val.Size
+		depth[len(depth)-1].Orders++
+	}
+	return depth
 }
 
-// getSize returns the size of the list
-func (l *OrdersList) getSize() int {
+// Len returns the size of the list
+func (l *OrdersList) Len() int {
 	count := 0
 	for t := l.front; t != nil; t = t.next {
 		count++
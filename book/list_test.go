@@ -1,16 +1,17 @@
-package main
+package book
 
 import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
 )
 
 func TestOrdersList_AddOrder_BUY(t *testing.T) {
-	o1 := Order{orderId: "1", limit: 100, buyOrSell: BUY}
-	o2 := Order{orderId: "2", limit: 200, buyOrSell: BUY}
-	o3 := Order{orderId: "3", limit: 300, buyOrSell: BUY}
-	o4 := Order{orderId: "4", limit: 400, buyOrSell: BUY}
+	o1 := store.Order{OrderId: "1", Limit: 100, BuyOrSell: store.BUY}
+	o2 := store.Order{OrderId: "2", Limit: 200, BuyOrSell: store.BUY}
+	o3 := store.Order{OrderId: "3", Limit: 300, BuyOrSell: store.BUY}
+	o4 := store.Order{OrderId: "4", Limit: 400, BuyOrSell: store.BUY}
 
 	list := newOrdersList()
 	list.AddOrder(o2) // add to front
@@ -30,10 +31,10 @@ func TestOrdersList_AddOrder_BUY(t *testing.T) {
 }
 
 func TestOrdersList_AddOrder_SELL(t *testing.T) {
-	o1 := Order{orderId: "1", limit: 100, buyOrSell: SELL}
-	o2 := Order{orderId: "2", limit: 200, buyOrSell: SELL}
-	o3 := Order{orderId: "3", limit: 300, buyOrSell: SELL}
-	o4 := Order{orderId: "4", limit: 400, buyOrSell: SELL}
+	o1 := store.Order{OrderId: "1", Limit: 100, BuyOrSell: store.SELL}
+	o2 := store.Order{OrderId: "2", Limit: 200, BuyOrSell: store.SELL}
+	o3 := store.Order{OrderId: "3", Limit: 300, BuyOrSell: store.SELL}
+	o4 := store.Order{OrderId: "4", Limit: 400, BuyOrSell: store.SELL}
 
 	list := newOrdersList()
 	list.AddOrder(o2) // add to front
@@ -53,8 +54,8 @@ func TestOrdersList_AddOrder_SELL(t *testing.T) {
 }
 
 func TestOrdersList_GetTopOrder(t *testing.T) {
-	o1 := Order{orderId: "1", limit: 100}
-	o2 := Order{orderId: "2", limit: 200}
+	o1 := store.Order{OrderId: "1", Limit: 100}
+	o2 := store.Order{OrderId: "2", Limit: 200}
 
 	list := newOrdersList()
 	list.AddOrder(o1)
@@ -65,10 +66,9 @@ func TestOrdersList_GetTopOrder(t *testing.T) {
 }
 
 func TestOrdersList_UpdateOrder(t *testing.T) {
-	o1 := Order{orderId: "1", limit: 100, size: 10}
-	o2 := Order{orderId: "2", limit: 200, size: 20}
-	o3 := Order{orderId: "3", limit: 300, size: 30}
-
+	o1 := store.Order{OrderId: "1", Limit: 100, Size: 10}
+	o2 := store.Order{OrderId: "2", Limit: 200, Size: 20}
+	o3 := store.Order{OrderId: "3", Limit: 300, Size: 30}
 
 	list := newOrdersList()
 	list.AddOrder(o1)
@@ -76,18 +76,17 @@ func TestOrdersList_UpdateOrder(t *testing.T) {
 	list.AddOrder(o3)
 
 	// update order
-	o2.size= 50
+	o2.Size = 50
 	list.UpdateOrder(o2)
 
-	actual := list.getOrder(o2.orderId)
-	assert.Equal(t, 50, actual.size)
+	actual := list.getOrder(o2.OrderId)
+	assert.Equal(t, 50, actual.Size)
 }
 
 func TestOrdersList_DeleteOrder(t *testing.T) {
-	o1 := Order{orderId: "1", limit: 100, size: 10}
-	o2 := Order{orderId: "2", limit: 200, size: 20}
-	o3 := Order{orderId: "3", limit: 300, size: 30}
-
+	o1 := store.Order{OrderId: "1", Limit: 100, Size: 10}
+	o2 := store.Order{OrderId: "2", Limit: 200, Size: 20}
+	o3 := store.Order{OrderId: "3", Limit: 300, Size: 30}
 
 	list := newOrdersList()
 	list.AddOrder(o1)
@@ -95,24 +94,24 @@ func TestOrdersList_DeleteOrder(t *testing.T) {
 	list.AddOrder(o3)
 
 	// delete order -> mid of list
-	list.DeleteOrder(o2.orderId)
+	list.DeleteOrder(o2.OrderId)
 
-	actual := list.getOrder(o2.orderId)
-	assert.Empty(t,  actual)
-	assert.Equal(t, 2, list.getSize())
+	actual := list.getOrder(o2.OrderId)
+	assert.Empty(t, actual)
+	assert.Equal(t, 2, list.Len())
 
 	// delete order -> end of list
-	list.DeleteOrder(o3.orderId)
-	actual = list.getOrder(o3.orderId)
+	list.DeleteOrder(o3.OrderId)
+	actual = list.getOrder(o3.OrderId)
 
-	assert.Empty(t,  actual)
-	assert.Equal(t, 1, list.getSize())
+	assert.Empty(t, actual)
+	assert.Equal(t, 1, list.Len())
 
 	// delete order -> front of list
-	list.DeleteOrder(o1.orderId)
-	actual = list.getOrder(o1.orderId)
+	list.DeleteOrder(o1.OrderId)
+	actual = list.getOrder(o1.OrderId)
 
-	assert.Empty(t,  actual)
-	assert.Equal(t, 0, list.getSize())
+	assert.Empty(t, actual)
+	assert.Equal(t, 0, list.Len())
 	assert.Nil(t, list.front)
-}
\ No newline at end of file
+}
@@ -0,0 +1,344 @@
+// Package book implements the limit order book of every asset: price-time priority matching, trading phases,
+// call auctions, circuit breakers and fees. Trades update the balances of the users in a store.Store.
+package book
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"stockexchange/store"
+)
+
+// TradingPhase represents the phase of the trading session an order book is in
+type TradingPhase string
+
+const (
+	Closed     TradingPhase = "CLOSED"     // no orders are accepted
+	PreOpen    TradingPhase = "PRE_OPEN"   // orders are accepted and rest in the book without matching
+	Auction    TradingPhase = "AUCTION"    // orders accumulate without matching until the book is uncrossed
+	Continuous TradingPhase = "CONTINUOUS" // incoming orders are matched immediately
+	Halted     TradingPhase = "HALTED"     // trading is suspended, no orders are accepted or matched
+	PostClose  TradingPhase = "POST_CLOSE" // trading day is over, no orders are accepted
+)
+
+// OrderBook struct represents an order book for buy and sell orders sorted by price-time priority
+type OrderBook struct {
+	BuyList    *OrdersList
+	SellList   *OrdersList
+	phase      TradingPhase                      // current trading phase of the book
+	refPrice   store.Usd                         // reference price for the price bands, the last traded price
+	haltedAt   time.Time                         // time the book was last halted by the circuit breaker
+	onTrade    func(store.Trade, *store.Command) // called for every trade executed in the book by a command
+	sync.Mutex                                   // synchronize operations
+}
+
+// OrderBooks struct manages all order books for each asset and operations on each asset's order book
+type OrderBooks struct {
+	orderBooks     map[store.AssetId]*OrderBook
+	Breaker        CircuitBreaker      // price bands applied to every order book
+	Fees           FeeSchedule         // fees charged on every trade
+	tradeListeners []func(store.Trade) // notified of every trade executed in any order book
+	mu             sync.Mutex          // synchronize access to the orderBooks map
+}
+
+// NewOrderBooks returns order books with the default circuit breaker and no fees
+func NewOrderBooks() *OrderBooks {
+	return &OrderBooks{
+		orderBooks: make(map[store.AssetId]*OrderBook),
+		Breaker:    DefaultCircuitBreaker,
+	}
+}
+
+// OrderBook retrieves the order book for the given assetId
+// It creates an empty order book if there isn't one for the given assetId
+func (ob *OrderBooks) OrderBook(assetId store.AssetId) *OrderBook {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if _, ok := ob.orderBooks[assetId]; ok {
+		return ob.orderBooks[assetId]
+	}
+
+	// create new order book for assetId if one isn't present
+	ob.orderBooks[assetId] = ob.newOrderBook()
+	return ob.orderBooks[assetId]
+}
+
+// getOrderBook retrieves the order book for the given assetId to read it.
+// It returns an empty order book, without adding it, if there isn't one, so reads never create order books.
+func (ob *OrderBooks) getOrderBook(assetId store.AssetId) *OrderBook {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if orderBook, ok := ob.orderBooks[assetId]; ok {
+		return orderBook
+	}
+	return ob.newOrderBook()
+}
+
+// HasOrderBook returns if there is an order book for the given assetId
+func (ob *OrderBooks) HasOrderBook(assetId store.AssetId) bool {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	_, ok := ob.orderBooks[assetId]
+	return ok
+}
+
+// newOrderBook returns an empty order book in continuous trading
+func (ob *OrderBooks) newOrderBook() *OrderBook {
+	return &OrderBook{
+		BuyList:  newOrdersList(),
+		SellList: newOrdersList(),
+		phase:    Continuous,
+		onTrade:  ob.publishTrade,
+	}
+}
+
+// GetAssetIds returns the ids of all assets with an order book, sorted by id
+func (ob *OrderBooks) GetAssetIds() []store.AssetId {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	assetIds := make([]store.AssetId, 0, len(ob.orderBooks))
+	for assetId := range ob.orderBooks {
+		assetIds = append(assetIds, assetId)
+	}
+	sort.Slice(assetIds, func(i, j int) bool { return assetIds[i] < assetIds[j] })
+	return assetIds
+}
+
+// GetTopOrder returns the top order in the order book for an assetId
+func (ob *OrderBooks) GetTopOrder(assetId store.AssetId, buyOrSell store.BuyOrSell) store.Order {
+	orderBook := ob.getOrderBook(assetId)
+	if orderBook != nil {
+		orderBook.Lock()
+		defer orderBook.Unlock()
+
+		if buyOrSell == store.BUY {
+			return orderBook.BuyList.GetTopOrder()
+		} else {
+			return orderBook.SellList.GetTopOrder()
+		}
+	}
+	return store.Order{}
+}
+
+// AddOrder adds a new order to the order book for an asset
+func (ob *OrderBooks) AddOrder(order store.Order) {
+	orderBook := ob.OrderBook(order.AssetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	orderBook.addOrder(order)
+}
+
+// UpdateOrder updates an order from the order book
+func (ob *OrderBooks) UpdateOrder(order store.Order) {
+	orderBook := ob.OrderBook(order.AssetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	if order.BuyOrSell == store.BUY {
+		orderBook.BuyList.UpdateOrder(order)
+	} else {
+		orderBook.SellList.UpdateOrder(order)
+	}
+}
+
+// DeleteOrder deletes an order from the order book/
+// It returns false if the order wasn't in the order book, e.g it was filled.
+func (ob *OrderBooks) DeleteOrder(order store.Order) bool {
+	orderBook := ob.OrderBook(order.AssetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	orderList := orderBook.SellList
+	if order.BuyOrSell == store.BUY {
+		orderList = orderBook.BuyList
+	}
+	if orderList.getOrder(order.OrderId).OrderId == "" {
+		return false
+	}
+	orderList.DeleteOrder(order.OrderId)
+	return true
+}
+
+// GetOrders returns all orders in an asset's order book, buy orders first
+func (ob *OrderBooks) GetOrders(assetId store.AssetId) []store.Order {
+	orderBook := ob.getOrderBook(assetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	return append(orderBook.BuyList.getOrders(), orderBook.SellList.getOrders()...)
+}
+
+// PriceLevel is the orders resting in an order book at a price
+type PriceLevel struct {
+	Price  store.Usd // limit of the orders, in Usd cents
+	Size   int       // total number of assets of the orders
+	Orders int       // number of orders
+}
+
+// GetDepth returns up to levels price levels of the buy and sell side of an asset's order book, best price first
+func (ob *OrderBooks) GetDepth(assetId store.AssetId, levels int) ([]PriceLevel, []PriceLevel) {
+	orderBook := ob.getOrderBook(assetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	return orderBook.BuyList.getDepth(levels), orderBook.SellList.getDepth(levels)
+}
+
+// ExecuteOrder executes an order on the order book
+// It tries to match a new order with the order book and executes if there is a match.
+// If no match, the new order is added to the order book.
+// The unfilled size of IOC and FOK orders isn't added to the order book, and FOK orders are only matched if they
+// can be filled in full, the caller cancels what's left of them.
+// The trades are numbered and timed by the command that placed the order.
+// It returns true if matching stopped and the asset was halted because a fill would have traded outside its price band.
+func (ob *OrderBooks) ExecuteOrder(newOrder store.Order, s *store.Store, cmd *store.Command) bool {
+	orderBook := ob.OrderBook(newOrder.AssetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	rests := newOrder.TimeInForce != store.IOC && newOrder.TimeInForce != store.FOK
+
+	// orders rest in the book without matching outside of continuous trading
+	if orderBook.phase != Continuous {
+		if rests {
+			orderBook.addOrder(newOrder)
+		}
+		return false
+	}
+
+	// the price band is fixed for the whole order, so a single order can't walk the book away from the reference price
+	band := ob.Breaker.priceBand(orderBook.refPrice)
+	oppositeList := orderBook.SellList
+	if newOrder.BuyOrSell == store.SELL {
+		oppositeList = orderBook.BuyList
+	}
+	if newOrder.TimeInForce == store.FOK && fillableSize(oppositeList, newOrder, newOrder.BuyOrSell, band) < newOrder.Size {
+		return false
+	}
+
+	order := orderBook.executeOrder(oppositeList, newOrder, s, newOrder.BuyOrSell, band, cmd)
+
+	// add unfilled orders to the order book
+	if order.Size > 0 && rests {
+		orderBook.addOrder(order)
+	}
+
+	return orderBook.phase == Halted
+}
+
+// addOrder adds an order to the buy or sell list of the order book.
+// Callers must hold the order book lock.
+func (b *OrderBook) addOrder(order store.Order) {
+	if order.BuyOrSell == store.BUY {
+		b.BuyList.AddOrder(order)
+	} else {
+		b.SellList.AddOrder(order)
+	}
+}
+
+// executeOrder tries to execute an order if a match order is found
+// else adds the order to the order book.
+// Matching stops and the order book is halted if a fill would trade outside the price band.
+func (b *OrderBook) executeOrder(orderList *OrdersList, newOrder store.Order, s *store.Store, buyOrSell store.BuyOrSell, band PriceBand, cmd *store.Command) store.Order {
+	for orderMatchAvailable(orderList, newOrder, buyOrSell) { // match incoming order with orders in the order book
+		matchedOrder := orderList.GetTopOrder()
+		matchedPrice := getMatchedPrice(buyOrSell, matchedOrder, newOrder)
+
+		if !band.contains(matchedPrice) {
+			b.phase = Halted
+			b.haltedAt = cmd.At
+			break // exit loop, the remaining order rests in the halted book
+		}
+		b.refPrice = matchedPrice
+
+		tradeAssetsSize := min(matchedOrder.Size, newOrder.Size)
+		newOrder.Size -= tradeAssetsSize     // update new order's asset size
+		matchedOrder.Size -= tradeAssetsSize // update matched order in order book
+
+		if buyOrSell == store.BUY {
+			trade := store.NewTrade(newOrder, matchedOrder, matchedPrice, tradeAssetsSize)
+			trade.BuyIsTaker = true
+			b.onTrade(trade, cmd)
+		} else {
+			trade := store.NewTrade(matchedOrder, newOrder, matchedPrice, tradeAssetsSize)
+			trade.SellIsTaker = true
+			b.onTrade(trade, cmd)
+		}
+
+		// new order completely filled
+		if newOrder.Size == 0 {
+			// matchedOrder and newOrder both completely filled
+			if matchedOrder.Size == 0 {
+				orderList.DeleteOrder(matchedOrder.OrderId)
+				// update matched order user's asset info in store
+				s.UpdateUserAsset(matchedOrder, matchedPrice, tradeAssetsSize, buyOrSell, store.Complete)
+
+			} else {
+				orderList.UpdateOrder(matchedOrder)
+
+				// update matched order user's asset info in store
+				s.UpdateUserAsset(matchedOrder, matchedPrice, tradeAssetsSize, buyOrSell, store.Working)
+			}
+
+			// update new order user's assets info in store
+			s.UpdateUserAsset(newOrder, matchedPrice, tradeAssetsSize, buyOrSell, store.Complete)
+
+			break // exit loop since new order was fulfilled
+		}
+
+		// matched order completely executed
+		// remove matched order from order book
+		orderList.DeleteOrder(matchedOrder.OrderId)
+
+		// update new order user's assets info in store
+		s.UpdateUserAsset(newOrder, matchedPrice, tradeAssetsSize, buyOrSell, store.Working)
+
+		// update matched order user's asset info in store
+		s.UpdateUserAsset(matchedOrder, matchedPrice, tradeAssetsSize, buyOrSell, store.Complete)
+	}
+	return newOrder
+}
+
+// fillableSize returns the size of the orders of the order list a new order can be matched with at once,
+// i.e without trading outside the price band
+func fillableSize(orderList *OrdersList, newOrder store.Order, buyOrSell store.BuyOrSell, band PriceBand) int {
+	size := 0
+	for node := orderList.front; node != nil && size < newOrder.Size; node = node.next {
+		matchedOrder := node.val
+		if !orderMatches(matchedOrder, newOrder, buyOrSell) || !band.contains(getMatchedPrice(buyOrSell, matchedOrder, newOrder)) {
+			break
+		}
+		size += matchedOrder.Size
+	}
+	return size
+}
+
+// getMatchedPrice returns matched price, which is the price of the sell order.
+func getMatchedPrice(buyOrSell store.BuyOrSell, matchedOrder store.Order, newOrder store.Order) store.Usd {
+	var matchedPrice store.Usd
+	if buyOrSell == store.BUY {
+		matchedPrice = matchedOrder.Limit
+	} else {
+		matchedPrice = newOrder.Limit
+	}
+	return matchedPrice
+}
+
+// orderMatchAvailable returns if there is an order in the order book that matches the incoming new order
+// For a BUY order, it returns true if there is a sell order in the order book that is <= the buy order's limit price
+// For a sell order, it returns true if there is a buy order in the order book that is >= the sell order's limit price
+// Else returns false.
+func orderMatchAvailable(orderList *OrdersList, newOrder store.Order, orderType store.BuyOrSell) bool {
+	return !orderList.isEmpty() && orderMatches(orderList.GetTopOrder(), newOrder, orderType)
+}
+
+// orderMatches returns if an order of the order book matches the incoming new order
+func orderMatches(order store.Order, newOrder store.Order, orderType store.BuyOrSell) bool {
+	return (orderType == store.BUY && order.Limit <= newOrder.Limit) || (orderType == store.SELL && order.Limit >= newOrder.Limit)
+}
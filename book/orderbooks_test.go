@@ -0,0 +1,329 @@
+package book
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+var userId1 = store.UserId("userId1")
+
+var userId2 = store.UserId("userId2")
+
+var assetId1 = store.AssetId("COIN")
+
+var assetId2 = store.AssetId("GAME")
+
+// newCommand returns a command accepted now, to number and time the trades of a test
+func newCommand() *store.Command {
+	return store.NewCommand(store.EventsPerCommand, time.Now())
+}
+
+func TestOrderBooks_AddOrder(t *testing.T) {
+	o1 := store.Order{OrderId: "1", AssetId: "COIN", Limit: 100, BuyOrSell: store.BUY}
+
+	ob := NewOrderBooks()
+	ob.AddOrder(o1)
+
+	actual := ob.orderBooks[o1.AssetId].BuyList.getOrder(o1.OrderId)
+
+	assert.Equal(t, o1, actual)
+}
+
+func TestOrderBooks_GetTopOrder(t *testing.T) {
+	o1 := store.Order{OrderId: "1", AssetId: "COIN", Limit: 100, BuyOrSell: store.BUY}
+	o2 := store.Order{OrderId: "2", AssetId: "COIN", Limit: 200, BuyOrSell: store.BUY}
+
+	ob := NewOrderBooks()
+	ob.AddOrder(o1)
+	ob.AddOrder(o2)
+
+	actual := ob.GetTopOrder("COIN", store.BUY)
+
+	assert.Equal(t, o2, actual)
+}
+
+func TestOrderBooks_UpdateOrder(t *testing.T) {
+	o1 := store.Order{OrderId: "1", AssetId: "COIN", Limit: 100, Size: 30, BuyOrSell: store.BUY}
+
+	ob := NewOrderBooks()
+	ob.AddOrder(o1)
+
+	o1.Size = 20
+	ob.UpdateOrder(o1)
+
+	actual := ob.OrderBook("COIN").BuyList.getOrder(o1.OrderId)
+
+	assert.Equal(t, 20, actual.Size)
+}
+
+func TestOrderBooks_DeleteOrder(t *testing.T) {
+	o1 := store.Order{OrderId: "1", AssetId: "COIN", Limit: 100, BuyOrSell: store.BUY}
+
+	ob := NewOrderBooks()
+	ob.AddOrder(o1)
+
+	ob.DeleteOrder(o1)
+
+	actual := ob.OrderBook("COIN").BuyList.getOrder(o1.OrderId)
+	lengthOfBuyList := ob.OrderBook("COIN").BuyList.Len()
+
+	assert.Empty(t, actual)
+	assert.Equal(t, 0, lengthOfBuyList)
+}
+
+// Execute top order once
+func TestOrderBooks_ExecuteOrder_BUY_CASE1(t *testing.T) {
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	sellOrder2 := store.Order{OrderId: "so2", UserId: userId1, AssetId: assetId1, Limit: 101, Size: 30, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId2, AssetId: assetId1, Limit: 100, Size: 25, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{sellOrder1, sellOrder2}, []store.Order{buyOrder1})
+
+	ob := NewOrderBooks()
+
+	ob.AddOrder(sellOrder1)
+	ob.AddOrder(sellOrder2)
+
+	ob.ExecuteOrder(buyOrder1, s, newCommand())
+
+	seller := s.GetUserData(userId1)
+	buyer := s.GetUserData(userId2)
+
+	assert.Equal(t, store.Usd(11000), seller.Cash) // assert user with sell order has increase in cash available
+	assert.Equal(t, 110, buyer.Assets[assetId1])   // assert user with buy order has increase in asset size available
+	assert.Equal(t, store.Complete, seller.Orders[sellOrder1.OrderId].Status)
+	assert.Equal(t, store.Working, seller.Orders[sellOrder2.OrderId].Status)
+	assert.Equal(t, store.Working, buyer.Orders[buyOrder1.OrderId].Status)
+
+	orderBook := ob.OrderBook(assetId1)
+
+	assert.Equal(t, 1, orderBook.SellList.Len()) // assert sellorder2 is in order book
+	assert.Equal(t, sellOrder2.OrderId, orderBook.SellList.GetTopOrder().OrderId)
+	assert.Equal(t, 1, orderBook.BuyList.Len()) // assert partial buy order is in order book
+	assert.Equal(t, buyOrder1.OrderId, orderBook.BuyList.GetTopOrder().OrderId)
+	assert.Equal(t, 15, orderBook.BuyList.GetTopOrder().Size) // assert remaining buy order size
+}
+
+// Execute everything in the sell order list and buy order fully executed
+func TestOrderBooks_ExecuteOrder_BUY_CASE2(t *testing.T) {
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	sellOrder2 := store.Order{OrderId: "so2", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 30, BuyOrSell: store.SELL, EventAt: time.Now().Add(1 * time.Minute), Status: store.Working}
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId2, AssetId: assetId1, Limit: 100, Size: 40, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{sellOrder1, sellOrder2}, []store.Order{buyOrder1})
+
+	ob := NewOrderBooks()
+
+	ob.AddOrder(sellOrder1)
+	ob.AddOrder(sellOrder2)
+
+	ob.ExecuteOrder(buyOrder1, s, newCommand())
+
+	seller := s.GetUserData(userId1)
+	buyer := s.GetUserData(userId2)
+
+	assert.Equal(t, store.Usd(14000), seller.Cash) // assert user with sell order has increase in cash available
+	assert.Equal(t, 140, buyer.Assets[assetId1])   // assert user with buy order has increase in asset size available
+	assert.Equal(t, store.Complete, seller.Orders[sellOrder1.OrderId].Status)
+	assert.Equal(t, store.Complete, seller.Orders[sellOrder2.OrderId].Status)
+	assert.Equal(t, store.Complete, buyer.Orders[buyOrder1.OrderId].Status)
+
+	orderBook := ob.OrderBook(assetId1)
+
+	assert.Equal(t, 0, orderBook.SellList.Len()) // assert sell list is empty
+	assert.Equal(t, 0, orderBook.BuyList.Len())  // assert buy list is empty
+}
+
+// Execute all sell orders and add remaining buy order to order book
+func TestOrderBooks_ExecuteOrder_BUY_CASE3(t *testing.T) {
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	sellOrder2 := store.Order{OrderId: "so2", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 30, BuyOrSell: store.SELL, EventAt: time.Now().Add(1 * time.Minute), Status: store.Working}
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId2, AssetId: assetId1, Limit: 100, Size: 50, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{sellOrder1, sellOrder2}, []store.Order{buyOrder1})
+
+	ob := NewOrderBooks()
+
+	ob.AddOrder(sellOrder1)
+	ob.AddOrder(sellOrder2)
+
+	ob.ExecuteOrder(buyOrder1, s, newCommand())
+
+	seller := s.GetUserData(userId1)
+	buyer := s.GetUserData(userId2)
+
+	assert.Equal(t, store.Usd(14000), seller.Cash)
+	assert.Equal(t, 140, buyer.Assets[assetId1])
+	assert.Equal(t, store.Complete, seller.Orders[sellOrder1.OrderId].Status)
+	assert.Equal(t, store.Complete, seller.Orders[sellOrder2.OrderId].Status)
+	assert.Equal(t, store.Working, buyer.Orders[buyOrder1.OrderId].Status)
+	assert.Equal(t, 40, buyer.Orders[buyOrder1.OrderId].Filled) // assert amount of assets filled
+
+	orderBook := ob.OrderBook(assetId1)
+
+	assert.Equal(t, 0, orderBook.SellList.Len()) // assert sellorder2 is in order book
+	assert.Equal(t, 1, orderBook.BuyList.Len())  // assert partial buy order is in order book
+	assert.Equal(t, buyOrder1.OrderId, orderBook.BuyList.GetTopOrder().OrderId)
+	assert.Equal(t, 10, orderBook.BuyList.GetTopOrder().Size) // assert remaining buy order size
+}
+
+func TestOrderBooks_ExecuteOrder_SELL_CASE1(t *testing.T) {
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	buyOrder2 := store.Order{OrderId: "bo2", UserId: userId1, AssetId: assetId1, Limit: 101, Size: 30, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId2, AssetId: assetId1, Limit: 100, Size: 35, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{buyOrder1, buyOrder2}, []store.Order{sellOrder1})
+
+	ob := NewOrderBooks()
+
+	ob.AddOrder(buyOrder1)
+	ob.AddOrder(buyOrder2)
+
+	ob.ExecuteOrder(sellOrder1, s, newCommand())
+
+	buyer := s.GetUserData(userId1)
+	seller := s.GetUserData(userId2)
+
+	assert.Equal(t, 135, buyer.Assets[assetId1])   // assert buyer's asset's size has increased
+	assert.Equal(t, store.Usd(13500), seller.Cash) // assert seller has increase in cash available
+	assert.Equal(t, store.Complete, buyer.Orders[buyOrder2.OrderId].Status)
+	assert.Equal(t, store.Working, buyer.Orders[buyOrder1.OrderId].Status)
+	assert.Equal(t, store.Complete, seller.Orders[sellOrder1.OrderId].Status)
+	assert.Equal(t, 35, seller.Orders[sellOrder1.OrderId].Filled)
+	assert.Equal(t, 5, buyer.Orders[buyOrder1.OrderId].Filled)
+	assert.Equal(t, 30, buyer.Orders[buyOrder2.OrderId].Filled)
+
+	orderBook := ob.OrderBook(assetId1)
+
+	assert.Equal(t, 0, orderBook.SellList.Len())
+	assert.Equal(t, 1, orderBook.BuyList.Len())
+	assert.Equal(t, buyOrder1.OrderId, orderBook.BuyList.GetTopOrder().OrderId)
+	assert.Equal(t, 5, orderBook.BuyList.GetTopOrder().Size)
+}
+
+func TestOrderBooks_ExecuteOrder_SELL_CASE2(t *testing.T) {
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	buyOrder2 := store.Order{OrderId: "bo2", UserId: userId1, AssetId: assetId1, Limit: 101, Size: 30, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId2, AssetId: assetId1, Limit: 100, Size: 40, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{buyOrder1, buyOrder2}, []store.Order{sellOrder1})
+
+	ob := NewOrderBooks()
+
+	ob.AddOrder(buyOrder1)
+	ob.AddOrder(buyOrder2)
+
+	ob.ExecuteOrder(sellOrder1, s, newCommand())
+
+	buyer := s.GetUserData(userId1)
+	seller := s.GetUserData(userId2)
+
+	assert.Equal(t, 140, buyer.Assets[assetId1])   // assert buyer's asset's size has increased
+	assert.Equal(t, store.Usd(14000), seller.Cash) // assert seller has increase in cash available
+	assert.Equal(t, store.Complete, buyer.Orders[buyOrder2.OrderId].Status)
+	assert.Equal(t, store.Complete, buyer.Orders[buyOrder1.OrderId].Status)
+	assert.Equal(t, store.Complete, seller.Orders[sellOrder1.OrderId].Status)
+
+	orderBook := ob.OrderBook(assetId1)
+
+	assert.Equal(t, 0, orderBook.SellList.Len())
+	assert.Equal(t, 0, orderBook.BuyList.Len())
+}
+
+func TestOrderBooks_ExecuteOrder_SELL_CASE3(t *testing.T) {
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	buyOrder2 := store.Order{OrderId: "bo2", UserId: userId1, AssetId: assetId1, Limit: 101, Size: 30, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId2, AssetId: assetId1, Limit: 100, Size: 50, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{buyOrder1, buyOrder2}, []store.Order{sellOrder1})
+
+	ob := NewOrderBooks()
+
+	ob.AddOrder(buyOrder1)
+	ob.AddOrder(buyOrder2)
+
+	ob.ExecuteOrder(sellOrder1, s, newCommand())
+
+	buyer := s.GetUserData(userId1)
+	seller := s.GetUserData(userId2)
+
+	assert.Equal(t, 140, buyer.Assets[assetId1])   // assert buyer's asset's size has increased
+	assert.Equal(t, store.Usd(14000), seller.Cash) // assert seller has increase in cash available
+	assert.Equal(t, store.Complete, buyer.Orders[buyOrder2.OrderId].Status)
+	assert.Equal(t, store.Complete, buyer.Orders[buyOrder1.OrderId].Status)
+	assert.Equal(t, store.Working, seller.Orders[sellOrder1.OrderId].Status)
+
+	orderBook := ob.OrderBook(assetId1)
+
+	assert.Equal(t, 1, orderBook.SellList.Len())
+	assert.Equal(t, 0, orderBook.BuyList.Len())
+	assert.Equal(t, sellOrder1.OrderId, orderBook.SellList.GetTopOrder().OrderId)
+	assert.Equal(t, 10, orderBook.SellList.GetTopOrder().Size)
+}
+
+func TestOrderBooks_ExecuteOrder_SELL_CASE4(t *testing.T) {
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId2, AssetId: assetId1, Limit: 101, Size: 35, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	store := setupTestData([]store.Order{buyOrder1}, []store.Order{sellOrder1})
+
+	ob := NewOrderBooks()
+	ob.AddOrder(buyOrder1)
+	ob.ExecuteOrder(sellOrder1, store, newCommand())
+
+	orderBook := ob.OrderBook(assetId1)
+
+	assert.Equal(t, 1, orderBook.SellList.Len())
+	assert.Equal(t, 1, orderBook.BuyList.Len())
+}
+
+func TestOrderBook_OrderMatchAvailable_CASE1(t *testing.T) {
+	buyOrderList := newOrdersList()
+	buyOrder := store.Order{Limit: 1000, BuyOrSell: store.BUY}
+	buyOrderList.AddOrder(buyOrder)
+
+	sellOrder1 := store.Order{Limit: 1000, BuyOrSell: store.SELL}
+	sellOrder2 := store.Order{Limit: 999, BuyOrSell: store.SELL}
+	sellOrder3 := store.Order{Limit: 1001, BuyOrSell: store.SELL}
+
+	assert.True(t, orderMatchAvailable(buyOrderList, sellOrder1, store.SELL))
+	assert.True(t, orderMatchAvailable(buyOrderList, sellOrder2, store.SELL))
+	assert.False(t, orderMatchAvailable(buyOrderList, sellOrder3, store.SELL))
+}
+
+func TestOrderBook_OrderMatchAvailable_CASE2(t *testing.T) {
+	sellOrderList := newOrdersList()
+	sellOrder := store.Order{Limit: 1000, BuyOrSell: store.BUY}
+	sellOrderList.AddOrder(sellOrder)
+
+	buyOrder1 := store.Order{Limit: 1000, BuyOrSell: store.SELL}
+	buyOrder2 := store.Order{Limit: 999, BuyOrSell: store.SELL}
+	buyOrder3 := store.Order{Limit: 1001, BuyOrSell: store.SELL}
+
+	assert.True(t, orderMatchAvailable(sellOrderList, buyOrder1, store.BUY))
+	assert.False(t, orderMatchAvailable(sellOrderList, buyOrder2, store.BUY))
+	assert.True(t, orderMatchAvailable(sellOrderList, buyOrder3, store.BUY))
+}
+
+func setupTestData(orders1, orders2 []store.Order) *store.Store {
+	userData1 := store.UserData{
+		UserId: userId1,
+		Cash:   10000,
+		Assets: map[store.AssetId]int{assetId1: 100},
+		Orders: make(map[store.OrderId]store.Order),
+	}
+	userData2 := store.UserData{
+		UserId: userId2,
+		Cash:   10000,
+		Assets: map[store.AssetId]int{assetId1: 100},
+		Orders: make(map[store.OrderId]store.Order),
+	}
+
+	for _, o := range orders1 {
+		userData1.Orders[o.OrderId] = o
+	}
+	for _, o := range orders2 {
+		userData2.Orders[o.OrderId] = o
+	}
+
+	store := store.NewStore()
+	store.SetUserData(userData1)
+	store.SetUserData(userData2)
+	return store
+}
@@ -0,0 +1,133 @@
+package book
+
+import (
+	"fmt"
+	"time"
+
+	"stockexchange/store"
+)
+
+// Trading sessions
+//
+// Every asset's order book moves through the trading phases of a session. Orders are only matched during
+// continuous trading, new orders are accepted during pre-open, auction and continuous trading,
+// and cancels are accepted in every phase. Leaving an auction uncrosses the order book.
+//
+//	CLOSED -> PRE_OPEN -> AUCTION -> CONTINUOUS -> AUCTION -> POST_CLOSE -> CLOSED
+//	                                     |   ^
+//	                                     v   |
+//	                                    HALTED -> AUCTION
+//
+// Order books start in continuous trading, so assets without a schedule trade at any time.
+
+// phaseTransitions maps each trading phase to the phases it can move to
+var phaseTransitions = map[TradingPhase][]TradingPhase{
+	Closed:     {PreOpen},
+	PreOpen:    {Auction, Closed},
+	Auction:    {Continuous, PostClose, Halted},
+	Continuous: {Auction, Halted, PostClose},
+	Halted:     {Auction, Closed},
+	PostClose:  {Closed},
+}
+
+// PhaseTransition describes a change of trading phase of an asset's order book
+type PhaseTransition struct {
+	AssetId store.AssetId
+	From    TradingPhase
+	To      TradingPhase
+	Auction *AuctionResult // result of the uncross, if the transition ended an auction with crossing orders
+}
+
+// IsValidPhase returns if phase is a known trading phase
+func IsValidPhase(phase TradingPhase) bool {
+	_, ok := phaseTransitions[phase]
+	return ok
+}
+
+// canTransition returns if an order book can move from one trading phase to another
+func canTransition(from, to TradingPhase) bool {
+	for _, phase := range phaseTransitions[from] {
+		if phase == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsOrders returns if new orders can be submitted during the trading phase
+func AcceptsOrders(phase TradingPhase) bool {
+	return phase == PreOpen || phase == Auction || phase == Continuous
+}
+
+// GetPhase returns the trading phase of the order book of an asset
+func (ob *OrderBooks) GetPhase(assetId store.AssetId) TradingPhase {
+	orderBook := ob.getOrderBook(assetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	return orderBook.phase
+}
+
+// SetPhase moves the order book of an asset to a new trading phase.
+// Moving from an auction to continuous trading or post-close uncrosses the order book.
+// The trades of the uncross are numbered and timed by the command changing the phase.
+// It returns an error if the transition isn't allowed from the book's current phase.
+func (ob *OrderBooks) SetPhase(assetId store.AssetId, phase TradingPhase, store *store.Store, cmd *store.Command) (PhaseTransition, error) {
+	return ob.SetPhaseFrom(assetId, "", phase, store, cmd)
+}
+
+// SetPhaseFrom moves the order book of an asset to a new trading phase only if it is currently in the from phase.
+// An empty from phase moves the order book from whatever phase it is in.
+func (ob *OrderBooks) SetPhaseFrom(assetId store.AssetId, from, phase TradingPhase, store *store.Store, cmd *store.Command) (PhaseTransition, error) {
+	orderBook := ob.OrderBook(assetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	transition := PhaseTransition{AssetId: assetId, From: orderBook.phase, To: phase}
+	if from != "" && orderBook.phase != from {
+		return transition, fmt.Errorf("asset %s is %s, not %s", assetId, orderBook.phase, from)
+	}
+	if !canTransition(orderBook.phase, phase) {
+		return transition, fmt.Errorf("asset %s can't move from %s to %s", assetId, orderBook.phase, phase)
+	}
+
+	if orderBook.phase == Auction && (phase == Continuous || phase == PostClose) {
+		if result, ok := uncrossOrderBook(orderBook, store, cmd); ok {
+			transition.Auction = &result
+		}
+	}
+	orderBook.phase = phase
+	orderBook.haltedAt = time.Time{} // only halts by the circuit breaker are resumed automatically
+
+	return transition, nil
+}
+
+// StopTrading moves the order book of an asset out of the phases accepting orders until it's moved to one of them
+// again: to HALTED, or to CLOSED from pre-open which can't be halted. Order books that don't accept orders keep their
+// phase, a halt by the circuit breaker isn't resumed automatically anymore.
+func (ob *OrderBooks) StopTrading(assetId store.AssetId) PhaseTransition {
+	orderBook := ob.OrderBook(assetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	transition := PhaseTransition{AssetId: assetId, From: orderBook.phase, To: orderBook.phase}
+	if canTransition(orderBook.phase, Halted) {
+		transition.To = Halted
+	} else if AcceptsOrders(orderBook.phase) {
+		transition.To = Closed
+	}
+	orderBook.phase = transition.To
+	orderBook.haltedAt = time.Time{}
+
+	return transition
+}
+
+// ForcePhase moves the order book of an asset to a trading phase without validating the transition.
+// It is used to initialise order books to the phase their schedule is currently in.
+func (ob *OrderBooks) ForcePhase(assetId store.AssetId, phase TradingPhase) {
+	orderBook := ob.OrderBook(assetId)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+
+	orderBook.phase = phase
+}
@@ -0,0 +1,85 @@
+package book
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestOrderBooks_SetPhase(t *testing.T) {
+	store := setupTestData(nil, nil)
+	ob := NewOrderBooks()
+
+	assert.Equal(t, Continuous, ob.GetPhase(assetId1)) // order books start in continuous trading
+
+	transition, err := ob.SetPhase(assetId1, Halted, store, newCommand())
+	assert.NoError(t, err)
+	assert.Equal(t, PhaseTransition{AssetId: assetId1, From: Continuous, To: Halted}, transition)
+
+	// invalid transitions leave the phase unchanged
+	_, err = ob.SetPhase(assetId1, Continuous, store, newCommand())
+	assert.Error(t, err)
+	_, err = ob.SetPhase(assetId1, PreOpen, store, newCommand())
+	assert.Error(t, err)
+	assert.Equal(t, Halted, ob.GetPhase(assetId1))
+
+	_, err = ob.SetPhase(assetId1, Auction, store, newCommand())
+	assert.NoError(t, err)
+	transition, err = ob.SetPhase(assetId1, Continuous, store, newCommand())
+	assert.NoError(t, err)
+	assert.Nil(t, transition.Auction) // no crossing orders in the book
+	assert.Equal(t, Continuous, ob.GetPhase(assetId1))
+}
+
+func TestOrderBooks_ExecuteOrder_Halted(t *testing.T) {
+	sellOrder1 := store.Order{OrderId: "so1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.SELL, EventAt: time.Now(), Status: store.Working}
+	buyOrder1 := store.Order{OrderId: "bo1", UserId: userId2, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.BUY, EventAt: time.Now(), Status: store.Working}
+	s := setupTestData([]store.Order{sellOrder1}, []store.Order{buyOrder1})
+
+	ob := NewOrderBooks()
+	ob.AddOrder(sellOrder1)
+
+	_, err := ob.SetPhase(assetId1, Halted, s, newCommand())
+	assert.NoError(t, err)
+
+	ob.ExecuteOrder(buyOrder1, s, newCommand())
+
+	// assert no matching while halted
+	orderBook := ob.OrderBook(assetId1)
+	assert.Equal(t, 1, orderBook.SellList.Len())
+	assert.Equal(t, 1, orderBook.BuyList.Len())
+	assert.Equal(t, store.Working, s.GetUserData(userId1).Orders[sellOrder1.OrderId].Status)
+
+	// resuming through an auction executes the crossing orders
+	_, err = ob.SetPhase(assetId1, Auction, s, newCommand())
+	assert.NoError(t, err)
+	transition, err := ob.SetPhase(assetId1, Continuous, s, newCommand())
+	assert.NoError(t, err)
+	assert.Equal(t, &AuctionResult{Price: 100, Volume: 10}, transition.Auction)
+	assert.Equal(t, 0, orderBook.SellList.Len())
+	assert.Equal(t, 0, orderBook.BuyList.Len())
+	assert.Equal(t, store.Complete, s.GetUserData(userId1).Orders[sellOrder1.OrderId].Status)
+}
+
+func TestOrderBooks_StopTrading(t *testing.T) {
+	ob := NewOrderBooks()
+
+	assert.Equal(t, PhaseTransition{AssetId: assetId1, From: Continuous, To: Halted}, ob.StopTrading(assetId1))
+	assert.Equal(t, PhaseTransition{AssetId: assetId1, From: Halted, To: Halted}, ob.StopTrading(assetId1))
+
+	// pre-open can't be halted, the order book is closed instead
+	ob.ForcePhase(assetId2, PreOpen)
+	assert.Equal(t, PhaseTransition{AssetId: assetId2, From: PreOpen, To: Closed}, ob.StopTrading(assetId2))
+	assert.Equal(t, Closed, ob.GetPhase(assetId2))
+}
+
+func TestAcceptsOrders(t *testing.T) {
+	assert.True(t, AcceptsOrders(PreOpen))
+	assert.True(t, AcceptsOrders(Auction))
+	assert.True(t, AcceptsOrders(Continuous))
+	assert.False(t, AcceptsOrders(Closed))
+	assert.False(t, AcceptsOrders(Halted))
+	assert.False(t, AcceptsOrders(PostClose))
+}
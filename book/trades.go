@@ -0,0 +1,23 @@
+package book
+
+import (
+	"stockexchange/store"
+)
+
+// AddTradeListener registers a listener notified of every trade executed in any order book.
+// Listeners are called while the order book of the trade is locked, so they must not call back into OrderBooks.
+// Listeners should be registered before orders are processed.
+func (ob *OrderBooks) AddTradeListener(listener func(store.Trade)) {
+	ob.tradeListeners = append(ob.tradeListeners, listener)
+}
+
+// publishTrade notifies all trade listeners of a trade executed by a command
+// It stamps the trade with the time of the command, the next event number of the command and the fees first.
+func (ob *OrderBooks) publishTrade(trade store.Trade, cmd *store.Command) {
+	trade.ExecutedAt = cmd.At
+	trade.Seq = cmd.NextEvent()
+	trade.BuyFee, trade.SellFee = ob.Fees.tradeFees(trade)
+	for _, listener := range ob.tradeListeners {
+		listener(trade)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersion prefixes the paths of the version of the api the client calls
+const apiVersion = "/v1"
+
+// Client calls the http api of the exchange, signing requests when it has an api key
+type Client struct {
+	baseURL   string
+	apiKey    string
+	apiSecret string
+	http      *http.Client
+	now       func() time.Time
+}
+
+func newClient(baseURL string, apiKey string, apiSecret string) *Client {
+	return &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		http:      &http.Client{Timeout: 10 * time.Second},
+		now:       time.Now,
+	}
+}
+
+// APIError is a response of the api with an error code
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
+}
+
+// do sends a request with a JSON body, if body isn't nil, and returns the body of the response.
+// It returns an *APIError if the response has an error code.
+func (c *Client) do(method string, path string, query url.Values, body interface{}) ([]byte, error) {
+	requestURI := apiVersion + path
+	if len(query) > 0 {
+		requestURI += "?" + query.Encode()
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+requestURI, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		timestamp := strconv.FormatInt(c.now().Unix(), 10)
+		req.Header.Set("X-API-Key", c.apiKey)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", sign(c.apiSecret, timestamp, method, requestURI, payload))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &APIError{Code: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	return data, nil
+}
+
+// sign returns the signature of a request, the hex encoded HMAC-SHA256 of its timestamp, method, request URI and body
+func sign(secret string, timestamp string, method string, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n", timestamp, method, requestURI)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// place places an order
+func (c *cli) place(args []string) error {
+	fs := c.flagSet("place", "-asset <assetId> -side buy|sell -size <size> -limit <cents> [-tif GTC|IOC|FOK] [-client-id <id>]")
+	asset := fs.String("asset", "", "asset to trade")
+	side := fs.String("side", "", "buy or sell")
+	size := fs.Int("size", 0, "number of assets")
+	limit := fs.Int("limit", 0, "limit price, in Usd cents")
+	tif := fs.String("tif", "", "optional time in force, GTC by default, IOC or FOK")
+	clientId := fs.String("client-id", "", "optional client order id, makes retries safe")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	buyOrSell, err := parseSide(*side)
+	if err != nil {
+		return err
+	}
+
+	or := orderReq{ClientOrderId: *clientId, AssetId: *asset, Size: *size, Limit: *limit, BuyOrSell: buyOrSell, TimeInForce: strings.ToUpper(*tif)}
+	return c.placeOrder(or)
+}
+
+func (c *cli) placeOrder(or orderReq) error {
+	path, err := c.userPath("/orders")
+	if err != nil {
+		return err
+	}
+	data, err := c.client.do("POST", path, nil, or)
+	if err != nil {
+		return err
+	}
+	return c.printOrders(data, false)
+}
+
+// cancel cancels an order by id or client order id, or every working order of the user
+func (c *cli) cancel(args []string) error {
+	fs := c.flagSet("cancel", "<orderId> | -client-id <id> | -all [-asset <assetId>] [-side buy|sell]")
+	clientId := fs.String("client-id", "", "cancel the order with a client order id")
+	all := fs.Bool("all", false, "cancel every working order, optionally only of -asset and -side")
+	asset := fs.String("asset", "", "with -all, only cancel orders of an asset")
+	side := fs.String("side", "", "with -all, only cancel orders of a side")
+	positional, err := parseArgs(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	switch {
+	case *all:
+		query, err := orderQuery(*asset, *side)
+		if err != nil {
+			return err
+		}
+		if path, err = c.userPath("/orders"); err != nil {
+			return err
+		}
+		data, err := c.client.do("DELETE", path, query, nil)
+		if err != nil {
+			return err
+		}
+		return c.printOrders(data, true)
+	case *clientId != "":
+		path, err = c.userPath("/client-orders/%s", url.PathEscape(*clientId))
+	case len(positional) == 1:
+		path, err = c.userPath("/orders/%s", url.PathEscape(positional[0]))
+	default:
+		fs.Usage()
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	// the cancel has no response, the order shows if it was canceled or had already been filled
+	if _, err := c.client.do("DELETE", path, nil, nil); err != nil {
+		return err
+	}
+	data, err := c.client.do("GET", path, nil, nil)
+	if err != nil {
+		return err
+	}
+	return c.printOrder(data)
+}
+
+// amend amends the limit or size of a working order
+func (c *cli) amend(args []string) error {
+	fs := c.flagSet("amend", "<orderId> [-limit <cents>] [-size <size>]")
+	limit := fs.Int("limit", 0, "new limit price, in Usd cents")
+	size := fs.Int("size", 0, "new number of assets, the remaining size is kept if not set")
+	positional, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *limit == 0 && *size == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	path, err := c.userPath("/orders/%s", url.PathEscape(positional[0]))
+	if err != nil {
+		return err
+	}
+	data, err := c.client.do("PATCH", path, nil, amendOrderReq{Limit: *limit, Size: *size})
+	if err != nil {
+		return err
+	}
+	return c.printOrders(data, false)
+}
+
+// orders lists the user's orders
+func (c *cli) orders(args []string) error {
+	fs := c.flagSet("orders", "[-status <status>] [-asset <assetId>] [-side buy|sell] [-limit <n>] [-desc]")
+	status := fs.String("status", "active", "active, complete, canceled, partially_filled, rejected or all")
+	asset := fs.String("asset", "", "only orders of an asset")
+	side := fs.String("side", "", "only orders of a side")
+	limit := fs.Int("limit", 100, "max number of orders")
+	desc := fs.Bool("desc", false, "newest orders first")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	query, err := orderQuery(*asset, *side)
+	if err != nil {
+		return err
+	}
+	query.Set("status", *status)
+	query.Set("limit", strconv.Itoa(*limit))
+	if *desc {
+		query.Set("sort", "desc")
+	}
+	path, err := c.userPath("/orders")
+	if err != nil {
+		return err
+	}
+	data, err := c.client.do("GET", path, query, nil)
+	if err != nil {
+		return err
+	}
+	return c.printOrders(data, true)
+}
+
+// order shows an order with its fills
+func (c *cli) order(args []string) error {
+	fs := c.flagSet("order", "<orderId> | -client-id <id>")
+	clientId := fs.String("client-id", "", "show the latest order with a client order id")
+	positional, err := parseArgs(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	if *clientId != "" {
+		path, err = c.userPath("/client-orders/%s", url.PathEscape(*clientId))
+	} else if len(positional) == 1 {
+		path, err = c.userPath("/orders/%s", url.PathEscape(positional[0]))
+	} else {
+		fs.Usage()
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	data, err := c.client.do("GET", path, nil, nil)
+	if err != nil {
+		return err
+	}
+	return c.printOrder(data)
+}
+
+// trades lists the latest fills of the user's orders
+func (c *cli) trades(args []string) error {
+	fs := c.flagSet("trades", "[-asset <assetId>] [-side buy|sell] [-limit <n>]")
+	asset := fs.String("asset", "", "only fills of an asset")
+	side := fs.String("side", "", "only fills of a side")
+	limit := fs.Int("limit", 100, "max number of fills")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	query, err := orderQuery(*asset, *side)
+	if err != nil {
+		return err
+	}
+	query.Set("limit", strconv.Itoa(*limit))
+	path, err := c.userPath("/trades")
+	if err != nil {
+		return err
+	}
+	data, err := c.client.do("GET", path, query, nil)
+	if err != nil {
+		return err
+	}
+	return c.printTrades(data)
+}
+
+// balances shows the user's available and reserved cash and assets
+func (c *cli) balances(args []string) error {
+	fs := c.flagSet("balances", "")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	path, err := c.userPath("/balances")
+	if err != nil {
+		return err
+	}
+	data, err := c.client.do("GET", path, nil, nil)
+	if err != nil {
+		return err
+	}
+	return c.printBalances(data)
+}
+
+// depth prints the depth ladder of an asset, once or every -watch interval until interrupted
+func (c *cli) depth(args []string) error {
+	fs := c.flagSet("depth", "<assetId> [-levels <n>] [-watch <interval>]")
+	levels := fs.Int("levels", 10, "number of price levels of each side")
+	watch := fs.Duration("watch", 0, "refresh the ladder every interval, e.g 1s, until interrupted")
+	positional, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	path := "/assets/" + url.PathEscape(positional[0]) + "/depth"
+	query := url.Values{"levels": {strconv.Itoa(*levels)}}
+	if *watch <= 0 {
+		data, err := c.client.do("GET", path, query, nil)
+		if err != nil {
+			return err
+		}
+		return c.printDepth(data, false)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ticker := time.NewTicker(*watch)
+	defer ticker.Stop()
+	for {
+		data, err := c.client.do("GET", path, query, nil)
+		if err != nil {
+			return err
+		}
+		if err := c.printDepth(data, true); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// bulk places the orders of a CSV file, see readOrdersCSV
+func (c *cli) bulk(args []string) error {
+	fs := c.flagSet("bulk", "<file.csv>")
+	positional, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(positional[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	ors, err := readOrdersCSV(file)
+	if err != nil {
+		return err
+	}
+	path, err := c.userPath("/orders")
+	if err != nil {
+		return err
+	}
+
+	var results []bulkResult
+	failed := 0
+	for i, or := range ors {
+		result := bulkResult{Row: i + 1, Order: or}
+		data, err := c.client.do("POST", path, nil, or)
+		result.Accepted = data
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if err := c.printBulkResults(results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d orders failed", failed, len(ors))
+	}
+	return nil
+}
+
+// orderQuery returns the query selecting orders of an asset and side, both optional
+func orderQuery(asset string, side string) (url.Values, error) {
+	query := url.Values{}
+	if asset != "" {
+		query.Set("asset_id", asset)
+	}
+	if side != "" {
+		buyOrSell, err := parseSide(side)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("side", buyOrSell)
+	}
+	return query, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// bulkResult is the result of placing an order of a CSV file
+type bulkResult struct {
+	Row      int             `json:"row"`                // row of the order in the file, the header isn't counted
+	Order    orderReq        `json:"order"`              // order placed
+	Accepted json.RawMessage `json:"accepted,omitempty"` // the accepted order returned by the api
+	Error    string          `json:"error,omitempty"`    // why the order was rejected
+}
+
+// readOrdersCSV reads orders from a CSV file with the columns asset_id,side,size,limit and an optional
+// client_order_id, e.g
+//
+//	asset_id,side,size,limit,client_order_id
+//	COIN,buy,10,100,bid-1
+//	COIN,sell,10,105,ask-1
+//
+// The header is optional. Every row is checked before any order is placed.
+func readOrdersCSV(r io.Reader) ([]orderReq, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && strings.EqualFold(records[0][0], "asset_id") {
+		records = records[1:]
+	}
+
+	ors := make([]orderReq, 0, len(records))
+	for i, record := range records {
+		or, err := parseOrderRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i+1, err)
+		}
+		ors = append(ors, or)
+	}
+	return ors, nil
+}
+
+func parseOrderRecord(record []string) (orderReq, error) {
+	if len(record) < 4 || len(record) > 5 {
+		return orderReq{}, fmt.Errorf("expected the columns asset_id,side,size,limit[,client_order_id], got %d columns", len(record))
+	}
+	side, err := parseSide(record[1])
+	if err != nil {
+		return orderReq{}, err
+	}
+	size, err := strconv.Atoi(record[2])
+	if err != nil {
+		return orderReq{}, fmt.Errorf("invalid size %q", record[2])
+	}
+	limit, err := strconv.Atoi(record[3])
+	if err != nil {
+		return orderReq{}, fmt.Errorf("invalid limit %q", record[3])
+	}
+
+	or := orderReq{AssetId: record[0], BuyOrSell: side, Size: size, Limit: limit}
+	if len(record) == 5 {
+		or.ClientOrderId = record[4]
+	}
+	return or, nil
+}
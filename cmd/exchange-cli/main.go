@@ -0,0 +1,148 @@
+// exchange-cli is a command line client of the exchange's http api.
+//
+//	exchange-cli [-url url] [-user userId] [-api-key key -api-secret secret] [-json] <command> [flags]
+//
+// Run it with -h to list the commands.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+)
+
+const usage = `usage: exchange-cli [flags] <command> [flags]
+
+commands:
+  place     place an order, e.g place -asset COIN -side buy -size 10 -limit 100
+  cancel    cancel an order by id, by -client-id, or every working order with -all
+  amend     amend the -limit or -size of a working order
+  orders    list the user's orders
+  order     show an order with its fills
+  trades    list the fills of the user's orders, newest first
+  balances  show the user's available and reserved cash and assets
+  depth     print the depth ladder of an asset, refreshed every -watch interval
+  bulk      place the orders of a CSV file with the columns asset_id,side,size,limit[,client_order_id]
+
+Run exchange-cli <command> -h for the flags of a command.
+
+flags:
+`
+
+// cli runs the commands of a user against the api
+type cli struct {
+	client *Client
+	user   string
+	json   bool // print the JSON responses of the api instead of tables
+	out    io.Writer
+	errOut io.Writer
+}
+
+// errUsage is returned for invalid arguments, after the usage is printed
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Getenv, os.Stdout, os.Stderr))
+}
+
+// run runs a command and returns the exit code, 1 if it failed and 2 if its arguments are invalid
+func run(args []string, getenv func(string) string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("exchange-cli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	baseURL := fs.String("url", envOr(getenv, "EXCHANGE_URL", "http://localhost:9093"), "url of the exchange, env EXCHANGE_URL")
+	user := fs.String("user", getenv("EXCHANGE_USER"), "id of the user, env EXCHANGE_USER")
+	apiKey := fs.String("api-key", getenv("EXCHANGE_API_KEY"), "api key signing requests, env EXCHANGE_API_KEY")
+	apiSecret := fs.String("api-secret", getenv("EXCHANGE_API_SECRET"), "secret of the api key, env EXCHANGE_API_SECRET")
+	jsonOutput := fs.Bool("json", false, "print the JSON responses of the api")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	c := &cli{client: newClient(*baseURL, *apiKey, *apiSecret), user: *user, json: *jsonOutput, out: stdout, errOut: stderr}
+	command, commandArgs := fs.Arg(0), fs.Args()[1:]
+	commands := map[string]func([]string) error{
+		"place":    c.place,
+		"cancel":   c.cancel,
+		"amend":    c.amend,
+		"orders":   c.orders,
+		"order":    c.order,
+		"trades":   c.trades,
+		"balances": c.balances,
+		"depth":    c.depth,
+		"bulk":     c.bulk,
+	}
+	runCommand, ok := commands[command]
+	if !ok {
+		fmt.Fprintf(stderr, "exchange-cli: unknown command %q\n", command)
+		fs.Usage()
+		return 2
+	}
+
+	err := runCommand(commandArgs)
+	if errors.Is(err, errUsage) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "exchange-cli %s: %v\n", command, err)
+		return 1
+	}
+	return 0
+}
+
+// flagSet returns the flag set of a command, args describes its flags and arguments in its usage
+func (c *cli) flagSet(command string, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(c.errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: exchange-cli %s %s\n", command, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// userPath returns the path of a resource of the cli's user, its user id is escaped
+func (c *cli) userPath(format string, a ...interface{}) (string, error) {
+	if c.user == "" {
+		return "", errors.New("the user is required, set -user or EXCHANGE_USER")
+	}
+	return "/users/" + url.PathEscape(c.user) + fmt.Sprintf(format, a...), nil
+}
+
+func envOr(getenv func(string) string, key string, def string) string {
+	if value := getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// parseArgs parses the flags of a command and returns its positional arguments, there must be between min and max of them.
+// Invalid flags are reported by the flag set, which prints the usage, and return errUsage.
+func parseArgs(fs *flag.FlagSet, args []string, min int, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	// flags may also follow the positional arguments, e.g amend <orderId> -limit 100
+	var positional []string
+	for rest := fs.Args(); len(rest) > 0; rest = fs.Args() {
+		positional = append(positional, rest[0])
+		if err := fs.Parse(rest[1:]); err != nil {
+			return nil, errUsage
+		}
+	}
+	if len(positional) < min || len(positional) > max {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return positional, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// request is a request received by the fake exchange
+type request struct {
+	method     string
+	requestURI string
+	body       string
+	header     http.Header
+}
+
+// fakeExchange returns a server replying to every request with the response of its path, and the requests it received
+func fakeExchange(t *testing.T, responses map[string]string) (*httptest.Server, *[]request) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, request{method: r.Method, requestURI: r.URL.RequestURI(), body: string(body), header: r.Header})
+		response, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func runCLI(server *httptest.Server, args ...string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	env := map[string]string{"EXCHANGE_URL": server.URL, "EXCHANGE_USER": "user1"}
+	code := run(args, func(key string) string { return env[key] }, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestPlace(t *testing.T) {
+	server, requests := fakeExchange(t, map[string]string{
+		"POST /v1/users/user1/orders": `{"order_id": "o1", "client_order_id": "bid-1", "asset_id": "COIN", "size": 10, "limit": 100, "buy_or_sell": "BUY"}`,
+	})
+
+	out, _, code := runCLI(server, "-api-key", "key1", "-api-secret", "secret1", "place", "-asset", "COIN", "-side", "buy", "-size", "10", "-limit", "100", "-client-id", "bid-1")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "o1")
+	assert.Contains(t, out, "bid-1")
+
+	req := (*requests)[0]
+	assert.Equal(t, "POST", req.method)
+	assert.JSONEq(t, `{"client_order_id": "bid-1", "asset_id": "COIN", "size": 10, "limit": 100, "buy_or_sell": "BUY"}`, req.body)
+	assert.Equal(t, "key1", req.header.Get("X-API-Key"))
+	assert.Equal(t, sign("secret1", req.header.Get("X-Timestamp"), "POST", "/v1/users/user1/orders", []byte(req.body)), req.header.Get("X-Signature"))
+
+	out, _, code = runCLI(server, "-json", "place", "-asset", "COIN", "-side", "sell", "-size", "10", "-limit", "100")
+	assert.Equal(t, 0, code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(out), &resp))
+	assert.Equal(t, "o1", resp["order_id"])
+	assert.Empty(t, (*requests)[1].header.Get("X-API-Key")) // requests aren't signed without a key
+
+	_, _, code = runCLI(server, "place", "-asset", "COIN", "-side", "b", "-size", "10", "-limit", "100", "-tif", "ioc")
+	assert.Equal(t, 0, code)
+	assert.JSONEq(t, `{"asset_id": "COIN", "size": 10, "limit": 100, "buy_or_sell": "BUY", "time_in_force": "IOC"}`, (*requests)[2].body)
+
+	// the user id is escaped in the path, and signed as sent
+	_, _, code = runCLI(server, "-api-key", "key1", "-api-secret", "secret1", "-user", "user 1/a", "place", "-asset", "COIN", "-side", "buy", "-size", "10", "-limit", "100")
+	assert.Equal(t, 1, code) // not found by the fake exchange
+	req = (*requests)[3]
+	assert.Equal(t, "/v1/users/user%201%2Fa/orders", req.requestURI)
+	assert.Equal(t, sign("secret1", req.header.Get("X-Timestamp"), "POST", req.requestURI, []byte(req.body)), req.header.Get("X-Signature"))
+
+	_, _, code = runCLI(server, "place", "-asset", "COIN", "-side", "hold", "-size", "10", "-limit", "100")
+	assert.Equal(t, 1, code)
+	_, _, code = runCLI(server, "place", "-size", "lots")
+	assert.Equal(t, 2, code)
+	_, _, code = runCLI(server, "unknown")
+	assert.Equal(t, 2, code)
+}
+
+func TestCancelAndAmend(t *testing.T) {
+	server, requests := fakeExchange(t, map[string]string{
+		"DELETE /v1/users/user1/orders/o1": ``,
+		"GET /v1/users/user1/orders/o1":    `{"order_id": "o1", "asset_id": "COIN", "size": 10, "limit": 100, "status": "CANCELED", "reason": "canceled by user"}`,
+		"DELETE /v1/users/user1/orders":    `[{"order_id": "o2", "asset_id": "COIN", "status": "CANCELED"}]`,
+		"PATCH /v1/users/user1/orders/o3":  `{"order_id": "o4", "asset_id": "COIN", "size": 5, "limit": 95}`,
+	})
+
+	out, _, code := runCLI(server, "cancel", "o1")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "CANCELED")
+	assert.Contains(t, out, "canceled by user")
+
+	_, _, code = runCLI(server, "cancel", "-all", "-asset", "COIN", "-side", "sell")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "/v1/users/user1/orders?asset_id=COIN&side=SELL", (*requests)[2].requestURI)
+
+	out, _, code = runCLI(server, "amend", "o3", "-limit", "95")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "o4")
+	assert.JSONEq(t, `{"limit": 95}`, (*requests)[3].body)
+
+	_, stderr, code := runCLI(server, "cancel", "unknown")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "404")
+	_, _, code = runCLI(server, "amend", "o3")
+	assert.Equal(t, 2, code) // nothing to amend
+}
+
+func TestDepth(t *testing.T) {
+	server, _ := fakeExchange(t, map[string]string{
+		"GET /v1/assets/COIN/depth": `{"asset_id": "COIN", "phase": "CONTINUOUS", "bids": [{"price": 99, "size": 6, "orders": 2}], "asks": [{"price": 101, "size": 3, "orders": 1}, {"price": 103, "size": 5, "orders": 1}]}`,
+	})
+
+	out, _, code := runCLI(server, "depth", "COIN", "-levels", "5")
+	assert.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, "COIN CONTINUOUS", lines[0])
+	assert.Equal(t, 6, len(lines))
+	assert.Contains(t, lines[2], "103") // worst ask first
+	assert.Contains(t, lines[3], "101")
+	assert.Contains(t, lines[4], "spread 2")
+	assert.Contains(t, lines[5], "99")
+}
+
+func TestBulk(t *testing.T) {
+	server, requests := fakeExchange(t, map[string]string{
+		"POST /v1/users/user1/orders": `{"order_id": "o1"}`,
+	})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orders.csv")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("asset_id,side,size,limit,client_order_id\nCOIN,buy,10,100,bid-1\nCOIN,sell,10,105\n"), 0644))
+
+	out, _, code := runCLI(server, "bulk", path)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "placed 2 of 2 orders")
+	assert.Equal(t, 2, len(*requests))
+	assert.JSONEq(t, `{"asset_id": "COIN", "size": 10, "limit": 105, "buy_or_sell": "SELL"}`, (*requests)[1].body)
+
+	// every row is checked before any order is placed
+	assert.NoError(t, ioutil.WriteFile(path, []byte("COIN,buy,10,100\nCOIN,sell,ten,105\n"), 0644))
+	_, stderr, code := runCLI(server, "bulk", path)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "row 2")
+	assert.Equal(t, 2, len(*requests))
+}
+
+func TestReadOrdersCSV(t *testing.T) {
+	ors, err := readOrdersCSV(strings.NewReader("COIN, b, 10, 100\nGAME,SELL,5,20,ask-1\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []orderReq{
+		{AssetId: "COIN", BuyOrSell: buy, Size: 10, Limit: 100},
+		{AssetId: "GAME", BuyOrSell: sell, Size: 5, Limit: 20, ClientOrderId: "ask-1"},
+	}, ors)
+
+	_, err = readOrdersCSV(strings.NewReader("COIN,buy,10\n"))
+	assert.Error(t, err)
+	_, err = readOrdersCSV(strings.NewReader("COIN,buy,10,cheap\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+// Output
+//
+// Responses are printed as tables, or as the JSON returned by the api with -json so the output can be
+// piped to other tools, e.g jq.
+
+// clearScreen moves the cursor home and clears the terminal, to redraw a live view
+const clearScreen = "\033[H\033[2J"
+
+// printJSON prints the JSON of a response indented
+func (c *cli) printJSON(data []byte) error {
+	var b bytes.Buffer
+	if err := json.Indent(&b, data, "", "  "); err != nil {
+		return err
+	}
+	b.WriteString("\n")
+	_, err := c.out.Write(b.Bytes())
+	return err
+}
+
+// printOrders prints an order, or a list of orders if list
+func (c *cli) printOrders(data []byte, list bool) error {
+	if c.json {
+		return c.printJSON(data)
+	}
+
+	var orders []order
+	if list {
+		if err := json.Unmarshal(data, &orders); err != nil {
+			return err
+		}
+	} else {
+		orders = make([]order, 1)
+		if err := json.Unmarshal(data, &orders[0]); err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ORDER_ID\tCLIENT_ORDER_ID\tASSET\tSIDE\tSIZE\tLIMIT\tFILLED\tSTATUS\tCREATED_AT")
+	for _, o := range orders {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\n", o.OrderId, orEmpty(o.ClientOrderId), o.AssetId, o.BuyOrSell,
+			o.Size, o.Limit, o.Filled, orEmpty(o.Status), formatTime(o.EventAt))
+	}
+	return w.Flush()
+}
+
+// printOrder prints an order with its fills
+func (c *cli) printOrder(data []byte) error {
+	if c.json {
+		return c.printJSON(data)
+	}
+
+	var o order
+	if err := json.Unmarshal(data, &o); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "order id:\t%s\n", o.OrderId)
+	if o.ClientOrderId != "" {
+		fmt.Fprintf(w, "client order id:\t%s\n", o.ClientOrderId)
+	}
+	fmt.Fprintf(w, "order:\t%s %d %s @ %d\n", o.BuyOrSell, o.Size, o.AssetId, o.Limit)
+	fmt.Fprintf(w, "status:\t%s\n", o.Status)
+	if o.Reason != "" {
+		fmt.Fprintf(w, "reason:\t%s\n", o.Reason)
+	}
+	fmt.Fprintf(w, "filled:\t%d, %d remaining, average price %.2f\n", o.Filled, o.Remaining, o.AvgFillPrice)
+	fmt.Fprintf(w, "created at:\t%s\n", formatTime(o.EventAt))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(o.Fills) == 0 {
+		return nil
+	}
+	fmt.Fprintln(c.out)
+	w = tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRICE\tSIZE\tFEE\tEXECUTED_AT")
+	for _, f := range o.Fills {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\n", f.Price, f.Size, f.Fee, formatTime(f.ExecutedAt))
+	}
+	return w.Flush()
+}
+
+// printTrades prints the fills of a user's orders
+func (c *cli) printTrades(data []byte) error {
+	if c.json {
+		return c.printJSON(data)
+	}
+
+	var trades []trade
+	if err := json.Unmarshal(data, &trades); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EXECUTED_AT\tORDER_ID\tCLIENT_ORDER_ID\tASSET\tSIDE\tSIZE\tPRICE\tFEE")
+	for _, t := range trades {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\n", formatTime(t.ExecutedAt), t.OrderId, orEmpty(t.ClientOrderId), t.AssetId,
+			t.BuyOrSell, t.Size, t.Price, t.Fee)
+	}
+	return w.Flush()
+}
+
+// printBalances prints a user's available and reserved cash and assets
+func (c *cli) printBalances(data []byte) error {
+	if c.json {
+		return c.printJSON(data)
+	}
+
+	var b balances
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ASSET\tAVAILABLE\tRESERVED")
+	fmt.Fprintf(w, "cash\t%d\t%d\n", b.Cash, b.ReservedCash)
+	for _, asset := range b.Assets {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", asset.AssetId, asset.Available, asset.Reserved)
+	}
+	return w.Flush()
+}
+
+// printDepth prints the depth ladder of an asset, asks above bids with the best prices in the middle.
+// With redraw, the screen is cleared first in the human output, and JSON is printed on a single line.
+func (c *cli) printDepth(data []byte, redraw bool) error {
+	if c.json && redraw {
+		var b bytes.Buffer
+		if err := json.Compact(&b, data); err != nil {
+			return err
+		}
+		b.WriteString("\n")
+		_, err := c.out.Write(b.Bytes())
+		return err
+	}
+	if c.json {
+		return c.printJSON(data)
+	}
+
+	var d depth
+	if err := json.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	if redraw {
+		fmt.Fprint(c.out, clearScreen)
+	}
+	fmt.Fprintf(c.out, "%s %s\n", d.AssetId, d.Phase)
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "ORDERS\tBID SIZE\tPRICE\tASK SIZE\tORDERS\t")
+	for i := len(d.Asks) - 1; i >= 0; i-- {
+		fmt.Fprintf(w, "\t\t%d\t%d\t%d\t\n", d.Asks[i].Price, d.Asks[i].Size, d.Asks[i].Orders)
+	}
+	if len(d.Asks) > 0 && len(d.Bids) > 0 {
+		fmt.Fprintf(w, "\t\t%s\t\t\t\n", fmt.Sprintf("spread %d", d.Asks[0].Price-d.Bids[0].Price))
+	}
+	for _, level := range d.Bids {
+		fmt.Fprintf(w, "%d\t%d\t%d\t\t\t\n", level.Orders, level.Size, level.Price)
+	}
+	return w.Flush()
+}
+
+// printBulkResults prints the result of every order of a CSV file
+func (c *cli) printBulkResults(results []bulkResult) error {
+	if c.json {
+		data, err := json.Marshal(results)
+		if err != nil {
+			return err
+		}
+		return c.printJSON(data)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROW\tASSET\tSIDE\tSIZE\tLIMIT\tCLIENT_ORDER_ID\tRESULT")
+	placed := 0
+	for _, result := range results {
+		outcome := "error: " + result.Error
+		if result.Error == "" {
+			var o order
+			json.Unmarshal(result.Accepted, &o)
+			outcome = "placed " + o.OrderId
+			placed++
+		}
+		or := result.Order
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%s\n", result.Row, or.AssetId, or.BuyOrSell, or.Size, or.Limit,
+			orEmpty(or.ClientOrderId), outcome)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "placed %d of %d orders\n", placed, len(results))
+	return nil
+}
+
+// orEmpty returns "-" for empty values so they're visible in a table
+func orEmpty(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
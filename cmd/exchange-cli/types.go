@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Requests and responses of the http api, prices are in Usd cents
+
+// sides of an order in the api
+const (
+	buy  = "BUY"
+	sell = "SELL"
+)
+
+type orderReq struct {
+	ClientOrderId string `json:"client_order_id,omitempty"`
+	AssetId       string `json:"asset_id"`
+	Size          int    `json:"size"`
+	Limit         int    `json:"limit"`
+	BuyOrSell     string `json:"buy_or_sell"`
+	TimeInForce   string `json:"time_in_force,omitempty"`
+}
+
+type amendOrderReq struct {
+	Limit int `json:"limit,omitempty"`
+	Size  int `json:"size,omitempty"`
+}
+
+type order struct {
+	OrderId       string    `json:"order_id"`
+	ClientOrderId string    `json:"client_order_id"`
+	AssetId       string    `json:"asset_id"`
+	Limit         int       `json:"limit"`
+	Size          int       `json:"size"`
+	BuyOrSell     string    `json:"buy_or_sell"`
+	EventAt       time.Time `json:"event_at"`
+	Status        string    `json:"status"`
+	Filled        int       `json:"filled"`
+	Remaining     int       `json:"remaining"`
+	AvgFillPrice  float64   `json:"avg_fill_price"`
+	Reason        string    `json:"reason"`
+	Fills         []fill    `json:"fills"`
+}
+
+type fill struct {
+	Price      int       `json:"price"`
+	Size       int       `json:"size"`
+	Fee        int       `json:"fee"`
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
+type trade struct {
+	OrderId       string    `json:"order_id"`
+	ClientOrderId string    `json:"client_order_id"`
+	AssetId       string    `json:"asset_id"`
+	BuyOrSell     string    `json:"buy_or_sell"`
+	Price         int       `json:"price"`
+	Size          int       `json:"size"`
+	Fee           int       `json:"fee"`
+	ExecutedAt    time.Time `json:"executed_at"`
+}
+
+type balances struct {
+	UserId       string `json:"user_id"`
+	Cash         int    `json:"cash"`
+	ReservedCash int    `json:"reserved_cash"`
+	Assets       []struct {
+		AssetId   string `json:"asset_id"`
+		Available int    `json:"available"`
+		Reserved  int    `json:"reserved"`
+	} `json:"assets"`
+}
+
+type depth struct {
+	AssetId string       `json:"asset_id"`
+	Phase   string       `json:"phase"`
+	Bids    []priceLevel `json:"bids"`
+	Asks    []priceLevel `json:"asks"`
+}
+
+type priceLevel struct {
+	Price  int `json:"price"`
+	Size   int `json:"size"`
+	Orders int `json:"orders"`
+}
+
+// parseSide parses the side of an order, buy or sell
+func parseSide(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "buy", "b":
+		return buy, nil
+	case "sell", "s":
+		return sell, nil
+	}
+	return "", fmt.Errorf("invalid side %q, must be buy or sell", value)
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"stockexchange/book"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+// Configuration
+//
+// The exchange is configured with a YAML file, see config.example.yaml. Settings of the file can be
+// overridden with environment variables and flags, flags take precedence over environment variables
+// and environment variables over the file. Settings not set anywhere keep their default.
+
+// Config configures the exchange server
+type Config struct {
+	Server         ServerConfig            `yaml:"server"`
+	Engine         EngineConfig            `yaml:"engine"`
+	CircuitBreaker CircuitBreakerConfig    `yaml:"circuit_breaker"`
+	Persistence    PersistenceConfig       `yaml:"persistence"`
+	Logging        LoggingConfig           `yaml:"logging"`
+	APIKeysFile    string                  `yaml:"api_keys_file"`    // JSON file with the api keys allowed to use the api, required unless InsecureNoAuth is set
+	InsecureNoAuth bool                    `yaml:"insecure_no_auth"` // run without authentication if there is no api keys file, every request can act as any user or admin
+	RateLimitsFile string                  `yaml:"rate_limits_file"` // JSON file with the rate limit tiers of users
+	RiskLimitsFile string                  `yaml:"risk_limits_file"` // JSON file with the pre-trade risk limits of users
+	ScheduleFile   string                  `yaml:"schedule_file"`    // JSON file with the trading session schedule of each asset
+	SeedFile       string                  `yaml:"seed_file"`        // YAML or JSON fixture the exchange is seeded with at startup
+	Fees           book.FeeSchedule        `yaml:"fees"`
+	Instruments    []engine.Instrument     `yaml:"instruments"` // instruments listed at startup, orders of any asset are accepted if empty
+	Users          []store.InitExchangeReq `yaml:"users"`       // users created at startup
+}
+
+type ServerConfig struct {
+	Listen          string        `yaml:"listen"`           // address the http api listens on
+	TLS             TLSConfig     `yaml:"tls"`              // serve https if set
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // how long to wait for in-flight requests and queued orders on shutdown
+	NumericSides    bool          `yaml:"numeric_sides"`    // accept and emit the sides of orders as 0 and 1 instead of BUY and SELL, for older clients
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// Enabled returns if https is configured
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type EngineConfig struct {
+	OrderQueueSize  int `yaml:"order_queue_size"`  // accepted orders queued for the matching engines, orders are rejected when it's full
+	EngineQueueSize int `yaml:"engine_queue_size"` // new orders queued for the matching engine of every asset, orders of the asset are rejected when it's full
+}
+
+type CircuitBreakerConfig struct {
+	BandBps       int           `yaml:"band_bps"`       // price band around the reference price in basis points, 0 disables the circuit breaker
+	HaltCooldown  time.Duration `yaml:"halt_cooldown"`  // how long an asset stays halted by the circuit breaker
+	ResumeAuction time.Duration `yaml:"resume_auction"` // call period of the auction resuming a halted asset
+}
+
+type PersistenceConfig struct {
+	SnapshotPath string `yaml:"snapshot_path"` // JSON file the state of the exchange is written to on shutdown, nothing is written if empty
+}
+
+type LoggingConfig struct {
+	Level string `yaml:"level"` // debug, info, warn or error
+}
+
+// defaultConfig returns the config of settings that aren't set
+func defaultConfig() Config {
+	return Config{
+		Server: ServerConfig{
+			Listen:          "0.0.0.0:9093",
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Engine: EngineConfig{
+			OrderQueueSize:  engine.DefaultOrderQueueSize,
+			EngineQueueSize: engine.DefaultEngineQueueSize,
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			BandBps:       book.DefaultCircuitBreaker.BandBps,
+			HaltCooldown:  book.DefaultCircuitBreaker.Cooldown,
+			ResumeAuction: book.DefaultCircuitBreaker.AuctionPeriod,
+		},
+		Logging: LoggingConfig{Level: engine.InfoLevel.String()},
+	}
+}
+
+// configSetting is a setting of the config file that can be overridden by a flag and an environment variable
+type configSetting struct {
+	flag  string
+	env   string
+	usage string
+	field func(c *Config) interface{} // pointer to the setting in a config, a *string, *int, *bool or *time.Duration
+}
+
+var configSettings = []configSetting{
+	{"listen", "EXCHANGE_LISTEN", "address the http api listens on", func(c *Config) interface{} { return &c.Server.Listen }},
+	{"tls-cert", "EXCHANGE_TLS_CERT", "certificate file to serve https", func(c *Config) interface{} { return &c.Server.TLS.CertFile }},
+	{"tls-key", "EXCHANGE_TLS_KEY", "key file to serve https", func(c *Config) interface{} { return &c.Server.TLS.KeyFile }},
+	{"shutdown-timeout", "EXCHANGE_SHUTDOWN_TIMEOUT", "how long to wait for in-flight requests and queued orders on shutdown", func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+	{"numeric-sides", "EXCHANGE_NUMERIC_SIDES", "accept and emit the sides of orders as 0 and 1 instead of BUY and SELL, true or false", func(c *Config) interface{} { return &c.Server.NumericSides }},
+	{"order-queue-size", "EXCHANGE_ORDER_QUEUE_SIZE", "accepted orders queued for the matching engines", func(c *Config) interface{} { return &c.Engine.OrderQueueSize }},
+	{"engine-queue-size", "EXCHANGE_ENGINE_QUEUE_SIZE", "new orders queued for the matching engine of every asset", func(c *Config) interface{} { return &c.Engine.EngineQueueSize }},
+	{"band-bps", "EXCHANGE_BAND_BPS", "price band around the reference price in basis points, 0 disables the circuit breaker", func(c *Config) interface{} { return &c.CircuitBreaker.BandBps }},
+	{"halt-cooldown", "EXCHANGE_HALT_COOLDOWN", "how long an asset stays halted by the circuit breaker", func(c *Config) interface{} { return &c.CircuitBreaker.HaltCooldown }},
+	{"resume-auction", "EXCHANGE_RESUME_AUCTION", "call period of the auction resuming a halted asset", func(c *Config) interface{} { return &c.CircuitBreaker.ResumeAuction }},
+	{"snapshot", "EXCHANGE_SNAPSHOT", "path of the JSON file the state of the exchange is written to on shutdown", func(c *Config) interface{} { return &c.Persistence.SnapshotPath }},
+	{"log-level", "EXCHANGE_LOG_LEVEL", "level of the logs written, debug, info, warn or error", func(c *Config) interface{} { return &c.Logging.Level }},
+	{"api-keys", "EXCHANGE_API_KEYS", "path to a JSON file with the api keys allowed to use the api", func(c *Config) interface{} { return &c.APIKeysFile }},
+	{"insecure-no-auth", "EXCHANGE_INSECURE_NO_AUTH", "run without authentication if there are no api keys, every request can act as any user or admin", func(c *Config) interface{} { return &c.InsecureNoAuth }},
+	{"rate-limits", "EXCHANGE_RATE_LIMITS", "path to a JSON file with the rate limit tiers of users", func(c *Config) interface{} { return &c.RateLimitsFile }},
+	{"risk-limits", "EXCHANGE_RISK_LIMITS", "path to a JSON file with the pre-trade risk limits of users", func(c *Config) interface{} { return &c.RiskLimitsFile }},
+	{"schedule", "EXCHANGE_SCHEDULE", "path to a JSON file with the trading session schedule of each asset", func(c *Config) interface{} { return &c.ScheduleFile }},
+	{"seed", "EXCHANGE_SEED", "path to a YAML or JSON fixture of instruments, users and orders the exchange is seeded with", func(c *Config) interface{} { return &c.SeedFile }},
+}
+
+// set parses a value into the setting of a config
+func (cs configSetting) set(c *Config, value string) error {
+	var err error
+	switch field := cs.field(c).(type) {
+	case *string:
+		*field = value
+	case *int:
+		*field, err = strconv.Atoi(value)
+	case *bool:
+		*field, err = strconv.ParseBool(value)
+	case *time.Duration:
+		*field, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %v", cs.flag, value, err)
+	}
+	return nil
+}
+
+// boolFlag is a flag overriding a bool setting, it can be set without a value, e.g -insecure-no-auth
+type boolFlag struct {
+	value *string
+}
+
+func (f boolFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f boolFlag) Set(value string) error {
+	*f.value = value
+	return nil
+}
+
+func (f boolFlag) IsBoolFlag() bool {
+	return true
+}
+
+// errDumpConfig is returned by loadConfig when the effective config should be printed instead of starting the server
+var errDumpConfig = errors.New("dump config")
+
+// loadConfig returns the effective config of the command line arguments and environment variables.
+// The config file is set with the -config flag or the EXCHANGE_CONFIG environment variable.
+// It returns the config along with errDumpConfig if the -dump-config flag is set.
+func loadConfig(args []string, getenv func(string) string) (Config, error) {
+	fs := flag.NewFlagSet("exchange", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("EXCHANGE_CONFIG"), "path to a YAML config file, env EXCHANGE_CONFIG")
+	dump := fs.Bool("dump-config", false, "print the effective config as YAML and exit")
+	overrides := make(map[string]*string)
+	for _, setting := range configSettings {
+		value := new(string)
+		usage := fmt.Sprintf("%s, env %s", setting.usage, setting.env)
+		if _, ok := setting.field(&Config{}).(*bool); ok {
+			fs.Var(boolFlag{value}, setting.flag, usage)
+		} else {
+			fs.StringVar(value, setting.flag, "", usage)
+		}
+		overrides[setting.flag] = value
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	config := defaultConfig()
+	if *configPath != "" {
+		data, err := ioutil.ReadFile(*configPath)
+		if err != nil {
+			return Config{}, err
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil {
+			return Config{}, fmt.Errorf("invalid config file %s: %v", *configPath, err)
+		}
+	}
+
+	for _, setting := range configSettings {
+		if value := getenv(setting.env); value != "" {
+			if err := setting.set(&config, value); err != nil {
+				return Config{}, fmt.Errorf("env %s: %v", setting.env, err)
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, setting := range configSettings {
+			if setting.flag == f.Name && err == nil {
+				err = setting.set(&config, *overrides[f.Name])
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := config.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %v", err)
+	}
+	if *dump {
+		return config, errDumpConfig
+	}
+	return config, nil
+}
+
+// validate returns an error if a setting is invalid
+func (c Config) validate() error {
+	if c.Server.Listen == "" {
+		return errors.New("server.listen is required")
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		return errors.New("server.tls needs both a cert_file and a key_file")
+	}
+	if c.APIKeysFile == "" && !c.InsecureNoAuth {
+		return errors.New("api_keys_file is required, set insecure_no_auth to run without authentication")
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		return errors.New("server.shutdown_timeout can't be negative")
+	}
+	if c.Engine.OrderQueueSize <= 0 || c.Engine.EngineQueueSize <= 0 {
+		return errors.New("engine queue sizes must be positive")
+	}
+	if c.CircuitBreaker.BandBps < 0 || c.CircuitBreaker.HaltCooldown < 0 || c.CircuitBreaker.ResumeAuction < 0 {
+		return errors.New("circuit_breaker settings can't be negative")
+	}
+	if _, err := engine.ParseLogLevel(c.Logging.Level); err != nil {
+		return fmt.Errorf("logging.level: %v", err)
+	}
+	if err := c.Fees.Validate(); err != nil {
+		return fmt.Errorf("fees: %v", err)
+	}
+
+	assetIds := make(map[store.AssetId]bool)
+	for _, instrument := range c.Instruments {
+		if err := instrument.Validate(); err != nil {
+			return fmt.Errorf("instruments: %v", err)
+		}
+		if assetIds[instrument.AssetId] {
+			return fmt.Errorf("instruments: asset %s is listed twice", instrument.AssetId)
+		}
+		assetIds[instrument.AssetId] = true
+	}
+
+	userIds := make(map[store.UserId]bool)
+	for _, user := range c.Users {
+		if err := engine.ValidateInitExchangeReq(user); err != nil {
+			return fmt.Errorf("users: %v", err)
+		}
+		if userIds[user.UserId] {
+			return fmt.Errorf("users: user %s is defined twice", user.UserId)
+		}
+		userIds[user.UserId] = true
+	}
+	return nil
+}
+
+// dumpConfig returns the YAML of a config
+func dumpConfig(config Config) ([]byte, error) {
+	return yaml.Marshal(config)
+}
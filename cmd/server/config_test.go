@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"stockexchange/book"
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+func TestLoadConfig(t *testing.T) {
+	noEnv := func(string) string { return "" }
+
+	// the exchange doesn't run without authentication unless it's explicitly disabled
+	_, err := loadConfig(nil, noEnv)
+	assert.EqualError(t, err, "invalid config: api_keys_file is required, set insecure_no_auth to run without authentication")
+	config, err := loadConfig([]string{"-insecure-no-auth"}, noEnv)
+	assert.NoError(t, err)
+	expected := defaultConfig()
+	expected.InsecureNoAuth = true
+	assert.Equal(t, expected, config)
+	config, err = loadConfig([]string{"-api-keys", "keys.json"}, noEnv)
+	assert.NoError(t, err)
+	assert.False(t, config.InsecureNoAuth)
+
+	// the other settings are loaded without api keys
+	noEnv = func(key string) string {
+		if key == "EXCHANGE_INSECURE_NO_AUTH" {
+			return "true"
+		}
+		return ""
+	}
+
+	config, err = loadConfig([]string{"-config", "../../config.example.yaml"}, noEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, "state.json", config.Persistence.SnapshotPath)
+	assert.Equal(t, book.FeeRates{MakerBps: 5, TakerBps: 10}, config.Fees.Default)
+	assert.Equal(t, engine.Instrument{AssetId: "GAME", Name: "GameStop", Phase: book.PreOpen}, config.Instruments[1])
+	assert.Equal(t, store.InitExchangeReq{UserId: "user1", Cash: 100000, Assets: []store.Asset{{AssetId: "COIN", Size: 100}}}, config.Users[0])
+
+	// flags take precedence over environment variables, and environment variables over the file
+	env := map[string]string{"EXCHANGE_CONFIG": "../../config.example.yaml", "EXCHANGE_INSECURE_NO_AUTH": "true", "EXCHANGE_LISTEN": ":8080", "EXCHANGE_ORDER_QUEUE_SIZE": "500", "EXCHANGE_NUMERIC_SIDES": "true"}
+	config, err = loadConfig([]string{"-listen", ":9090", "-halt-cooldown", "10s"}, func(key string) string { return env[key] })
+	assert.NoError(t, err)
+	assert.Equal(t, ":9090", config.Server.Listen)
+	assert.Equal(t, 500, config.Engine.OrderQueueSize)
+	assert.True(t, config.Server.NumericSides)
+	assert.Equal(t, 10*time.Second, config.CircuitBreaker.HaltCooldown)
+	assert.Equal(t, "state.json", config.Persistence.SnapshotPath)
+
+	_, err = loadConfig([]string{"-order-queue-size", "lots"}, noEnv)
+	assert.Error(t, err)
+	_, err = loadConfig([]string{"-numeric-sides=maybe"}, noEnv)
+	assert.Error(t, err)
+	_, err = loadConfig([]string{"-order-queue-size", "0"}, noEnv)
+	assert.Error(t, err)
+	_, err = loadConfig([]string{"-tls-cert", "server.crt"}, noEnv)
+	assert.Error(t, err)
+	_, err = loadConfig([]string{"-log-level", "verbose"}, noEnv)
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("server:\n  listn: :9090\n"), 0644))
+	_, err = loadConfig([]string{"-config", path}, noEnv)
+	assert.Error(t, err) // unknown settings are rejected
+	assert.NoError(t, ioutil.WriteFile(path, []byte("users:\n  - user_id: user1\n  - user_id: user1\n"), 0644))
+	_, err = loadConfig([]string{"-config", path}, noEnv)
+	assert.Error(t, err)
+}
+
+func TestDumpConfig(t *testing.T) {
+	config, err := loadConfig([]string{"-config", "../../config.example.yaml", "-insecure-no-auth", "-dump-config"}, func(string) string { return "" })
+	assert.Equal(t, errDumpConfig, err)
+
+	// the dumped config loads back to the same config
+	data, err := dumpConfig(config)
+	assert.NoError(t, err)
+	var dumped Config
+	assert.NoError(t, yaml.Unmarshal(data, &dumped))
+	assert.Equal(t, config, dumped)
+}
+
+func TestNewExchange(t *testing.T) {
+	config, err := loadConfig([]string{"-config", "../../config.example.yaml", "-insecure-no-auth"}, func(string) string { return "" })
+	assert.NoError(t, err)
+	s, err := newExchange(config)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.Equal(t, store.Usd(100000), s.Store.GetUserData("user1").Cash)
+	assert.Equal(t, book.PreOpen, s.OrderBooks.GetPhase("GAME"))
+	assert.Equal(t, 2, len(s.Instruments.GetAll()))
+	orderQueueSize, engineQueueSize := s.QueueSizes()
+	assert.Equal(t, 100, orderQueueSize)
+	assert.Equal(t, 100, engineQueueSize)
+
+	// a seed file with numeric sides needs them enabled
+	path := filepath.Join(t.TempDir(), "seed.yaml")
+	fixture := "users:\n  - user_id: user3\n    cash: 1000\n    assets:\n      - asset_id: COIN\n        size: 0\n" +
+		"orders:\n  - user_id: user3\n    asset_id: COIN\n    limit: 100\n    size: 1\n    buy_or_sell: 0\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(fixture), 0644))
+	config.SeedFile = path
+	_, err = newExchange(config)
+	assert.Contains(t, err.Error(), "invalid side 0")
+	config.Server.NumericSides = true
+	s, err = newExchange(config)
+	assert.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, store.BUY, s.Store.GetUserData("user3").Orders[s.Store.GetUserData("user3").OrderIds[0]].BuyOrSell)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"stockexchange/api"
+	"stockexchange/book"
+	"stockexchange/engine"
+)
+
+func main() {
+	config, err := loadConfig(os.Args[1:], os.Getenv)
+	if err == errDumpConfig {
+		data, err := dumpConfig(config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+		return
+	}
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	s, err := newExchange(config)
+	if err != nil {
+		s.Logger.Fatal("invalid config", "error", err)
+	}
+	stop := make(chan struct{}) // closed on shutdown to stop background jobs
+	go s.RunCircuitBreaker(time.Second, stop)
+
+	if config.ScheduleFile != "" {
+		schedules, err := engine.LoadSessionSchedules(config.ScheduleFile)
+		if err != nil {
+			s.Logger.Fatal("invalid session schedule", "error", err)
+		}
+		go engine.NewSessionScheduler(s, schedules).Run(time.Second, stop)
+	}
+
+	var keys []api.APIKey
+	if config.APIKeysFile != "" {
+		keys, err = api.LoadAPIKeys(config.APIKeysFile)
+		if err != nil {
+			s.Logger.Fatal("invalid api keys", "error", err)
+		}
+	}
+	auth := api.NewAuthenticator(keys)
+	if !auth.Enabled() {
+		if !config.InsecureNoAuth {
+			s.Logger.Fatal("no api keys configured, set insecure_no_auth to run without authentication", "api_keys_file", config.APIKeysFile)
+		}
+		s.Logger.Warn("insecure_no_auth is set, authentication is disabled")
+	}
+
+	// request contexts are canceled on shutdown, so streams end instead of holding the server open
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	var options []api.Option
+	if config.Server.NumericSides {
+		options = append(options, api.WithNumericSides())
+	}
+	server := &http.Server{
+		Addr:        config.Server.Listen,
+		Handler:     api.NewRouter(s, auth, options...),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	go func() {
+		var err error
+		if config.Server.TLS.Enabled() {
+			err = server.ListenAndServeTLS(config.Server.TLS.CertFile, config.Server.TLS.KeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			s.Logger.Fatal("http server failed", "error", err)
+		}
+	}()
+	s.Logger.Info("listening", "addr", server.Addr, "tls", config.Server.TLS.Enabled())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	s.Logger.Info("shutting down", "signal", (<-signals).String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout)
+	defer cancel()
+	shutdown(ctx, server, s, cancelRequests, stop, config.Persistence.SnapshotPath)
+}
+
+// newExchange returns the exchange of a config, with its limits, fees, instruments and users
+func newExchange(config Config) (*engine.OrderMatchingService, error) {
+	s := engine.NewOrderMatchingService(engine.WithQueueSizes(config.Engine.OrderQueueSize, config.Engine.EngineQueueSize))
+	level, err := engine.ParseLogLevel(config.Logging.Level)
+	if err != nil {
+		return s, err
+	}
+	s.Logger = engine.NewLogger(os.Stderr, level)
+	s.OrderBooks.Breaker = book.CircuitBreaker{
+		BandBps:       config.CircuitBreaker.BandBps,
+		Cooldown:      config.CircuitBreaker.HaltCooldown,
+		AuctionPeriod: config.CircuitBreaker.ResumeAuction,
+	}
+	s.OrderBooks.Fees = config.Fees
+
+	if config.RateLimitsFile != "" {
+		rateLimits, err := engine.LoadRateLimitConfig(config.RateLimitsFile)
+		if err != nil {
+			return s, err
+		}
+		s.RateLimiter = engine.NewRateLimiter(rateLimits)
+	}
+	if config.RiskLimitsFile != "" {
+		riskLimits, err := engine.LoadRiskConfig(config.RiskLimitsFile)
+		if err != nil {
+			return s, err
+		}
+		s.Risk.Config = riskLimits
+	}
+
+	for _, instrument := range config.Instruments {
+		if err := s.ListInstrument(instrument); err != nil {
+			return s, err
+		}
+	}
+	s.InitExchange(config.Users)
+
+	if config.SeedFile != "" {
+		fixture, err := engine.LoadFixture(config.SeedFile, config.Server.NumericSides)
+		if err != nil {
+			return s, err
+		}
+		if err := s.Seed(fixture); err != nil {
+			return s, fmt.Errorf("seed %s: %v", config.SeedFile, err)
+		}
+	}
+	return s, nil
+}
+
+// shutdown stops the exchange gracefully: it stops accepting requests and orders, waits for in-flight
+// requests, drains the queued orders through the matching engines and writes a snapshot of the final state
+func shutdown(ctx context.Context, server *http.Server, s *engine.OrderMatchingService, cancelRequests func(), stop chan struct{}, snapshotPath string) {
+	close(stop)
+	cancelRequests()
+	if err := server.Shutdown(ctx); err != nil {
+		s.Logger.Error("http server shutdown failed", "error", err)
+	}
+	if err := s.Shutdown(ctx); err != nil {
+		s.Logger.Error("draining queued orders failed", "error", err)
+	}
+
+	if snapshotPath != "" {
+		if err := api.WriteSnapshot(snapshotPath, api.TakeSnapshot(s)); err != nil {
+			s.Logger.Error("writing snapshot failed", "path", snapshotPath, "error", err)
+			return
+		}
+		s.Logger.Info("wrote snapshot", "path", snapshotPath)
+	}
+}
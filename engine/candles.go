@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"stockexchange/store"
+)
+
+// CandleInterval represents the period of a candle and how many candles of that period are kept in memory
+type CandleInterval struct {
+	Name      string
+	Period    time.Duration
+	Retention int // max number of candles kept per asset, older candles are dropped
+}
+
+var candleIntervals = []CandleInterval{
+	{Name: "1m", Period: time.Minute, Retention: 24 * 60},     // 1 day
+	{Name: "5m", Period: 5 * time.Minute, Retention: 7 * 288}, // 1 week
+	{Name: "1h", Period: time.Hour, Retention: 30 * 24},       // 30 days
+	{Name: "1d", Period: 24 * time.Hour, Retention: 365},      // 1 year
+}
+
+// getCandleInterval returns the candle interval with the given name
+func getCandleInterval(name string) (CandleInterval, error) {
+	for _, interval := range candleIntervals {
+		if interval.Name == name {
+			return interval, nil
+		}
+	}
+	return CandleInterval{}, fmt.Errorf("unknown candle interval %q", name)
+}
+
+// Candle represents the open/high/low/close prices and volume of the trades of an asset during a period
+type Candle struct {
+	Start    time.Time // start of the period, in UTC
+	Open     store.Usd
+	High     store.Usd
+	Low      store.Usd
+	Close    store.Usd
+	Volume   int       // number of assets traded
+	Notional store.Usd // total value of the assets traded, in Usd cents
+	Trades   int       // number of trades
+}
+
+// VWAP returns the volume weighted average price of the candle, in Usd cents
+func (c Candle) VWAP() store.Usd {
+	if c.Volume == 0 {
+		return 0
+	}
+	return c.Notional / store.Usd(c.Volume)
+}
+
+// addTrade updates the candle with a trade in its period
+func (c *Candle) addTrade(trade store.Trade) {
+	if c.Trades == 0 {
+		c.Open, c.High, c.Low = trade.Price, trade.Price, trade.Price
+	}
+	if trade.Price > c.High {
+		c.High = trade.Price
+	}
+	if trade.Price < c.Low {
+		c.Low = trade.Price
+	}
+	c.Close = trade.Price
+	c.Volume += trade.Size
+	c.Notional += store.GetTotalAssetCost(trade.Price, trade.Size)
+	c.Trades++
+}
+
+// CandleAggregator builds candles for every candle interval from executed trades
+type CandleAggregator struct {
+	candles map[store.AssetId]map[string][]Candle // assetId -> interval name -> candles, oldest first
+	sync.Mutex
+}
+
+func newCandleAggregator() *CandleAggregator {
+	return &CandleAggregator{
+		candles: make(map[store.AssetId]map[string][]Candle),
+	}
+}
+
+// AddTrade adds a trade to the current candle of every interval of the trade's asset
+func (ca *CandleAggregator) AddTrade(trade store.Trade) {
+	ca.Lock()
+	defer ca.Unlock()
+
+	assetCandles, ok := ca.candles[trade.AssetId]
+	if !ok {
+		assetCandles = make(map[string][]Candle)
+		ca.candles[trade.AssetId] = assetCandles
+	}
+
+	for _, interval := range candleIntervals {
+		assetCandles[interval.Name] = addTradeToCandles(assetCandles[interval.Name], trade, interval)
+	}
+}
+
+// addTradeToCandles adds a trade to the candle of its period, starting a new candle if there isn't one.
+// Trades older than the retained candles are dropped.
+func addTradeToCandles(candles []Candle, trade store.Trade, interval CandleInterval) []Candle {
+	start := trade.ExecutedAt.UTC().Truncate(interval.Period)
+
+	// trades arrive in time order, so the candle of the trade is almost always the last one
+	i := len(candles) - 1
+	for i >= 0 && candles[i].Start.After(start) {
+		i--
+	}
+
+	if i >= 0 && candles[i].Start.Equal(start) {
+		candles[i].addTrade(trade)
+		return candles
+	}
+	if i < 0 && len(candles) >= interval.Retention {
+		return candles
+	}
+
+	candle := Candle{Start: start}
+	candle.addTrade(trade)
+	candles = append(candles, Candle{})
+	copy(candles[i+2:], candles[i+1:])
+	candles[i+1] = candle
+
+	if len(candles) > interval.Retention {
+		candles = candles[len(candles)-interval.Retention:]
+	}
+	return candles
+}
+
+// GetCandles returns the candles of an asset for an interval that start within [from, to], oldest first
+func (ca *CandleAggregator) GetCandles(assetId store.AssetId, intervalName string, from, to time.Time) ([]Candle, error) {
+	interval, err := getCandleInterval(intervalName)
+	if err != nil {
+		return nil, err
+	}
+
+	ca.Lock()
+	defer ca.Unlock()
+
+	var candles []Candle
+	for _, c := range ca.candles[assetId][interval.Name] {
+		if !c.Start.Before(from) && !c.Start.After(to) {
+			candles = append(candles, c)
+		}
+	}
+	return candles, nil
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestCandleAggregator_AddTrade(t *testing.T) {
+	ca := newCandleAggregator()
+	start := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+
+	ca.AddTrade(store.Trade{AssetId: assetId1, Price: 100, Size: 10, ExecutedAt: start})
+	ca.AddTrade(store.Trade{AssetId: assetId1, Price: 104, Size: 5, ExecutedAt: start.Add(10 * time.Second)})
+	ca.AddTrade(store.Trade{AssetId: assetId1, Price: 98, Size: 5, ExecutedAt: start.Add(50 * time.Second)})
+	ca.AddTrade(store.Trade{AssetId: assetId1, Price: 101, Size: 20, ExecutedAt: start.Add(2 * time.Minute)})
+	ca.AddTrade(store.Trade{AssetId: assetId2, Price: 500, Size: 1, ExecutedAt: start})
+
+	candles, err := ca.GetCandles(assetId1, "1m", time.Time{}, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(candles))
+	assert.Equal(t, Candle{Start: start, Open: 100, High: 104, Low: 98, Close: 98, Volume: 20, Notional: 2010, Trades: 3}, candles[0])
+	assert.Equal(t, store.Usd(100), candles[0].VWAP())
+	assert.Equal(t, start.Add(2*time.Minute), candles[1].Start)
+
+	candles, err = ca.GetCandles(assetId1, "5m", time.Time{}, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(candles))
+	assert.Equal(t, Candle{Start: start, Open: 100, High: 104, Low: 98, Close: 101, Volume: 40, Notional: 4030, Trades: 4}, candles[0])
+
+	candles, err = ca.GetCandles(assetId1, "1d", time.Time{}, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), candles[0].Start)
+
+	// filter by time range
+	candles, err = ca.GetCandles(assetId1, "1m", start.Add(time.Minute), start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(candles))
+	assert.Equal(t, store.Usd(101), candles[0].Open)
+
+	candles, err = ca.GetCandles(assetId2, "1h", time.Time{}, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(candles))
+
+	_, err = ca.GetCandles(assetId1, "2m", time.Time{}, start)
+	assert.Error(t, err)
+}
+
+func TestAddTradeToCandles_Retention(t *testing.T) {
+	interval := CandleInterval{Name: "1m", Period: time.Minute, Retention: 3}
+	start := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+
+	var candles []Candle
+	for i := 0; i < 5; i++ {
+		candles = addTradeToCandles(candles, store.Trade{Price: store.Usd(100 + i), Size: 1, ExecutedAt: start.Add(time.Duration(i) * time.Minute)}, interval)
+	}
+
+	assert.Equal(t, 3, len(candles)) // oldest candles are dropped
+	assert.Equal(t, start.Add(2*time.Minute), candles[0].Start)
+	assert.Equal(t, start.Add(4*time.Minute), candles[2].Start)
+
+	// late trade in a retained period updates its candle, trades older than the retained candles are dropped
+	candles = addTradeToCandles(candles, store.Trade{Price: 90, Size: 1, ExecutedAt: start.Add(3 * time.Minute)}, interval)
+	candles = addTradeToCandles(candles, store.Trade{Price: 90, Size: 1, ExecutedAt: start}, interval)
+	assert.Equal(t, 3, len(candles))
+	assert.Equal(t, store.Usd(90), candles[1].Low)
+	assert.Equal(t, start.Add(2*time.Minute), candles[0].Start)
+}
+
+func TestOrderMatchingService_Candles(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 6, BuyOrSell: store.BUY})
+
+	s.Flush()
+
+	candles, err := s.Candles.GetCandles(assetId1, "1h", time.Time{}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(candles))
+	assert.Equal(t, 10, candles[0].Volume)
+	assert.Equal(t, 2, candles[0].Trades)
+	assert.Equal(t, store.Usd(100), candles[0].VWAP())
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"sync"
+
+	"stockexchange/store"
+)
+
+// maxClientOrderIdLength is the max length of a client order id
+const maxClientOrderIdLength = 64
+
+// ClientOrders maps the client order ids of users to their latest order.
+// A client order id is unique across a user's open orders, it can be reused once its order is closed.
+type ClientOrders struct {
+	orders map[store.UserId]map[string]OrderReq // userId -> client order id -> latest order request with the id
+	sync.Mutex
+}
+
+func newClientOrders() *ClientOrders {
+	return &ClientOrders{
+		orders: make(map[store.UserId]map[string]OrderReq),
+	}
+}
+
+// Get returns the latest order request of a user with a client order id
+func (c *ClientOrders) Get(userId store.UserId, clientOrderId string) (OrderReq, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	or, ok := c.orders[userId][clientOrderId]
+	return or, ok
+}
+
+// Reserve assigns the client order id of an order request to it, unless isOpen reports the order
+// the id is assigned to is still open. In that case it returns the open order's request and false.
+func (c *ClientOrders) Reserve(or OrderReq, isOpen func(OrderReq) bool) (OrderReq, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if existing, ok := c.orders[or.UserId][or.ClientOrderId]; ok && isOpen(existing) {
+		return existing, false
+	}
+
+	if c.orders[or.UserId] == nil {
+		c.orders[or.UserId] = make(map[string]OrderReq)
+	}
+	c.orders[or.UserId][or.ClientOrderId] = or
+	return or, true
+}
+
+// Release frees the client order id of an order request that was reserved but never queued
+func (c *ClientOrders) Release(or OrderReq) {
+	c.Lock()
+	defer c.Unlock()
+
+	if existing, ok := c.orders[or.UserId][or.ClientOrderId]; ok && existing.OrderId == or.OrderId {
+		delete(c.orders[or.UserId], or.ClientOrderId)
+	}
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+type MarketEventType string
+
+const (
+	HaltEvent   MarketEventType = "HALT"   // asset halted by the circuit breaker
+	ResumeEvent MarketEventType = "RESUME" // asset resumed continuous trading after a halt
+)
+
+// MarketEvent represents a change in the trading status of an asset
+type MarketEvent struct {
+	Type     MarketEventType
+	AssetId  store.AssetId
+	Phase    book.TradingPhase // phase of the asset after the event
+	RefPrice store.Usd         // reference price of the asset after the event
+	EventAt  time.Time
+	Seq      uint64 // exchange-wide sequence number of the event
+}
+
+// maxMarketEvents is the number of most recent market events kept in memory
+const maxMarketEvents = 1000
+
+// MarketEvents keeps the most recent market events
+type MarketEvents struct {
+	events []MarketEvent
+	sync.Mutex
+}
+
+func newMarketEvents() *MarketEvents {
+	return &MarketEvents{}
+}
+
+// Add records a market event, dropping the oldest event once maxMarketEvents are kept
+func (me *MarketEvents) Add(event MarketEvent) {
+	me.Lock()
+	defer me.Unlock()
+
+	me.events = append(me.events, event)
+	if len(me.events) > maxMarketEvents {
+		me.events = me.events[len(me.events)-maxMarketEvents:]
+	}
+}
+
+// GetAssetEvents returns the recorded market events of an asset, oldest first
+func (me *MarketEvents) GetAssetEvents(assetId store.AssetId) []MarketEvent {
+	me.Lock()
+	defer me.Unlock()
+
+	var events []MarketEvent
+	for _, e := range me.events {
+		if e.AssetId == assetId {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// haltAsset emits a halt event for an asset halted by the circuit breaker while matching an order of a command.
+// ResumeHaltedAssets resumes it once its cooldown is over.
+func (s *OrderMatchingService) haltAsset(assetId store.AssetId, cmd *store.Command) {
+	status := s.OrderBooks.GetStatus(assetId)
+	s.emitMarketEvent(MarketEvent{Type: HaltEvent, AssetId: assetId, Phase: status.Phase, RefPrice: status.RefPrice, EventAt: status.HaltedAt}, cmd)
+}
+
+// resumption is a resumption auction started by the circuit breaker
+type resumption struct {
+	haltedAt time.Time // time the asset was halted
+	endAt    time.Time // time the auction is uncrossed and the asset resumes continuous trading
+}
+
+// ResumeHaltedAssets moves the assets halted by the circuit breaker to the resumption auction once their cooldown
+// is over, and uncrosses them at the end of the auction period. The cooldown and auction period are measured with
+// the service's clock, so the assets are resumed at the same times whenever it's called, e.g by RunCircuitBreaker.
+// Nothing is done for the assets moved out of the halt, or halted again, in the meantime.
+func (s *OrderMatchingService) ResumeHaltedAssets() {
+	now := s.Now()
+	for _, assetId := range s.OrderBooks.GetAssetIds() {
+		status := s.OrderBooks.GetStatus(assetId)
+		if status.ResumeAt.IsZero() || now.Before(status.ResumeAt) {
+			continue
+		}
+		if _, err := s.setAssetPhase(assetId, book.Halted, book.Auction, status.HaltedAt); err != nil {
+			s.Logger.Error("circuit breaker failed to start the resumption auction", "asset_id", assetId, "error", err)
+			continue
+		}
+
+		s.queueMu.Lock()
+		s.resumptions[assetId] = resumption{haltedAt: status.HaltedAt, endAt: status.ResumeAt.Add(s.OrderBooks.Breaker.AuctionPeriod)}
+		s.queueMu.Unlock()
+	}
+
+	for _, assetId := range s.dueResumptions(now) {
+		s.queueMu.Lock()
+		r := s.resumptions[assetId]
+		delete(s.resumptions, assetId)
+		s.queueMu.Unlock()
+
+		if _, err := s.setAssetPhase(assetId, book.Auction, book.Continuous, r.haltedAt); err != nil {
+			s.Logger.Error("circuit breaker failed to resume trading", "asset_id", assetId, "error", err)
+		}
+	}
+}
+
+// dueResumptions returns the assets whose resumption auction ends at now or before, sorted by asset id
+func (s *OrderMatchingService) dueResumptions(now time.Time) []store.AssetId {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	var assetIds []store.AssetId
+	for assetId, r := range s.resumptions {
+		if !now.Before(r.endAt) {
+			assetIds = append(assetIds, assetId)
+		}
+	}
+	sort.Slice(assetIds, func(i, j int) bool { return assetIds[i] < assetIds[j] })
+	return assetIds
+}
+
+// RunCircuitBreaker resumes the assets halted by the circuit breaker, see ResumeHaltedAssets,
+// every interval until stop is closed
+func (s *OrderMatchingService) RunCircuitBreaker(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.ResumeHaltedAssets()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// emitMarketEvent numbers a market event as an event of a command, records and logs it
+func (s *OrderMatchingService) emitMarketEvent(event MarketEvent, cmd *store.Command) {
+	event.Seq = cmd.NextEvent()
+	s.Logger.Warn("market event", "type", event.Type, "asset_id", event.AssetId, "phase", event.Phase, "reference_price", event.RefPrice, "seq", event.Seq)
+	s.MarketEvents.Add(event)
+}
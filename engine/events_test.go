@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+func TestOrderMatchingService_CircuitBreaker(t *testing.T) {
+	now := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	s := NewOrderMatchingService(WithClock(func() time.Time { return now }), WithIdGenerator(store.NewSequentialIds("id-")))
+	defer s.Close()
+	s.OrderBooks.Breaker = book.CircuitBreaker{BandBps: 1000, Cooldown: 5 * time.Minute, AuctionPeriod: time.Minute}
+
+	setupTestUsers(s)
+
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 150, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 5, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 150, AssetId: assetId1, Size: 15, BuyOrSell: store.BUY})
+	s.Flush()
+
+	status := s.OrderBooks.GetStatus(assetId1)
+	assert.Equal(t, book.Halted, status.Phase)
+	assert.Equal(t, now, status.HaltedAt)
+	assert.Equal(t, now.Add(5*time.Minute), status.ResumeAt)
+
+	// the halt lasts the cooldown of the service's clock
+	now = now.Add(5*time.Minute - time.Second)
+	s.ResumeHaltedAssets()
+	assert.Equal(t, book.Halted, s.OrderBooks.GetPhase(assetId1))
+
+	now = now.Add(time.Second)
+	s.ResumeHaltedAssets()
+	assert.Equal(t, book.Auction, s.OrderBooks.GetPhase(assetId1))
+
+	now = now.Add(time.Minute - time.Second)
+	s.ResumeHaltedAssets()
+	assert.Equal(t, book.Auction, s.OrderBooks.GetPhase(assetId1))
+
+	now = now.Add(time.Second)
+	s.ResumeHaltedAssets()
+	status = s.OrderBooks.GetStatus(assetId1)
+	assert.Equal(t, book.Continuous, status.Phase)
+	assert.Equal(t, store.Usd(150), status.RefPrice) // resumption auction uncrossed at 150
+
+	events := s.MarketEvents.GetAssetEvents(assetId1)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, HaltEvent, events[0].Type)
+	assert.Equal(t, book.Halted, events[0].Phase)
+	assert.Equal(t, ResumeEvent, events[1].Type)
+	assert.Equal(t, book.Continuous, events[1].Phase)
+	assert.Equal(t, now, events[1].EventAt)
+	assert.Empty(t, s.MarketEvents.GetAssetEvents(assetId2))
+
+	// the halt follows the trade of the order that hit the band, the resume follows the trade uncrossing the auction
+	assert.Equal(t, s.Store.GetUserData(userId2).Orders["id-4"].Seq+2, events[0].Seq)
+	assert.Equal(t, s.LastSeq()+2, events[1].Seq)
+}
@@ -0,0 +1,31 @@
+package engine_test
+
+import (
+	"fmt"
+
+	"stockexchange/engine"
+	"stockexchange/store"
+)
+
+func ExampleOrderMatchingService() {
+	s := engine.NewOrderMatchingService()
+	s.InitExchange([]store.InitExchangeReq{
+		{UserId: "buyer", Cash: 10000, Assets: []store.Asset{{AssetId: "COIN", Size: 0}}},
+		{UserId: "seller", Assets: []store.Asset{{AssetId: "COIN", Size: 10}}},
+	})
+
+	s.PlaceOrder(engine.OrderReq{UserId: "seller", AssetId: "COIN", Size: 10, Limit: 100, BuyOrSell: store.SELL})
+	placed, _, err := s.PlaceOrder(engine.OrderReq{UserId: "buyer", AssetId: "COIN", Size: 10, Limit: 100, BuyOrSell: store.BUY})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	s.Flush() // orders are matched asynchronously, wait until they're matched
+
+	order := s.Store.GetUserData("buyer").Orders[placed.OrderId]
+	fmt.Println(order.Status, order.Filled)
+	fmt.Println(s.Store.GetUserData("seller").Cash)
+	// Output:
+	// COMPLETE 10
+	// 1000
+}
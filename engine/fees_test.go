@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+func TestFeeSchedule_tradeFees(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	s.OrderBooks.Fees = book.FeeSchedule{
+		Default: book.FeeRates{MakerBps: 10, TakerBps: 20},
+		Users:   map[store.UserId]book.FeeRates{userId2: {MakerBps: 0, TakerBps: 5}},
+	}
+	setupTestUsers(s)
+
+	// the resting sell order is the maker, the incoming buy order the taker
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 50, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 50, BuyOrSell: store.BUY})
+	s.Flush()
+
+	assert.Equal(t, store.Usd(10000-5000-10), s.Store.GetUserData(userId1).Cash) // 20 bps of 5000
+	assert.Equal(t, store.Usd(10000+5000), s.Store.GetUserData(userId2).Cash)    // no maker fee
+	for _, fills := range s.Store.GetUserData(userId1).Fills {
+		assert.Equal(t, []store.Fill{{Price: 100, Size: 50, ExecutedAt: fills[0].ExecutedAt, Fee: 10, Seq: fills[0].Seq}}, fills)
+	}
+
+	assert.Error(t, book.FeeSchedule{Default: book.FeeRates{MakerBps: -1}}.Validate())
+	assert.Error(t, book.FeeSchedule{Users: map[store.UserId]book.FeeRates{userId1: {TakerBps: 10001}}}.Validate())
+}
+
+func TestFeeSchedule_feeReserve(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	s.OrderBooks.Fees = book.FeeSchedule{Default: book.FeeRates{MakerBps: 10, TakerBps: 20}}
+	setupTestUsers(s)
+
+	// the cash pays for the order but not for its fees
+	_, _, err := s.PlaceOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 100, BuyOrSell: store.BUY})
+	assert.EqualError(t, err, "user doesn't have enough cash")
+
+	// 20 bps of 5000 are reserved for the fees, the fees of the fills are paid from the reserve
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 20, BuyOrSell: store.SELL})
+	s.Flush()
+	placed, _, err := s.PlaceOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 50, BuyOrSell: store.BUY})
+	assert.NoError(t, err)
+	s.Flush()
+	order := s.Store.GetUserData(userId1).Orders[placed.OrderId]
+	assert.Equal(t, store.Usd(10-4), order.FeeReserve) // 20 bps of 2000
+	assert.Equal(t, store.Usd(10000-5000-10), s.Store.GetUserData(userId1).Cash)
+	reserved, _ := s.Store.GetReserved(userId1)
+	assert.Equal(t, store.Usd(3000+6), reserved)
+
+	// the unused reserve is released when the order is canceled
+	s.CancelUserOrder(userId1, order.OrderId)
+	assert.Equal(t, store.Usd(10000-2000-4), s.Store.GetUserData(userId1).Cash)
+	assert.Equal(t, store.Usd(0), s.Store.GetUserData(userId1).Orders[order.OrderId].FeeReserve)
+}
@@ -0,0 +1,13 @@
+package engine
+
+import (
+	"stockexchange/store"
+)
+
+var userId1 = store.UserId("userId1")
+
+var userId2 = store.UserId("userId2")
+
+var assetId1 = store.AssetId("COIN")
+
+var assetId2 = store.AssetId("GAME")
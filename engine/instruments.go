@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+// Instrument defines an asset listed on the exchange
+type Instrument struct {
+	AssetId  store.AssetId     `yaml:"asset_id"`
+	Name     string            `yaml:"name"`      // display name of the asset
+	TickSize store.Usd         `yaml:"tick_size"` // limits must be a multiple of the tick size, in Usd cents, 0 allows any limit
+	LotSize  int               `yaml:"lot_size"`  // sizes must be a multiple of the lot size, 0 allows any size
+	Phase    book.TradingPhase `yaml:"phase"`     // trading phase the asset is listed in, CONTINUOUS if empty
+}
+
+// Validate returns an error if the instrument has no asset id, a negative tick or lot size, or an unknown phase
+func (i Instrument) Validate() error {
+	if i.AssetId == "" {
+		return fmt.Errorf("asset_id is required")
+	}
+	if i.TickSize < 0 || i.LotSize < 0 {
+		return fmt.Errorf("asset %s: tick_size and lot_size can't be negative", i.AssetId)
+	}
+	if i.Phase != "" && !book.IsValidPhase(i.Phase) {
+		return fmt.Errorf("asset %s: unknown phase %s", i.AssetId, i.Phase)
+	}
+	return nil
+}
+
+// validateOrder returns an error if an order's limit or size doesn't fit the instrument's tick or lot size
+func (i Instrument) validateOrder(or OrderReq) error {
+	if i.TickSize > 0 && or.Limit%i.TickSize != 0 {
+		return fmt.Errorf("limit must be a multiple of the tick size %d of %s", i.TickSize, i.AssetId)
+	}
+	if i.LotSize > 0 && or.Size%i.LotSize != 0 {
+		return fmt.Errorf("size must be a multiple of the lot size %d of %s", i.LotSize, i.AssetId)
+	}
+	return nil
+}
+
+// Instruments holds the instruments listed on the exchange.
+// Until an instrument is listed, orders of any asset are accepted.
+type Instruments struct {
+	instruments map[store.AssetId]Instrument
+	sync.RWMutex
+}
+
+func newInstruments() *Instruments {
+	return &Instruments{
+		instruments: make(map[store.AssetId]Instrument),
+	}
+}
+
+// Add lists an instrument, replacing the instrument of the same asset if it's already listed
+func (in *Instruments) Add(instrument Instrument) {
+	in.Lock()
+	defer in.Unlock()
+
+	in.instruments[instrument.AssetId] = instrument
+}
+
+// Get returns the instrument of an asset
+func (in *Instruments) Get(assetId store.AssetId) (Instrument, bool) {
+	in.RLock()
+	defer in.RUnlock()
+
+	instrument, ok := in.instruments[assetId]
+	return instrument, ok
+}
+
+// GetAll returns every listed instrument, sorted by asset id
+func (in *Instruments) GetAll() []Instrument {
+	in.RLock()
+	defer in.RUnlock()
+
+	instruments := make([]Instrument, 0, len(in.instruments))
+	for _, instrument := range in.instruments {
+		instruments = append(instruments, instrument)
+	}
+	sort.Slice(instruments, func(i, j int) bool { return instruments[i].AssetId < instruments[j].AssetId })
+	return instruments
+}
+
+// ValidateOrder returns an error if an order's asset isn't listed, or its limit or size doesn't fit the instrument
+func (in *Instruments) ValidateOrder(or OrderReq) error {
+	in.RLock()
+	defer in.RUnlock()
+
+	if len(in.instruments) == 0 {
+		return nil
+	}
+	instrument, ok := in.instruments[or.AssetId]
+	if !ok {
+		return fmt.Errorf("asset %s is not listed", or.AssetId)
+	}
+	return instrument.validateOrder(or)
+}
+
+// ListInstrument lists an instrument and moves its order book to the instrument's phase
+func (s *OrderMatchingService) ListInstrument(instrument Instrument) error {
+	if err := instrument.Validate(); err != nil {
+		return err
+	}
+
+	s.Instruments.Add(instrument)
+	if instrument.Phase != "" {
+		s.OrderBooks.ForcePhase(instrument.AssetId, instrument.Phase)
+	}
+	return nil
+}
+
+// IsTradedAsset returns if an asset is traded on the exchange: once instruments are listed only listed assets are,
+// until then assets with an order book are
+func (s *OrderMatchingService) IsTradedAsset(assetId store.AssetId) bool {
+	if len(s.Instruments.GetAll()) > 0 {
+		_, ok := s.Instruments.Get(assetId)
+		return ok
+	}
+	return s.OrderBooks.HasOrderBook(assetId)
+}
+
+// GetTradedAssetIds returns the ids of the assets traded on the exchange, sorted by id, see IsTradedAsset
+func (s *OrderMatchingService) GetTradedAssetIds() []store.AssetId {
+	instruments := s.Instruments.GetAll()
+	if len(instruments) == 0 {
+		return s.OrderBooks.GetAssetIds()
+	}
+	assetIds := make([]store.AssetId, 0, len(instruments))
+	for _, instrument := range instruments {
+		assetIds = append(assetIds, instrument.AssetId)
+	}
+	return assetIds
+}
@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+func TestInstruments_ValidateOrder(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+	place := func(assetId store.AssetId, size int, limit store.Usd) error {
+		_, _, err := s.PlaceOrder(OrderReq{UserId: userId1, AssetId: assetId, BuyOrSell: store.BUY, Size: size, Limit: limit})
+		return err
+	}
+
+	// orders of any asset are accepted until an instrument is listed
+	assert.NoError(t, place(assetId2, 3, 101))
+
+	assert.NoError(t, s.ListInstrument(Instrument{AssetId: assetId1, TickSize: 5, LotSize: 10, Phase: book.PreOpen}))
+	assert.Error(t, s.ListInstrument(Instrument{AssetId: "AAPL", Phase: "OPEN"}))
+	assert.Equal(t, book.PreOpen, s.OrderBooks.GetPhase(assetId1))
+
+	assert.NoError(t, place(assetId1, 20, 100))
+	assert.Error(t, place(assetId1, 20, 101))
+	assert.Error(t, place(assetId1, 15, 100))
+	assert.Error(t, place(assetId2, 3, 101))
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+type CommandType string
+
+const (
+	CreateUserCommand  CommandType = "CREATE_USER"  // a user was created with their cash and assets
+	UserStatusCommand  CommandType = "USER_STATUS"  // a user was suspended or resumed
+	DeleteUserCommand  CommandType = "DELETE_USER"  // a user was deleted
+	NewOrderCommand    CommandType = "NEW_ORDER"    // an order was accepted, or rejected when it was saved
+	CancelOrderCommand CommandType = "CANCEL_ORDER" // a working order was canceled
+	AmendOrderCommand  CommandType = "AMEND_ORDER"  // a working order was canceled and replaced by an amended order
+	CancelAssetCommand CommandType = "CANCEL_ASSET" // every order in an asset's order book was canceled
+	SetPhaseCommand    CommandType = "SET_PHASE"    // an asset's order book moved to a trading phase
+	TransferCommand    CommandType = "TRANSFER"     // a deposit or withdrawal was made
+)
+
+// JournalEntry is a command accepted by the exchange, with what's needed to apply it again.
+// Orders and transfers are journaled once they're checked, so replaying them doesn't check them again.
+type JournalEntry struct {
+	Type     CommandType
+	Seq      uint64    // sequence number of the command
+	At       time.Time // time the command was accepted
+	User     store.InitExchangeReq
+	UserId   store.UserId
+	Status   store.UserStatus
+	Order    store.Order // new order, or replacement of an amended order once it was placed
+	OrderId  store.OrderId
+	AssetId  store.AssetId
+	Reason   string            // why the orders are canceled
+	From     book.TradingPhase // phase the order book must be in, any phase if empty
+	Phase    book.TradingPhase
+	HaltedAt time.Time // time the order book was halted, set when the circuit breaker resumes the order book
+	Transfer store.Transfer
+}
+
+// Journal records the commands accepted by a service, in sequence order
+type Journal struct {
+	entries []JournalEntry
+	sync.Mutex
+}
+
+// NewJournal returns an empty journal
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Entries returns a copy of the journaled commands, in sequence order.
+// The replacements of amended orders are recorded once they're placed, flush the service first to get them.
+func (j *Journal) Entries() []JournalEntry {
+	j.Lock()
+	defer j.Unlock()
+
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// add records a command
+func (j *Journal) add(entry JournalEntry) {
+	j.Lock()
+	defer j.Unlock()
+
+	j.entries = append(j.entries, entry)
+}
+
+// setOrder records the replacement of the amended order of the command with sequence number seq, it's placed by the
+// matching engine after the command was journaled. A nil journal records nothing.
+func (j *Journal) setOrder(seq uint64, order store.Order) {
+	if j == nil {
+		return
+	}
+	j.Lock()
+	defer j.Unlock()
+
+	if i := sort.Search(len(j.entries), func(i int) bool { return j.entries[i].Seq >= seq }); i < len(j.entries) && j.entries[i].Seq == seq {
+		j.entries[i].Order = order
+	}
+}
+
+// WithJournal makes the service record the commands it accepts in a journal
+func WithJournal(journal *Journal) Option {
+	return func(s *OrderMatchingService) {
+		s.journal = journal
+	}
+}
+
+// journalCommand records an accepted command in the journal of the service, if it has one.
+// Callers must hold queueMu, so commands are journaled in sequence order.
+func (s *OrderMatchingService) journalCommand(entry JournalEntry) {
+	if s.journal != nil {
+		s.journal.add(entry)
+	}
+}
+
+// Replay applies journaled commands, in sequence order, to a service that hasn't accepted any command yet.
+// The commands are applied from the caller's goroutine with their journaled sequence numbers and times, so a service
+// configured like the journaled service, e.g with the same instruments, fees and circuit breaker, ends up in the same
+// state once the journaled service processed its commands.
+func (s *OrderMatchingService) Replay(entries []JournalEntry) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if s.seq.Last() > 0 || len(s.queues) > 0 || s.closed {
+		return errors.New("commands were already accepted, journals are replayed into new services")
+	}
+
+	for i, entry := range entries {
+		if entry.Seq <= s.seq.Last() {
+			return fmt.Errorf("entries[%d]: sequence number %d isn't after %d", i, entry.Seq, s.seq.Last())
+		}
+		s.seq.Set(entry.Seq)
+		s.journalCommand(entry)
+		s.applyEntry(entry, store.NewCommand(entry.Seq, entry.At))
+	}
+	return nil
+}
+
+// applyEntry applies a journaled command
+func (s *OrderMatchingService) applyEntry(entry JournalEntry, cmd *store.Command) {
+	switch entry.Type {
+	case CreateUserCommand:
+		s.Store.CreateUser(entry.User)
+	case UserStatusCommand:
+		s.Store.SetUserStatus(entry.UserId, entry.Status)
+	case DeleteUserCommand:
+		s.Store.DeleteUser(entry.UserId)
+	case NewOrderCommand:
+		s.restoreOrder(entry.Order, cmd)
+	case CancelOrderCommand:
+		s.applyCancel(entry.UserId, entry.OrderId, entry.Reason, cmd)
+	case AmendOrderCommand:
+		if s.applyCancel(entry.UserId, entry.OrderId, entry.Reason, cmd).OrderId != "" && entry.Order.OrderId != "" {
+			s.restoreOrder(entry.Order, cmd)
+		}
+	case CancelAssetCommand:
+		s.applyCancelAsset(entry.AssetId, entry.Reason, cmd)
+	case SetPhaseCommand:
+		s.applyPhase(entry.AssetId, entry.From, entry.Phase, entry.HaltedAt, cmd)
+	case TransferCommand:
+		s.Store.RestoreTransfer(entry.Transfer)
+	}
+}
+
+// restoreOrder saves a journaled order without checking it again and matches it
+func (s *OrderMatchingService) restoreOrder(order store.Order, cmd *store.Command) {
+	if order.ClientOrderId != "" {
+		s.ClientOrders.Reserve(orderReqFromOrder(order), s.isOpenOrder)
+	}
+	if order.Status == store.Rejected {
+		s.Store.AddRejectedOrder(order, order.Reason)
+		return
+	}
+	s.Store.RestoreUserOrder(order)
+	s.ExecuteOrder(order, cmd)
+}
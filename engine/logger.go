@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"stockexchange/store"
+)
+
+// Structured logging
+//
+// Logs are written as one JSON object per line with the time, level, message and the fields of the
+// log, e.g {"time":"...","level":"info","msg":"order accepted","request_id":"...","order_id":"..."}.
+// Every request gets a request id that follows its order through the matching engine, so an order
+// can be traced from the request placing it to each of its fills.
+
+type LogLevel int
+
+const (
+	DebugLevel LogLevel = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var logLevelNames = map[LogLevel]string{DebugLevel: "debug", InfoLevel: "info", WarnLevel: "warn", ErrorLevel: "error"}
+
+func (l LogLevel) String() string {
+	return logLevelNames[l]
+}
+
+// ParseLogLevel parses a log level name, i.e debug, info, warn or error
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, must be debug, info, warn or error", name)
+}
+
+// Logger writes structured logs at or above its level
+type Logger struct {
+	out    io.Writer
+	level  LogLevel
+	fields []interface{} // key value pairs added to every log
+	now    store.Clock
+	mu     *sync.Mutex // synchronize writes to out, shared by the loggers returned by With
+}
+
+// NewLogger returns a logger writing JSON lines to out, dropping logs below level
+func NewLogger(out io.Writer, level LogLevel) *Logger {
+	return &Logger{out: out, level: level, now: time.Now, mu: &sync.Mutex{}}
+}
+
+// With returns a logger adding key value pairs to every log
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	with := *l
+	with.fields = append(append([]interface{}{}, l.fields...), keyvals...)
+	return &with
+}
+
+// Enabled returns if logs of a level are written
+func (l *Logger) Enabled(level LogLevel) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.log(DebugLevel, msg, keyvals) }
+
+func (l *Logger) Info(msg string, keyvals ...interface{}) { l.log(InfoLevel, msg, keyvals) }
+
+func (l *Logger) Warn(msg string, keyvals ...interface{}) { l.log(WarnLevel, msg, keyvals) }
+
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.log(ErrorLevel, msg, keyvals) }
+
+// Fatal writes an error log and exits
+func (l *Logger) Fatal(msg string, keyvals ...interface{}) {
+	l.log(ErrorLevel, msg, keyvals)
+	os.Exit(1)
+}
+
+// log writes a log with the logger's fields and key value pairs, keys must be strings
+func (l *Logger) log(level LogLevel, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var b bytes.Buffer
+	b.WriteString("{")
+	writeLogField(&b, "time", l.now().UTC().Format(time.RFC3339Nano))
+	b.WriteString(",")
+	writeLogField(&b, "level", level.String())
+	b.WriteString(",")
+	writeLogField(&b, "msg", msg)
+	fields := append(append([]interface{}{}, l.fields...), keyvals...)
+	for i := 0; i+1 < len(fields); i += 2 {
+		b.WriteString(",")
+		writeLogField(&b, fmt.Sprint(fields[i]), fields[i+1])
+	}
+	b.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(b.Bytes())
+}
+
+func writeLogField(b *bytes.Buffer, key string, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	b.Write(k)
+	b.WriteString(":")
+	b.Write(v)
+}
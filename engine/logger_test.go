@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(&out, InfoLevel)
+	logger.now = func() time.Time { return time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC) }
+
+	logger.Debug("not written")
+	logger.With("user_id", userId1).Warn("order rejected", "size", 10, "error", errors.New("no cash"))
+	assert.Equal(t, `{"time":"2021-06-01T09:30:00Z","level":"warn","msg":"order rejected","user_id":"userId1","size":10,"error":"no cash"}`+"\n", out.String())
+
+	level, err := ParseLogLevel("DEBUG")
+	assert.NoError(t, err)
+	assert.Equal(t, DebugLevel, level)
+	_, err = ParseLogLevel("verbose")
+	assert.Error(t, err)
+}
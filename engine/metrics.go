@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stockexchange/store"
+)
+
+// Metrics
+//
+// The internals of the exchange are exposed at /metrics in the Prometheus text format.
+// Counters and histograms are updated as orders, trades and requests happen, gauges like the
+// queue depth and the book depth are read when the metrics are scraped.
+
+// Order rejection reasons of the orders rejected metric
+const (
+	rejectInvalid           = "invalid"
+	RejectSuspended         = "suspended"
+	RejectPhase             = "phase"
+	RejectMaxOpenOrders     = "max_open_orders"
+	RejectDuplicate         = "duplicate_client_order_id"
+	RejectQueueFull         = "queue_full"
+	RejectShuttingDown      = "shutting_down"
+	rejectInsufficientFunds = "insufficient_funds"
+)
+
+// latencyBuckets are the upper bounds of the latency histograms, in seconds
+var latencyBuckets = []float64{0.00001, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// counterVec is a counter with a value for every combination of label values
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	series map[string]*counterSeries // joined label values -> series
+	sync.Mutex
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, series: make(map[string]*counterSeries)}
+}
+
+// Add adds v to the counter of the label values
+func (c *counterVec) Add(v float64, labelValues ...string) {
+	c.Lock()
+	defer c.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	series, ok := c.series[key]
+	if !ok {
+		series = &counterSeries{labelValues: labelValues}
+		c.series[key] = series
+	}
+	series.value += v
+}
+
+// Inc adds 1 to the counter of the label values
+func (c *counterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Get returns the counter of the label values
+func (c *counterVec) Get(labelValues ...string) float64 {
+	c.Lock()
+	defer c.Unlock()
+
+	if series, ok := c.series[strings.Join(labelValues, "\xff")]; ok {
+		return series.value
+	}
+	return 0
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.Lock()
+	defer c.Unlock()
+
+	keys := make([]string, 0, len(c.series))
+	for key := range c.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range keys {
+		series := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, series.labelValues), formatValue(series.value))
+	}
+}
+
+// histogramVec is a histogram with a distribution for every combination of label values
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	series  map[string]*histogramSeries // joined label values -> series
+	sync.Mutex
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // number of observations in every bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+}
+
+// Observe adds an observation to the histogram of the label values
+func (h *histogramVec) Observe(v float64, labelValues ...string) {
+	h.Lock()
+	defer h.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += v
+}
+
+// Count returns the number of observations of the histogram of the label values
+func (h *histogramVec) Count(labelValues ...string) uint64 {
+	h.Lock()
+	defer h.Unlock()
+
+	if series, ok := h.series[strings.Join(labelValues, "\xff")]; ok {
+		return series.count
+	}
+	return 0
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	bucketLabels := append(append([]string{}, h.labels...), "le")
+	for _, key := range keys {
+		series := h.series[key]
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			labelValues := append(append([]string{}, series.labelValues...), formatValue(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, labelValues), cumulative)
+		}
+		labelValues := append(append([]string{}, series.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, labelValues), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, series.labelValues), formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, series.labelValues), series.count)
+	}
+}
+
+// gaugeSample is the value of a gauge for some label values
+type gaugeSample struct {
+	labelValues []string
+	value       float64
+}
+
+// writeGauge writes a gauge read at scrape time
+func writeGauge(w io.Writer, name, help string, labels []string, samples []gaugeSample) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, sample := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(labels, sample.labelValues), formatValue(sample.value))
+	}
+}
+
+// labelValueEscaper escapes label values as the Prometheus text format expects
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats label names and values, e.g {asset_id="COIN",side="BUY"}
+func formatLabels(labels, labelValues []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, label, labelValueEscaper.Replace(labelValues[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Metrics collects the counters and histograms of the exchange
+type Metrics struct {
+	ordersAccepted  *counterVec
+	ordersRejected  *counterVec
+	fills           *counterVec
+	tradedVolume    *counterVec
+	tradedNotional  *counterVec
+	matchingLatency *histogramVec
+	httpRequests    *counterVec
+	httpLatency     *histogramVec
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		ordersAccepted:  newCounterVec("exchange_orders_accepted_total", "Orders accepted and queued for matching.", "asset_id"),
+		ordersRejected:  newCounterVec("exchange_orders_rejected_total", "Orders rejected, by reason.", "reason"),
+		fills:           newCounterVec("exchange_fills_total", "Fills of orders, a trade fills a buy and a sell order.", "asset_id", "side"),
+		tradedVolume:    newCounterVec("exchange_traded_volume_total", "Number of assets traded.", "asset_id"),
+		tradedNotional:  newCounterVec("exchange_traded_notional_total", "Notional traded, in Usd cents.", "asset_id"),
+		matchingLatency: newHistogramVec("exchange_matching_latency_seconds", "Time the matching engine takes to process an order.", latencyBuckets, "asset_id"),
+		httpRequests:    newCounterVec("exchange_http_requests_total", "HTTP requests, by route and status code.", "method", "route", "code"),
+		httpLatency:     newHistogramVec("exchange_http_request_duration_seconds", "Time taken to serve HTTP requests, by route.", latencyBuckets, "method", "route"),
+	}
+}
+
+// OrderRejected counts an order rejected for a reason
+func (m *Metrics) OrderRejected(reason string) {
+	m.ordersRejected.Inc(reason)
+}
+
+// HTTPRequest counts a request served by a route and observes its latency
+func (m *Metrics) HTTPRequest(method, route string, code int, latency time.Duration) {
+	m.httpRequests.Inc(method, route, strconv.Itoa(code))
+	m.httpLatency.Observe(latency.Seconds(), method, route)
+}
+
+// AddTrade counts the fills, volume and notional of a trade
+func (m *Metrics) AddTrade(trade store.Trade) {
+	assetId := string(trade.AssetId)
+	m.fills.Inc(assetId, store.SideName(store.BUY))
+	m.fills.Inc(assetId, store.SideName(store.SELL))
+	m.tradedVolume.Add(float64(trade.Size), assetId)
+	m.tradedNotional.Add(float64(store.GetTotalAssetCost(trade.Price, trade.Size)), assetId)
+}
+
+// WriteMetrics writes every metric of the exchange in the Prometheus text format
+func (s *OrderMatchingService) WriteMetrics(w io.Writer) {
+	m := s.Metrics
+	m.ordersAccepted.write(w)
+	m.ordersRejected.write(w)
+	m.fills.write(w)
+	m.tradedVolume.write(w)
+	m.tradedNotional.write(w)
+	m.matchingLatency.write(w)
+	m.httpRequests.write(w)
+	m.httpLatency.write(w)
+
+	writeGauge(w, "exchange_order_queue_depth", "Accepted commands waiting for the matching engines.", nil,
+		[]gaugeSample{{value: float64(s.queuedOrders())}})
+	writeGauge(w, "exchange_engine_queue_depth", "Commands waiting for the matching engine of the asset.", []string{"asset_id"},
+		s.engineQueueDepths())
+
+	var orders, sizes []gaugeSample
+	for _, assetId := range s.OrderBooks.GetAssetIds() {
+		count := map[store.BuyOrSell]int{}
+		size := map[store.BuyOrSell]int{}
+		for _, order := range s.OrderBooks.GetOrders(assetId) {
+			count[order.BuyOrSell]++
+			size[order.BuyOrSell] += order.Size
+		}
+		for _, side := range []store.BuyOrSell{store.BUY, store.SELL} {
+			labelValues := []string{string(assetId), store.SideName(side)}
+			orders = append(orders, gaugeSample{labelValues: labelValues, value: float64(count[side])})
+			sizes = append(sizes, gaugeSample{labelValues: labelValues, value: float64(size[side])})
+		}
+	}
+	writeGauge(w, "exchange_book_orders", "Orders resting in the order book.", []string{"asset_id", "side"}, orders)
+	writeGauge(w, "exchange_book_depth", "Assets of the orders resting in the order book.", []string{"asset_id", "side"}, sizes)
+}
+
+// engineQueueDepths returns the number of commands waiting in the queue of the matching engine of every asset, sorted by asset id
+func (s *OrderMatchingService) engineQueueDepths() []gaugeSample {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	samples := make([]gaugeSample, 0, len(s.queues))
+	for assetId, queue := range s.queues {
+		samples = append(samples, gaugeSample{labelValues: []string{string(assetId)}, value: float64(len(queue))})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].labelValues[0] < samples[j].labelValues[0] })
+	return samples
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestHistogramVec_write(t *testing.T) {
+	h := newHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(5, "/a")
+
+	var b strings.Builder
+	h.write(&b)
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 5.55
+latency_seconds_count{route="/a"} 3
+`, b.String())
+}
+
+func TestOrderMatchingService_WriteMetrics_EngineQueueDepth(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+	setupTestUsers(s)
+
+	// the engine of an asset is blocked matching its first order, the next orders wait in its queue
+	orderBook := s.OrderBooks.OrderBook(assetId1)
+	orderBook.Lock()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY}))
+	}
+	assert.NoError(t, s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId2, Size: 1, BuyOrSell: store.BUY}))
+	assert.Eventually(t, func() bool {
+		depths := s.engineQueueDepths()
+		return len(depths) == 2 && depths[0].value == 2 && depths[1].value == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []gaugeSample{{labelValues: []string{"COIN"}, value: 2}, {labelValues: []string{"GAME"}, value: 0}}, s.engineQueueDepths())
+	assert.Equal(t, 3, s.queuedOrders())
+	orderBook.Unlock()
+
+	s.Flush()
+	var b strings.Builder
+	s.WriteMetrics(&b)
+	assert.Contains(t, b.String(), `exchange_engine_queue_depth{asset_id="COIN"} 0`)
+	assert.Contains(t, b.String(), "exchange_order_queue_depth 0")
+}
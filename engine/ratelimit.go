@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+
+	"stockexchange/store"
+)
+
+// Rate limiting
+//
+// Every user gets a token bucket per endpoint. A request takes a token from the bucket of its user and endpoint
+// and is rejected with 429 Too Many Requests if the bucket is empty. Buckets refill at the rate of the user's tier.
+// Requests are attributed to the authenticated user, or to the client's IP address for public endpoints.
+// Users are also limited in how many working orders they can have at once.
+// Buckets that refilled to their burst are the same as new buckets, so they're evicted once a minute.
+
+// bucketSweepInterval is how often full buckets are evicted
+const bucketSweepInterval = time.Minute
+
+// RateLimit configures a token bucket
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"` // rate the bucket refills at
+	Burst             int     `json:"burst"`               // max number of tokens in the bucket
+}
+
+// RateLimitTier configures the limits of a group of users
+type RateLimitTier struct {
+	RateLimit                          // limit of every endpoint without a limit of its own
+	Endpoints     map[string]RateLimit `json:"endpoints"`       // limits by endpoint, e.g "POST /users/{userId}/orders"
+	MaxOpenOrders int                  `json:"max_open_orders"` // max number of working orders per user, 0 for no limit
+}
+
+// RateLimitConfig configures the tiers of users and which tier each user is in
+type RateLimitConfig struct {
+	Tiers       map[string]RateLimitTier `json:"tiers"`        // tier name -> tier
+	DefaultTier string                   `json:"default_tier"` // tier of users without a tier and of anonymous clients
+	Users       map[store.UserId]string  `json:"users"`        // userId -> tier name
+}
+
+var defaultRateLimitConfig = RateLimitConfig{
+	Tiers: map[string]RateLimitTier{
+		"default": {
+			RateLimit:     RateLimit{RequestsPerSecond: 20, Burst: 40},
+			MaxOpenOrders: 1000,
+		},
+	},
+	DefaultTier: "default",
+}
+
+// LoadRateLimitConfig reads the rate limit config from a JSON file
+func LoadRateLimitConfig(path string) (RateLimitConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return RateLimitConfig{}, err
+	}
+
+	var config RateLimitConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return RateLimitConfig{}, fmt.Errorf("invalid rate limit file %s: %v", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return RateLimitConfig{}, fmt.Errorf("rate limit file %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// validate returns an error if the default tier or a user's tier doesn't exist, or a limit is invalid
+func (c RateLimitConfig) validate() error {
+	if _, ok := c.Tiers[c.DefaultTier]; !ok {
+		return fmt.Errorf("unknown default tier %q", c.DefaultTier)
+	}
+	for userId, tier := range c.Users {
+		if _, ok := c.Tiers[tier]; !ok {
+			return fmt.Errorf("unknown tier %q of user %s", tier, userId)
+		}
+	}
+	for name, tier := range c.Tiers {
+		limits := []RateLimit{tier.RateLimit}
+		for _, limit := range tier.Endpoints {
+			limits = append(limits, limit)
+		}
+		for _, limit := range limits {
+			if limit.RequestsPerSecond <= 0 || limit.Burst <= 0 {
+				return fmt.Errorf("tier %q: requests_per_second and burst must be positive", name)
+			}
+		}
+	}
+	return nil
+}
+
+// tokenBucket represents a token bucket refilling continuously
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // time tokens was last updated
+	limit  RateLimit // limit the bucket refills at
+}
+
+// take takes a token from the bucket. If the bucket is empty it returns false
+// and how long until a token is available.
+func (tb *tokenBucket) take(limit RateLimit, now time.Time) (bool, time.Duration) {
+	elapsed := now.Sub(tb.last).Seconds()
+	tb.tokens = math.Min(float64(limit.Burst), tb.tokens+elapsed*limit.RequestsPerSecond)
+	tb.last = now
+
+	if tb.tokens < 1 {
+		wait := (1 - tb.tokens) / limit.RequestsPerSecond
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	tb.tokens--
+	return true, 0
+}
+
+// isFull returns if the bucket refilled to its burst by now
+func (tb *tokenBucket) isFull(now time.Time) bool {
+	return tb.tokens+now.Sub(tb.last).Seconds()*tb.limit.RequestsPerSecond >= float64(tb.limit.Burst)
+}
+
+type bucketKey struct {
+	client   string // user id or ip address
+	endpoint string
+}
+
+// RateLimiter limits the request rate and open orders of users by tier
+type RateLimiter struct {
+	config    RateLimitConfig
+	buckets   map[bucketKey]*tokenBucket
+	lastSweep time.Time // time full buckets were last evicted
+	now       func() time.Time
+	sync.Mutex
+}
+
+// NewRateLimiter returns a rate limiter with a token bucket per client and endpoint
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		config:  config,
+		buckets: make(map[bucketKey]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// getTier returns the tier of a user
+func (rl *RateLimiter) getTier(userId store.UserId) RateLimitTier {
+	if tier, ok := rl.config.Tiers[rl.config.Users[userId]]; ok {
+		return tier
+	}
+	return rl.config.Tiers[rl.config.DefaultTier]
+}
+
+// MaxOpenOrders returns the max number of working orders a user can have, 0 for no limit
+func (rl *RateLimiter) MaxOpenOrders(userId store.UserId) int {
+	return rl.getTier(userId).MaxOpenOrders
+}
+
+// Allow takes a token from the bucket of a client and endpoint.
+// If the bucket is empty it returns false and how long until the client can retry.
+func (rl *RateLimiter) Allow(userId store.UserId, client, endpoint string) (bool, time.Duration) {
+	tier := rl.getTier(userId)
+	limit, ok := tier.Endpoints[endpoint]
+	if !ok {
+		limit = tier.RateLimit
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	if now.Sub(rl.lastSweep) >= bucketSweepInterval {
+		rl.sweep(now)
+	}
+	key := bucketKey{client: client, endpoint: endpoint}
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		rl.buckets[key] = bucket
+	}
+	return bucket.take(limit, now)
+}
+
+// sweep evicts the buckets that refilled to their burst, the rate limiter must be locked
+func (rl *RateLimiter) sweep(now time.Time) {
+	for key, bucket := range rl.buckets {
+		if bucket.isFull(now) {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
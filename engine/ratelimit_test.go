@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{
+		Tiers: map[string]RateLimitTier{
+			"retail": {RateLimit: RateLimit{RequestsPerSecond: 1, Burst: 2}},
+			"market-maker": {
+				RateLimit: RateLimit{RequestsPerSecond: 10, Burst: 10},
+				Endpoints: map[string]RateLimit{"POST /users/{userId}/orders": {RequestsPerSecond: 100, Burst: 100}},
+			},
+		},
+		DefaultTier: "retail",
+		Users:       map[store.UserId]string{userId2: "market-maker"},
+	})
+	now := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	rl.now = func() time.Time { return now }
+
+	// burst of 2, then empty
+	ok, _ := rl.Allow(userId1, "user:userId1", "GET /tickers")
+	assert.True(t, ok)
+	ok, _ = rl.Allow(userId1, "user:userId1", "GET /tickers")
+	assert.True(t, ok)
+	ok, retryAfter := rl.Allow(userId1, "user:userId1", "GET /tickers")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, retryAfter)
+
+	// buckets are per endpoint
+	ok, _ = rl.Allow(userId1, "user:userId1", "GET /users/{userId}/orders")
+	assert.True(t, ok)
+
+	// bucket refills over time
+	now = now.Add(500 * time.Millisecond)
+	ok, retryAfter = rl.Allow(userId1, "user:userId1", "GET /tickers")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = rl.Allow(userId1, "user:userId1", "GET /tickers")
+	assert.True(t, ok)
+
+	// tier and endpoint limits
+	for i := 0; i < 100; i++ {
+		ok, _ = rl.Allow(userId2, "user:userId2", "POST /users/{userId}/orders")
+		assert.True(t, ok)
+	}
+	ok, _ = rl.Allow(userId2, "user:userId2", "POST /users/{userId}/orders")
+	assert.False(t, ok)
+}
+
+func TestRateLimiter_sweep(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{
+		Tiers:       map[string]RateLimitTier{"retail": {RateLimit: RateLimit{RequestsPerSecond: 1, Burst: 100}}},
+		DefaultTier: "retail",
+	})
+	now := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	rl.now = func() time.Time { return now }
+
+	// a client with an empty bucket and clients with full buckets
+	for i := 0; i < 100; i++ {
+		rl.Allow(userId1, "user:userId1", "GET /tickers")
+	}
+	rl.Allow("", "ip:10.0.0.1", "GET /tickers")
+	rl.Allow("", "ip:10.0.0.2", "GET /tickers")
+	assert.Equal(t, 3, len(rl.buckets))
+
+	// full buckets are evicted once a minute, the bucket that's still refilling is kept
+	now = now.Add(bucketSweepInterval)
+	rl.Allow("", "ip:10.0.0.3", "GET /tickers")
+	assert.Equal(t, 2, len(rl.buckets))
+	ok, _ := rl.Allow(userId1, "user:userId1", "GET /tickers")
+	assert.True(t, ok)
+	assert.Equal(t, 59.0, rl.buckets[bucketKey{client: "user:userId1", endpoint: "GET /tickers"}].tokens)
+
+	// once every bucket refilled only the new one is left
+	now = now.Add(bucketSweepInterval)
+	rl.Allow("", "ip:10.0.0.1", "GET /tickers")
+	assert.Equal(t, 1, len(rl.buckets))
+}
+
+func TestRateLimitConfig_Validate(t *testing.T) {
+	assert.NoError(t, defaultRateLimitConfig.validate())
+	assert.Error(t, RateLimitConfig{Tiers: defaultRateLimitConfig.Tiers, DefaultTier: "vip"}.validate())
+	assert.Error(t, RateLimitConfig{Tiers: defaultRateLimitConfig.Tiers, DefaultTier: "default", Users: map[store.UserId]string{userId1: "vip"}}.validate())
+	assert.Error(t, RateLimitConfig{Tiers: map[string]RateLimitTier{"default": {}}, DefaultTier: "default"}.validate())
+}
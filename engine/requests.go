@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"stockexchange/store"
+)
+
+type OrderReq struct {
+	OrderId       store.OrderId     `json:"-" yaml:"-"`                                   // id assigned to the val once accepted
+	ClientOrderId string            `json:"client_order_id" yaml:"client_order_id"`       // optional id of the val chosen by the user, unique across the user's open orders
+	UserId        store.UserId      `json:"user_id" yaml:"user_id"`                       // id of user making the val
+	Limit         store.Usd         `json:"limit" yaml:"limit"`                           // Limit price, in usd cents
+	AssetId       store.AssetId     `json:"asset_id" yaml:"asset_id"`                     // asset to trade
+	Size          int               `json:"size" yaml:"size"`                             // number of assets
+	BuyOrSell     store.BuyOrSell   `json:"buy_or_sell" yaml:"buy_or_sell"`               // BUY or SELL
+	OrderType     store.OrderType   `json:"order_type,omitempty" yaml:"order_type"`       // LIMIT, the default
+	TimeInForce   store.TimeInForce `json:"time_in_force,omitempty" yaml:"time_in_force"` // GTC, the default, IOC or FOK
+	RequestId     string            `json:"-" yaml:"-"`                                   // id of the request that placed the val
+}
+
+type TransferReq struct {
+	Type           store.TransferType `json:"type"`            // DEPOSIT or WITHDRAWAL
+	AssetId        store.AssetId      `json:"asset_id"`        // asset to transfer, empty to transfer cash
+	Amount         int                `json:"amount"`          // cash in Usd cents or number of assets
+	IdempotencyKey string             `json:"idempotency_key"` // unique key of the transfer, also accepted in the Idempotency-Key header
+}
+
+type AmendOrderReq struct {
+	Limit     store.Usd `json:"limit"` // new limit price, in Usd cents, 0 keeps the order's limit
+	Size      int       `json:"size"`  // new number of assets, 0 keeps the order's remaining size
+	RequestId string    `json:"-"`     // id of the request that amended the order
+}
+
+// withOrderDefaults returns an order request with the default order type and time in force if they aren't set
+func withOrderDefaults(or OrderReq) OrderReq {
+	if or.OrderType == "" {
+		or.OrderType = store.Limit
+	}
+	if or.TimeInForce == "" {
+		or.TimeInForce = store.GTC
+	}
+	return or
+}
+
+func validateOrderReq(userData store.UserData, or OrderReq, feeReserve store.Usd) error {
+	// validate the enums of orders built without unmarshalling, e.g by the programs embedding the engine
+	if or.BuyOrSell != store.BUY && or.BuyOrSell != store.SELL {
+		return fmt.Errorf("invalid side %d, must be BUY or SELL", or.BuyOrSell)
+	}
+	if or.OrderType != "" && !or.OrderType.IsValid() {
+		return fmt.Errorf("invalid order type %s", or.OrderType)
+	}
+	if or.TimeInForce != "" && !or.TimeInForce.IsValid() {
+		return fmt.Errorf("invalid time in force %s", or.TimeInForce)
+	}
+	// validate userId is present in db
+	if userData.UserId == "" {
+		return fmt.Errorf("userId: %s not an actual user", or.UserId)
+	}
+	// validate user owns asset
+	if _, ok := userData.Assets[or.AssetId]; !ok {
+		return fmt.Errorf("user doesn't own AssetId:%s", or.AssetId)
+	}
+	// validate user has enough cash to buy, and to pay the fees reserved by the order
+	if or.BuyOrSell == store.BUY && userData.Cash < store.GetTotalAssetCost(or.Limit, or.Size)+feeReserve {
+		return errors.New("user doesn't have enough cash")
+	}
+	// validate user has enough assets to sell
+	if or.BuyOrSell == store.SELL && userData.Assets[or.AssetId] < or.Size {
+		return errors.New("user doesn't have enough assets to sell")
+	}
+
+	return nil
+}
+
+// ValidateInitExchangeReq checks the user, cash and assets of a request to create a user
+func ValidateInitExchangeReq(req store.InitExchangeReq) error {
+	if req.UserId == "" {
+		return errors.New("user_id is required")
+	}
+	if req.Cash < 0 {
+		return errors.New("cash can't be negative")
+	}
+	for _, asset := range req.Assets {
+		if asset.AssetId == "" || asset.Size < 0 {
+			return errors.New("assets need an asset_id and a size that isn't negative")
+		}
+	}
+	return nil
+}
+
+// orderReqLogFields returns the fields logged to trace an order request
+func orderReqLogFields(or OrderReq) []interface{} {
+	return []interface{}{"request_id", or.RequestId, "order_id", or.OrderId, "client_order_id", or.ClientOrderId,
+		"user_id", or.UserId, "asset_id", or.AssetId, "side", store.SideName(or.BuyOrSell), "size", or.Size, "limit", or.Limit,
+		"time_in_force", or.TimeInForce}
+}
+
+// createOrderFromOrderReq creates an Order{} struct given an orderReq struct{}
+// The order is created at eventAt by the command with sequence number seq.
+func createOrderFromOrderReq(or OrderReq, eventAt time.Time, seq uint64) store.Order {
+	return store.Order{
+		OrderId:       or.OrderId,
+		ClientOrderId: or.ClientOrderId,
+		UserId:        or.UserId,
+		Limit:         or.Limit,
+		AssetId:       or.AssetId,
+		Size:          or.Size,
+		BuyOrSell:     or.BuyOrSell,
+		OrderType:     or.OrderType,
+		TimeInForce:   or.TimeInForce,
+		EventAt:       eventAt,
+		Status:        store.Working,
+		Seq:           seq,
+		UpdateSeq:     seq,
+		RequestId:     or.RequestId,
+	}
+}
+
+// createTransferFromTransferReq creates a Transfer{} struct given a TransferReq struct{}
+// The transfer is created at eventAt by the command with sequence number seq.
+func createTransferFromTransferReq(transferId store.TransferId, userId store.UserId, tr TransferReq, eventAt time.Time, seq uint64) store.Transfer {
+	return store.Transfer{
+		TransferId:     transferId,
+		UserId:         userId,
+		TransferType:   tr.Type,
+		AssetId:        tr.AssetId,
+		Amount:         tr.Amount,
+		IdempotencyKey: tr.IdempotencyKey,
+		EventAt:        eventAt,
+		Seq:            seq,
+	}
+}
+
+// ValidateTransferReq checks the type and amount of a deposit or withdrawal
+func ValidateTransferReq(tr TransferReq) error {
+	if tr.Type != store.Deposit && tr.Type != store.Withdrawal {
+		return fmt.Errorf("type must be %s or %s", store.Deposit, store.Withdrawal)
+	}
+	if tr.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if tr.IdempotencyKey == "" {
+		return errors.New("idempotency key is required")
+	}
+	return nil
+}
+
+// encodeCursor encodes the position of the next order of a page of orders into an opaque cursor
+func encodeCursor(position int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(position)))
+}
+
+// decodeCursor decodes a cursor returned by encodeCursor
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	position, err := strconv.Atoi(string(data))
+	if err != nil || position < 0 {
+		return 0, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	return position, nil
+}
+
+// isSameOrderReq returns if two order requests are for the same order
+func isSameOrderReq(a, b OrderReq) bool {
+	return a.UserId == b.UserId && a.AssetId == b.AssetId && a.Limit == b.Limit && a.Size == b.Size && a.BuyOrSell == b.BuyOrSell &&
+		a.OrderType == b.OrderType && a.TimeInForce == b.TimeInForce
+}
+
+// withOrderCanceled returns a copy of a user's data as it will be once a working order is canceled,
+// with the cash or assets reserved by the order released
+func withOrderCanceled(userData store.UserData, order store.Order) store.UserData {
+	if order.BuyOrSell == store.BUY {
+		userData.Cash += store.GetTotalAssetCost(order.Limit, order.Size-order.Filled) + order.FeeReserve
+	} else {
+		userData.Assets[order.AssetId] += order.Size - order.Filled
+	}
+	order.Status = store.Canceled
+	userData.Orders[order.OrderId] = order
+	return userData
+}
+
+// amendOrderReqToOrderReq creates the request replacing a canceled order with an amended limit and size
+func amendOrderReqToOrderReq(order store.Order, req AmendOrderReq) OrderReq {
+	or := OrderReq{
+		ClientOrderId: order.ClientOrderId,
+		UserId:        order.UserId,
+		Limit:         order.Limit,
+		AssetId:       order.AssetId,
+		Size:          order.Size - order.Filled,
+		BuyOrSell:     order.BuyOrSell,
+		OrderType:     order.OrderType,
+		TimeInForce:   order.TimeInForce,
+		RequestId:     req.RequestId,
+	}
+	if req.Limit > 0 {
+		or.Limit = req.Limit
+	}
+	if req.Size > 0 {
+		or.Size = req.Size
+	}
+	return or
+}
+
+// orderReqFromOrder returns the request of an order
+func orderReqFromOrder(order store.Order) OrderReq {
+	return OrderReq{
+		OrderId:       order.OrderId,
+		ClientOrderId: order.ClientOrderId,
+		UserId:        order.UserId,
+		Limit:         order.Limit,
+		AssetId:       order.AssetId,
+		Size:          order.Size,
+		BuyOrSell:     order.BuyOrSell,
+		OrderType:     order.OrderType,
+		TimeInForce:   order.TimeInForce,
+		RequestId:     order.RequestId,
+	}
+}
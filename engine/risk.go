@@ -0,0 +1,268 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"stockexchange/store"
+)
+
+// Pre-trade risk checks
+//
+// Every new order is checked against the risk rules before it's sent to the order book.
+// Rules are pluggable, each one checks an order against the limits of its user and
+// rejects it with a rule specific code. A limit of 0 disables its rule.
+
+// RiskLimits configures the pre-trade risk limits of a user
+type RiskLimits struct {
+	MaxOrderNotional     store.Usd `json:"max_order_notional"`      // max limit * size of an order, in Usd cents
+	MaxPosition          int       `json:"max_position"`            // max number of assets of an asset a user can hold and buy
+	MaxPriceDeviationBps int       `json:"max_price_deviation_bps"` // max distance of an order's limit from the last trade price, in basis points
+	MaxDailyNotional     store.Usd `json:"max_daily_notional"`      // max notional a user can trade in a UTC day, in Usd cents
+}
+
+// RiskConfig configures the risk limits of every user
+type RiskConfig struct {
+	Default RiskLimits                  `json:"default"` // limits of users without limits of their own
+	Users   map[store.UserId]RiskLimits `json:"users"`   // userId -> limits
+}
+
+// LoadRiskConfig reads the risk limits from a JSON file
+func LoadRiskConfig(path string) (RiskConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return RiskConfig{}, err
+	}
+
+	var config RiskConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return RiskConfig{}, fmt.Errorf("invalid risk limits file %s: %v", path, err)
+	}
+	if err := config.Default.validate(); err != nil {
+		return RiskConfig{}, fmt.Errorf("risk limits file %s: default: %v", path, err)
+	}
+	for userId, limits := range config.Users {
+		if err := limits.validate(); err != nil {
+			return RiskConfig{}, fmt.Errorf("risk limits file %s: user %s: %v", path, userId, err)
+		}
+	}
+	return config, nil
+}
+
+// validate returns an error if a limit is negative
+func (l RiskLimits) validate() error {
+	if l.MaxOrderNotional < 0 || l.MaxPosition < 0 || l.MaxPriceDeviationBps < 0 || l.MaxDailyNotional < 0 {
+		return fmt.Errorf("limits can't be negative")
+	}
+	return nil
+}
+
+type RiskRejectCode string
+
+const (
+	MaxOrderNotionalExceeded RiskRejectCode = "MAX_ORDER_NOTIONAL"
+	MaxPositionExceeded      RiskRejectCode = "MAX_POSITION"
+	PriceDeviationExceeded   RiskRejectCode = "PRICE_DEVIATION"
+	MaxDailyNotionalExceeded RiskRejectCode = "MAX_DAILY_NOTIONAL"
+)
+
+// RiskRejection is the error of an order rejected by a risk rule
+type RiskRejection struct {
+	Code   RiskRejectCode
+	Reason string
+}
+
+func (r *RiskRejection) Error() string {
+	return fmt.Sprintf("%s: %s", r.Code, r.Reason)
+}
+
+func rejectOrder(code RiskRejectCode, format string, a ...interface{}) *RiskRejection {
+	return &RiskRejection{Code: code, Reason: fmt.Sprintf(format, a...)}
+}
+
+// RiskCheck is what a risk rule checks a new order against
+type RiskCheck struct {
+	Order         OrderReq       // new order
+	Limits        RiskLimits     // limits of the order's user
+	UserData      store.UserData // cash, assets and working orders of the order's user
+	LastPrice     store.Usd      // last trade price of the order's asset, 0 if it never traded
+	DailyNotional store.Usd      // notional the order's user traded today, in Usd cents
+}
+
+// RiskRule checks if a new order is within a risk limit
+type RiskRule interface {
+	Check(check RiskCheck) *RiskRejection
+}
+
+// RiskRuleFunc adapts a function to a RiskRule
+type RiskRuleFunc func(check RiskCheck) *RiskRejection
+
+func (f RiskRuleFunc) Check(check RiskCheck) *RiskRejection {
+	return f(check)
+}
+
+var defaultRiskRules = []RiskRule{
+	RiskRuleFunc(checkOrderNotional),
+	RiskRuleFunc(checkPosition),
+	RiskRuleFunc(checkPriceDeviation),
+	RiskRuleFunc(checkDailyNotional),
+}
+
+// checkOrderNotional rejects orders worth more than the max order notional
+func checkOrderNotional(check RiskCheck) *RiskRejection {
+	max := check.Limits.MaxOrderNotional
+	if notional := store.GetTotalAssetCost(check.Order.Limit, check.Order.Size); max > 0 && notional > max {
+		return rejectOrder(MaxOrderNotionalExceeded, "order notional %d is over the max of %d", notional, max)
+	}
+	return nil
+}
+
+// checkPosition rejects buy orders that could take a user's position in an asset over the max position.
+// The position includes the assets held, the assets in working sell orders and the assets of working buy orders.
+func checkPosition(check RiskCheck) *RiskRejection {
+	max := check.Limits.MaxPosition
+	if max == 0 || check.Order.BuyOrSell != store.BUY {
+		return nil
+	}
+
+	position := check.UserData.Assets[check.Order.AssetId] + check.Order.Size
+	for _, order := range check.UserData.Orders {
+		if order.AssetId == check.Order.AssetId && order.Status == store.Working {
+			position += order.Size - order.Filled
+		}
+	}
+	if position > max {
+		return rejectOrder(MaxPositionExceeded, "position in %s would be %d, over the max of %d", check.Order.AssetId, position, max)
+	}
+	return nil
+}
+
+// checkPriceDeviation rejects orders with a limit too far from the last trade price, e.g a mistyped price
+func checkPriceDeviation(check RiskCheck) *RiskRejection {
+	max := check.Limits.MaxPriceDeviationBps
+	if max == 0 || check.LastPrice == 0 {
+		return nil
+	}
+
+	deviation := int(check.Order.Limit - check.LastPrice)
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	deviationBps := deviation * 10000 / int(check.LastPrice)
+	if deviationBps > max {
+		return rejectOrder(PriceDeviationExceeded, "limit %d is %d bps away from the last price %d, over the max of %d bps",
+			check.Order.Limit, deviationBps, check.LastPrice, max)
+	}
+	return nil
+}
+
+// checkDailyNotional rejects orders that could take a user's traded notional of the day over the max daily notional
+func checkDailyNotional(check RiskCheck) *RiskRejection {
+	max := check.Limits.MaxDailyNotional
+	if notional := check.DailyNotional + store.GetTotalAssetCost(check.Order.Limit, check.Order.Size); max > 0 && notional > max {
+		return rejectOrder(MaxDailyNotionalExceeded, "daily notional would be %d, over the max of %d", notional, max)
+	}
+	return nil
+}
+
+// dailyNotional represents the notional a user traded in a UTC day
+type dailyNotional struct {
+	day      time.Time
+	notional store.Usd
+}
+
+// RiskChecker checks new orders against the risk rules and tracks the notional users trade every day
+type RiskChecker struct {
+	Config RiskConfig
+	rules  []RiskRule
+	daily  map[store.UserId]dailyNotional
+	now    func() time.Time
+	sync.Mutex
+}
+
+func newRiskChecker(config RiskConfig) *RiskChecker {
+	return &RiskChecker{
+		Config: config,
+		rules:  append([]RiskRule{}, defaultRiskRules...),
+		daily:  make(map[store.UserId]dailyNotional),
+		now:    time.Now,
+	}
+}
+
+// AddRule adds a rule every new order is checked against
+func (rc *RiskChecker) AddRule(rule RiskRule) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	rc.rules = append(rc.rules, rule)
+}
+
+// GetLimits returns the risk limits of a user
+func (rc *RiskChecker) GetLimits(userId store.UserId) RiskLimits {
+	if limits, ok := rc.Config.Users[userId]; ok {
+		return limits
+	}
+	return rc.Config.Default
+}
+
+// AddTrade adds the notional of a trade to the daily notional of its buyer and seller
+func (rc *RiskChecker) AddTrade(trade store.Trade) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	notional := store.GetTotalAssetCost(trade.Price, trade.Size)
+	day := startOfDay(trade.ExecutedAt)
+	for _, userId := range []store.UserId{trade.BuyerId, trade.SellerId} {
+		daily := rc.daily[userId]
+		if day.Before(daily.day) {
+			continue // trade of a previous day
+		}
+		if day.After(daily.day) {
+			daily = dailyNotional{day: day}
+		}
+		daily.notional += notional
+		rc.daily[userId] = daily
+	}
+}
+
+// GetDailyNotional returns the notional a user traded today
+func (rc *RiskChecker) GetDailyNotional(userId store.UserId) store.Usd {
+	rc.Lock()
+	defer rc.Unlock()
+
+	daily := rc.daily[userId]
+	if !daily.day.Equal(startOfDay(rc.now())) {
+		return 0
+	}
+	return daily.notional
+}
+
+// Check checks a new order against every risk rule and returns the rejection of the first rule it breaks
+func (rc *RiskChecker) Check(userData store.UserData, lastPrice store.Usd, or OrderReq) *RiskRejection {
+	check := RiskCheck{
+		Order:         or,
+		Limits:        rc.GetLimits(or.UserId),
+		UserData:      userData,
+		LastPrice:     lastPrice,
+		DailyNotional: rc.GetDailyNotional(or.UserId),
+	}
+
+	rc.Lock()
+	rules := rc.rules
+	rc.Unlock()
+
+	for _, rule := range rules {
+		if rejection := rule.Check(check); rejection != nil {
+			return rejection
+		}
+	}
+	return nil
+}
+
+// startOfDay returns the start of the UTC day of a time
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestRiskChecker_Check(t *testing.T) {
+	rc := newRiskChecker(RiskConfig{
+		Default: RiskLimits{MaxOrderNotional: 5000, MaxPosition: 120, MaxPriceDeviationBps: 1000, MaxDailyNotional: 7000},
+		Users:   map[store.UserId]RiskLimits{userId2: {}},
+	})
+	now := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	rc.now = func() time.Time { return now }
+
+	userData := store.UserData{
+		UserId: userId1,
+		Cash:   10000,
+		Assets: map[store.AssetId]int{assetId1: 100},
+		Orders: map[store.OrderId]store.Order{
+			"bo1": {OrderId: "bo1", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 15, Filled: 5, BuyOrSell: store.BUY, Status: store.Working},
+			"bo2": {OrderId: "bo2", UserId: userId1, AssetId: assetId1, Limit: 100, Size: 50, Filled: 50, BuyOrSell: store.BUY, Status: store.Complete},
+		},
+	}
+	rc.AddTrade(store.Trade{AssetId: assetId1, Price: 100, Size: 30, BuyerId: userId1, SellerId: userId2, ExecutedAt: now.Add(-time.Hour)})
+	rc.AddTrade(store.Trade{AssetId: assetId1, Price: 100, Size: 50, BuyerId: userId1, SellerId: userId2, ExecutedAt: now.Add(-24 * time.Hour)})
+
+	tests := []struct {
+		name string
+		or   OrderReq
+		code RiskRejectCode
+	}{
+		{"within limits", OrderReq{UserId: userId1, AssetId: assetId1, Limit: 100, Size: 10, BuyOrSell: store.BUY}, ""},
+		{"order notional", OrderReq{UserId: userId1, AssetId: assetId1, Limit: 100, Size: 51, BuyOrSell: store.SELL}, MaxOrderNotionalExceeded},
+		{"position", OrderReq{UserId: userId1, AssetId: assetId1, Limit: 100, Size: 11, BuyOrSell: store.BUY}, MaxPositionExceeded},
+		{"position of other asset", OrderReq{UserId: userId1, AssetId: assetId2, Limit: 100, Size: 11, BuyOrSell: store.BUY}, ""},
+		{"sells don't add to position", OrderReq{UserId: userId1, AssetId: assetId1, Limit: 100, Size: 20, BuyOrSell: store.SELL}, ""},
+		{"price deviation", OrderReq{UserId: userId1, AssetId: assetId1, Limit: 111, Size: 1, BuyOrSell: store.SELL}, PriceDeviationExceeded},
+		{"price deviation below", OrderReq{UserId: userId1, AssetId: assetId1, Limit: 89, Size: 1, BuyOrSell: store.SELL}, PriceDeviationExceeded},
+		{"daily notional", OrderReq{UserId: userId1, AssetId: assetId1, Limit: 100, Size: 50, BuyOrSell: store.SELL}, MaxDailyNotionalExceeded},
+		{"user without limits", OrderReq{UserId: userId2, AssetId: assetId1, Limit: 1000, Size: 1000, BuyOrSell: store.BUY}, ""},
+	}
+
+	for _, test := range tests {
+		rejection := rc.Check(userData, 100, test.or)
+		if test.code == "" {
+			assert.Nil(t, rejection, test.name)
+		} else if assert.NotNil(t, rejection, test.name) {
+			assert.Equal(t, test.code, rejection.Code, test.name)
+		}
+	}
+
+	// no last trade, no price deviation check
+	assert.Nil(t, rc.Check(userData, 0, OrderReq{UserId: userId1, AssetId: assetId1, Limit: 200, Size: 1, BuyOrSell: store.SELL}))
+
+	// daily notional resets at the start of the next day
+	assert.Equal(t, store.Usd(3000), rc.GetDailyNotional(userId1))
+	now = now.Add(24 * time.Hour)
+	assert.Equal(t, store.Usd(0), rc.GetDailyNotional(userId1))
+}
+
+func TestRiskChecker_AddRule(t *testing.T) {
+	rc := newRiskChecker(RiskConfig{})
+	rc.AddRule(RiskRuleFunc(func(check RiskCheck) *RiskRejection {
+		if check.Order.AssetId == assetId2 {
+			return rejectOrder("RESTRICTED_ASSET", "%s is restricted", check.Order.AssetId)
+		}
+		return nil
+	}))
+
+	assert.Nil(t, rc.Check(store.UserData{}, 0, OrderReq{UserId: userId1, AssetId: assetId1, Limit: 100, Size: 1}))
+	assert.Equal(t, RiskRejectCode("RESTRICTED_ASSET"), rc.Check(store.UserData{}, 0, OrderReq{UserId: userId1, AssetId: assetId2, Limit: 100, Size: 1}).Code)
+}
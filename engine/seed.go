@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+	"stockexchange/store"
+)
+
+// Fixture is the state an exchange is seeded with at startup, e.g so dev, demo and test environments
+// come up in a known state. It's read from a YAML or JSON file, see seed.example.yaml.
+type Fixture struct {
+	Instruments []Instrument            `yaml:"instruments"` // instruments listed
+	Users       []store.InitExchangeReq `yaml:"users"`       // users created with their cash and assets
+	Orders      []OrderReq              `yaml:"orders"`      // orders placed once the users are created, in order
+}
+
+// LoadFixture reads a fixture from a YAML or JSON file. The sides of its orders are BUY or SELL,
+// or 0 or 1 too with numericSides, for the fixtures of the numeric sides of earlier versions.
+func LoadFixture(path string, numericSides bool) (Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Fixture{}, err
+	}
+	if numericSides {
+		if data, err = nameNumericSides(data); err != nil {
+			return Fixture{}, fmt.Errorf("invalid fixture file %s: %v", path, err)
+		}
+	}
+
+	var fixture Fixture
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fixture); err != nil {
+		return Fixture{}, fmt.Errorf("invalid fixture file %s: %v", path, err)
+	}
+	return fixture, nil
+}
+
+// nameNumericSides returns a YAML or JSON document with the 0 and 1 sides of its orders replaced by BUY and SELL
+func nameNumericSides(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || doc.Kind == 0 {
+		return data, err
+	}
+	var rewrite func(node *yaml.Node)
+	rewrite = func(node *yaml.Node) {
+		for i := 0; node.Kind == yaml.MappingNode && i+1 < len(node.Content); i += 2 {
+			if side := node.Content[i+1]; node.Content[i].Value == "buy_or_sell" && side.Tag == "!!int" {
+				switch side.Value {
+				case "0":
+					side.SetString("BUY")
+				case "1":
+					side.SetString("SELL")
+				}
+			}
+		}
+		for _, child := range node.Content {
+			rewrite(child)
+		}
+	}
+	rewrite(&doc)
+	return yaml.Marshal(&doc)
+}
+
+// Seed lists the instruments of a fixture, creates its users and places its orders.
+// Users that already exist are left unchanged. Orders are checked like the orders of the api and
+// matched as they're placed, so orders meant to rest in the book shouldn't cross.
+// Seed must be called before orders are accepted: it matches the orders from the caller's goroutine,
+// so it returns an error once orders were queued, and commands accepted while it runs wait until it's done.
+func (s *OrderMatchingService) Seed(fixture Fixture) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	for s.queued > 0 { // e.g cancels still running on the engines
+		s.dequeued.Wait()
+	}
+	if s.ordersQueued || s.closed {
+		return errors.New("orders were already placed, the exchange must be seeded before it accepts orders")
+	}
+
+	for _, instrument := range fixture.Instruments {
+		if err := s.ListInstrument(instrument); err != nil {
+			return fmt.Errorf("instruments: %v", err)
+		}
+	}
+
+	for _, user := range fixture.Users {
+		if err := ValidateInitExchangeReq(user); err != nil {
+			return fmt.Errorf("users: %v", err)
+		}
+		s.createUser(user)
+	}
+
+	for i, or := range fixture.Orders {
+		or = withOrderDefaults(or)
+		if _, err := s.checkOrderReq(s.Store.GetUserAccount(or.UserId), or); err != nil {
+			return fmt.Errorf("orders[%d]: %v", i, err)
+		}
+
+		or.OrderId = s.createOrderId()
+		if or.ClientOrderId != "" {
+			if _, reserved := s.ClientOrders.Reserve(or, s.isOpenOrder); !reserved {
+				return fmt.Errorf("orders[%d]: client_order_id %s is already used by an open order", i, or.ClientOrderId)
+			}
+		}
+		cmd := s.sequence()
+		order := s.saveOrder(or, cmd)
+		s.journalCommand(JournalEntry{Type: NewOrderCommand, Seq: cmd.Seq, At: cmd.At, Order: order})
+		if order.Status == store.Rejected {
+			return fmt.Errorf("orders[%d]: %s", i, order.Reason)
+		}
+		s.matchOrder(order, cmd)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestLoadFixture(t *testing.T) {
+	fixture, err := LoadFixture("../seed.example.yaml", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(fixture.Instruments))
+	assert.Equal(t, store.InitExchangeReq{UserId: "maker1", Cash: 1000000, Assets: []store.Asset{{AssetId: "COIN", Size: 500}}}, fixture.Users[0])
+	assert.Equal(t, OrderReq{ClientOrderId: "ask-1", UserId: "maker2", Limit: 10100, AssetId: "COIN", Size: 10, BuyOrSell: store.SELL}, fixture.Orders[1])
+
+	// JSON fixtures are read too
+	dir := t.TempDir()
+	path := filepath.Join(dir, "seed.json")
+	json := `{"users": [{"user_id": "user1", "cash": 100}], "orders": [{"user_id": "user1", "asset_id": "COIN", "limit": 10, "size": 1, "buy_or_sell": "BUY"}]}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(json), 0644))
+	fixture, err = LoadFixture(path, false)
+	assert.NoError(t, err)
+	assert.Equal(t, store.InitExchangeReq{UserId: "user1", Cash: 100}, fixture.Users[0])
+	assert.Equal(t, OrderReq{UserId: "user1", AssetId: "COIN", Limit: 10, Size: 1, BuyOrSell: store.BUY}, fixture.Orders[0])
+
+	// numeric sides are only read if enabled
+	numeric := `{"orders": [{"user_id": "user1", "asset_id": "COIN", "limit": 10, "size": 1, "buy_or_sell": 1}]}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(numeric), 0644))
+	_, err = LoadFixture(path, false)
+	assert.Contains(t, err.Error(), "invalid side 1")
+	fixture, err = LoadFixture(path, true)
+	assert.NoError(t, err)
+	assert.Equal(t, store.SELL, fixture.Orders[0].BuyOrSell)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"userz": []}`), 0644))
+	_, err = LoadFixture(path, false)
+	assert.Error(t, err) // unknown fields are rejected
+	_, err = LoadFixture(filepath.Join(dir, "missing.yaml"), false)
+	assert.Error(t, err)
+}
+
+func TestSeed(t *testing.T) {
+	s := NewOrderMatchingService()
+	fixture, err := LoadFixture("../seed.example.yaml", false)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Seed(fixture))
+
+	_, listed := s.Instruments.Get("COIN")
+	assert.True(t, listed)
+	assert.Equal(t, store.Usd(10100), s.OrderBooks.GetTopOrder("COIN", store.SELL).Limit)
+	assert.Equal(t, store.Usd(9900), s.OrderBooks.GetTopOrder("COIN", store.BUY).Limit)
+	assert.Equal(t, 2, len(s.OrderBooks.GetOrders("COIN")))
+
+	// the cash of resting buys and the assets of resting sells are reserved
+	assert.Equal(t, store.Usd(1000000-9900*10), s.Store.GetUserData("maker1").Cash)
+	assert.Equal(t, 490, s.Store.GetUserData("maker2").Assets["COIN"])
+	assert.Contains(t, s.ClientOrders.orders["maker1"], "bid-1")
+
+	// seeding again keeps the users and adds the orders
+	assert.NoError(t, s.Seed(Fixture{Orders: []OrderReq{{UserId: "maker1", AssetId: "COIN", Limit: 9800, Size: 1, BuyOrSell: store.BUY}}}))
+	assert.Equal(t, 3, len(s.OrderBooks.GetOrders("COIN")))
+
+	users := []store.InitExchangeReq{{UserId: "maker3", Cash: 1000, Assets: []store.Asset{{AssetId: "GAME", Size: 10}}}}
+	err = s.Seed(Fixture{Users: users, Orders: []OrderReq{{UserId: "maker3", AssetId: "GAME", Limit: 100, Size: 1, BuyOrSell: store.SELL}}})
+	assert.EqualError(t, err, "orders[0]: asset GAME is not listed")
+	err = s.Seed(Fixture{Orders: []OrderReq{{UserId: "unknown", AssetId: "COIN", Limit: 100, Size: 1, BuyOrSell: store.BUY}}})
+	assert.Error(t, err)
+	err = s.Seed(Fixture{Orders: []OrderReq{{UserId: "maker1", ClientOrderId: "bid-1", AssetId: "COIN", Limit: 100, Size: 1, BuyOrSell: store.BUY}}})
+	assert.Error(t, err)
+	err = s.Seed(Fixture{Instruments: []Instrument{{Name: "no asset id"}}})
+	assert.Error(t, err)
+
+	// orders are checked like the orders of the api, e.g suspended users can't place orders
+	assert.NoError(t, s.SuspendUser("maker1"))
+	err = s.Seed(Fixture{Orders: []OrderReq{{UserId: "maker1", AssetId: "COIN", Limit: 9800, Size: 1, BuyOrSell: store.BUY}}})
+	assert.EqualError(t, err, "orders[0]: user maker1 is suspended")
+
+	// the exchange can't be seeded once it accepted orders
+	assert.NoError(t, s.SubmitOrder(OrderReq{UserId: "maker2", AssetId: "COIN", Limit: 10200, Size: 1, BuyOrSell: store.SELL}))
+	err = s.Seed(Fixture{Users: []store.InitExchangeReq{{UserId: "maker4"}}})
+	assert.EqualError(t, err, "orders were already placed, the exchange must be seeded before it accepts orders")
+	assert.False(t, s.Store.HasUser("maker4"))
+}
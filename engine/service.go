@@ -0,0 +1,979 @@
+// Package engine runs the exchange in-process. An OrderMatchingService validates orders, queues them to a
+// matching engine per asset and keeps the market data, risk checks, rate limits, metrics and logs of the exchange.
+//
+// To embed the exchange, create a service, which starts its matching engines, and place orders:
+//
+//	s := engine.NewOrderMatchingService()
+//	s.InitExchange([]store.InitExchangeReq{{UserId: "user1", Cash: 10000, Assets: []store.Asset{{AssetId: "COIN", Size: 0}}}})
+//	placed, _, err := s.PlaceOrder(engine.OrderReq{UserId: "user1", AssetId: "COIN", Size: 10, Limit: 100, BuyOrSell: store.BUY})
+//
+// Orders are matched asynchronously, their status is read from the store, e.g s.Store.GetUserData("user1").Orders[placed.OrderId],
+// Flush waits until the orders placed so far are matched.
+// Shutdown stops accepting orders and drains the queued ones.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+// DefaultOrderQueueSize is the number of accepted orders queued for the matching engines of every asset
+const DefaultOrderQueueSize = 100
+
+// DefaultEngineQueueSize is the number of new orders of an asset queued for its matching engine
+const DefaultEngineQueueSize = 100
+
+var (
+	errQueueFull       = errors.New("order queue is full, try again later")              // returned when the matching engines can't keep up
+	errEngineQueueFull = errors.New("order queue of the asset is full, try again later") // returned when the engine of an asset can't keep up
+	errShuttingDown    = errors.New("exchange is shutting down")                         // returned once the exchange stopped accepting orders
+
+	ErrOrderNotFound   = errors.New("order not found")              // returned when amending an unknown order
+	ErrOrderNotWorking = errors.New("order is not working anymore") // returned when amending a filled or canceled order
+)
+
+// OrderMatchingService manages order matching executes trades for buy and sell limit orders
+type OrderMatchingService struct {
+	Store        *store.Store                         // in memory data db
+	OrderBooks   *book.OrderBooks                     // order book for each asset
+	MarketEvents *MarketEvents                        // most recent halt and resume events
+	Candles      *CandleAggregator                    // candles of every asset built from executed trades
+	Tickers      *Tickers                             // last price and 24h statistics of every asset
+	RateLimiter  *RateLimiter                         // request rate and open orders limits of every user
+	TradeStream  *TradeStream                         // executed trades of every asset for streaming clients
+	ClientOrders *ClientOrders                        // latest order of every client order id of every user
+	Instruments  *Instruments                         // instruments listed on the exchange
+	Risk         *RiskChecker                         // pre-trade risk checks of new orders
+	Metrics      *Metrics                             // counters and histograms of the exchange's internals
+	Logger       *Logger                              // structured logs of the exchange
+	Now          store.Clock                          // clock of the exchange
+	newId        store.IdGenerator                    // generates order and transfer ids
+	seq          *store.Sequence                      // exchange-wide sequence of accepted commands
+	journal      *Journal                             // records the accepted commands, nil if they aren't journaled
+	closed       bool                                 // whether new orders are still accepted
+	queues       map[store.AssetId]chan engineCommand // queue of the matching engine of every asset
+	queueMu      sync.Mutex                           // sequences the commands: numbers, journals and queues them one at a time
+	queued       int                                  // commands queued or being processed, guarded by queueMu
+	ordersQueued bool                                 // whether orders were queued for the engines, guarded by queueMu
+	dequeued     *sync.Cond                           // signaled every time an engine processed a command
+	resumptions  map[store.AssetId]resumption         // resumption auctions started by the circuit breaker, guarded by queueMu
+	engines      sync.WaitGroup                       // running matching engines
+	done         chan struct{}                        // closed once every queued command was processed after Close
+	doneOnce     sync.Once                            // closes done once
+
+	orderQueueSize  int // max number of accepted orders queued or being matched
+	engineQueueSize int // size of the queue of every matching engine
+}
+
+// NewOrderMatchingService returns a service with an empty store and order books, configured by options.
+// The matching engine of an asset is started with its first order, orders are matched as soon as they're placed.
+func NewOrderMatchingService(options ...Option) *OrderMatchingService {
+	s := &OrderMatchingService{
+		Store:        store.NewStore(),
+		OrderBooks:   book.NewOrderBooks(),
+		MarketEvents: newMarketEvents(),
+		Candles:      newCandleAggregator(),
+		Tickers:      newTickers(),
+		RateLimiter:  NewRateLimiter(defaultRateLimitConfig),
+		TradeStream:  newTradeStream(),
+		ClientOrders: newClientOrders(),
+		Instruments:  newInstruments(),
+		Risk:         newRiskChecker(RiskConfig{}),
+		Metrics:      newMetrics(),
+		Logger:       NewLogger(os.Stderr, InfoLevel),
+		Now:          time.Now,
+		newId:        store.RandomIds,
+		seq:          &store.Sequence{},
+		queues:       make(map[store.AssetId]chan engineCommand),
+		resumptions:  make(map[store.AssetId]resumption),
+		done:         make(chan struct{}),
+
+		orderQueueSize:  DefaultOrderQueueSize,
+		engineQueueSize: DefaultEngineQueueSize,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.dequeued = sync.NewCond(&s.queueMu)
+	s.Risk.now = s.Now
+
+	s.OrderBooks.AddTradeListener(s.Store.AddTrade)
+	s.OrderBooks.AddTradeListener(s.Candles.AddTrade)
+	s.OrderBooks.AddTradeListener(s.Tickers.AddTrade)
+	s.OrderBooks.AddTradeListener(s.Risk.AddTrade)
+	s.OrderBooks.AddTradeListener(s.TradeStream.AddTrade)
+	s.OrderBooks.AddTradeListener(s.Metrics.AddTrade)
+	s.OrderBooks.AddTradeListener(s.logTrade)
+
+	return s
+}
+
+// Option configures an OrderMatchingService
+type Option func(s *OrderMatchingService)
+
+// WithClock makes the service read the time from a clock instead of the system clock
+func WithClock(now store.Clock) Option {
+	return func(s *OrderMatchingService) {
+		s.Now = now
+	}
+}
+
+// WithQueueSizes sets the size of the queue of accepted orders and of the queue of every matching engine
+func WithQueueSizes(orderQueueSize, engineQueueSize int) Option {
+	return func(s *OrderMatchingService) {
+		s.orderQueueSize = orderQueueSize
+		s.engineQueueSize = engineQueueSize
+	}
+}
+
+// WithIdGenerator makes the service create order and transfer ids with an id generator instead of random ids
+func WithIdGenerator(newId store.IdGenerator) Option {
+	return func(s *OrderMatchingService) {
+		s.newId = newId
+	}
+}
+
+// InitExchange initializes the exchange with users and their assets
+func (s *OrderMatchingService) InitExchange(reqs []store.InitExchangeReq) {
+	for _, r := range reqs {
+		s.CreateUser(r)
+	}
+}
+
+// CreateUser creates a user with their cash and assets.
+// Existing users are left unchanged, it returns false if the user already exists.
+func (s *OrderMatchingService) CreateUser(req store.InitExchangeReq) (store.UserData, bool) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	created := s.createUser(req)
+	return s.Store.GetUserData(req.UserId), created
+}
+
+// createUser creates and journals a user, it returns false if the user already exists. Callers must hold queueMu.
+func (s *OrderMatchingService) createUser(req store.InitExchangeReq) bool {
+	if s.Store.HasUser(req.UserId) {
+		return false
+	}
+
+	cmd := s.sequence()
+	s.Store.CreateUser(req)
+	s.journalCommand(JournalEntry{Type: CreateUserCommand, Seq: cmd.Seq, At: cmd.At, User: req})
+	return true
+}
+
+// SuspendUser stops a user from placing new orders and cancels their open orders
+func (s *OrderMatchingService) SuspendUser(userId store.UserId) error {
+	if err := s.setUserStatus(userId, store.Suspended); err != nil {
+		return err
+	}
+
+	s.CancelUserOrders(userId, OrderFilter{}, "user was suspended")
+	return nil
+}
+
+// ResumeUser allows a suspended user to place orders again
+func (s *OrderMatchingService) ResumeUser(userId store.UserId) error {
+	return s.setUserStatus(userId, store.Active)
+}
+
+// setUserStatus sets and journals whether a user can trade
+func (s *OrderMatchingService) setUserStatus(userId store.UserId, status store.UserStatus) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if !s.Store.HasUser(userId) {
+		return fmt.Errorf("userId: %s not an actual user", userId)
+	}
+
+	cmd := s.sequence()
+	s.Store.SetUserStatus(userId, status)
+	s.journalCommand(JournalEntry{Type: UserStatusCommand, Seq: cmd.Seq, At: cmd.At, UserId: userId, Status: status})
+	return nil
+}
+
+// DeleteUser deletes a user. Only users without cash, assets and open orders can be deleted.
+func (s *OrderMatchingService) DeleteUser(userId store.UserId) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	userData := s.Store.GetUserAccount(userId)
+	if userData.UserId == "" {
+		return nil
+	}
+	if userData.Cash != 0 {
+		return fmt.Errorf("user %s still has %d cash", userId, userData.Cash)
+	}
+	for assetId, size := range userData.Assets {
+		if size != 0 {
+			return fmt.Errorf("user %s still has %d of asset %s", userId, size, assetId)
+		}
+	}
+	if open := s.Store.CountOpenOrders(userId); open > 0 {
+		return fmt.Errorf("user %s still has %d open orders", userId, open)
+	}
+
+	cmd := s.sequence()
+	s.Store.DeleteUser(userId)
+	s.journalCommand(JournalEntry{Type: DeleteUserCommand, Seq: cmd.Seq, At: cmd.At, UserId: userId})
+	return nil
+}
+
+// engineCommand is a command processed by the matching engine of an asset: an accepted order to match,
+// or a function run on the engine, e.g to cancel an order
+type engineCommand struct {
+	cmd   *store.Command
+	order store.Order              // accepted order to match, if run is nil
+	run   func(cmd *store.Command) // run on the engine instead
+	done  chan struct{}            // closed once run returned
+}
+
+// processAssetCommands processes the commands of an asset in the order they were accepted: it matches the new orders,
+// executing them if there is a match and adding them to the order book if not, and runs the other commands
+func (s *OrderMatchingService) processAssetCommands(commands <-chan engineCommand) {
+	defer s.engines.Done()
+
+	for c := range commands {
+		if c.run != nil {
+			c.run(c.cmd)
+			close(c.done)
+		} else {
+			s.matchOrder(c.order, c.cmd)
+		}
+
+		s.queueMu.Lock()
+		s.queued--
+		s.dequeued.Broadcast()
+		s.queueMu.Unlock()
+	}
+}
+
+// sequence numbers and times a new command. Callers must hold queueMu, so commands are numbered in the order
+// they're accepted.
+func (s *OrderMatchingService) sequence() *store.Command {
+	return store.NewCommand(s.seq.Next(), s.Now())
+}
+
+// saveOrder saves a new order of a command, reserving the cash or assets it needs, and returns it.
+// The order is saved as rejected if its user was suspended or doesn't have what it needs anymore.
+// Callers must hold queueMu, so orders reserve what they need in the order they're accepted.
+func (s *OrderMatchingService) saveOrder(or OrderReq, cmd *store.Command) store.Order {
+	or = withOrderDefaults(or)
+	if or.OrderId == "" {
+		or.OrderId = s.createOrderId()
+	}
+	order := createOrderFromOrderReq(or, cmd.At, cmd.Seq)
+	order.FeeReserve = s.feeReserve(or)
+	if s.Store.GetUserStatus(or.UserId) == store.Suspended {
+		s.Store.AddRejectedOrder(order, "user was suspended")
+		s.orderRejected(or, RejectSuspended, "user was suspended")
+	} else if err := s.SaveOrderToStore(order); err != nil { // save new order to db
+		s.Store.AddRejectedOrder(order, err.Error())
+		s.orderRejected(or, rejectInsufficientFunds, err.Error())
+	}
+	order, _ = s.Store.GetOrder(or.UserId, order.OrderId)
+	return order
+}
+
+// matchOrder matches a saved order of a command
+func (s *OrderMatchingService) matchOrder(order store.Order, cmd *store.Command) {
+	start := time.Now()
+	s.ExecuteOrder(order, cmd)
+	s.Metrics.matchingLatency.Observe(time.Since(start).Seconds(), string(order.AssetId))
+	s.Logger.Info("order matched", append(orderReqLogFields(orderReqFromOrder(order)), "seq", order.Seq, "duration_ms", float64(time.Since(start).Microseconds())/1000)...)
+}
+
+// feeReserve returns the cash a buy order reserves for its fees, the highest fee of its cost
+func (s *OrderMatchingService) feeReserve(or OrderReq) store.Usd {
+	if or.BuyOrSell != store.BUY {
+		return 0
+	}
+	return s.OrderBooks.Fees.MaxFee(or.UserId, store.GetTotalAssetCost(or.Limit, or.Size))
+}
+
+// GetUserOrders returns a page of up to limit orders of a user selected by the filter, in the order they were
+// created, oldest first or newest first if desc. It starts after the cursor of the previous page, or at the first
+// order if the cursor is empty, and also returns the cursor of the next page, empty if this is the last page.
+func (s *OrderMatchingService) GetUserOrders(userId store.UserId, filter OrderFilter, cursor string, limit int, desc bool) ([]store.Order, string, error) {
+	// the cursor is the position of the next order to look at in the user's orders, -1 for the first page
+	start := -1
+	if cursor != "" {
+		position, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = position
+	}
+
+	// orders are created in sequence order, their eventAt times come from the service's clock, which can go back,
+	// so every order is looked at instead of searching the time range
+	orders, next := s.Store.ScanOrders(userId, start, desc, limit, filter.matches)
+	if next < 0 {
+		return orders, "", nil
+	}
+	return orders, encodeCursor(next), nil
+}
+
+// GetUserTrades returns up to limit of the latest fills of a user's orders selected by the filter, newest first
+func (s *OrderMatchingService) GetUserTrades(userId store.UserId, filter OrderFilter, limit int) []UserTrade {
+	orders := s.Store.GetOrders(userId, func(order store.Order) bool { return order.Filled > 0 && filter.matches(order) })
+
+	var trades []UserTrade
+	for _, order := range orders {
+		for _, fill := range s.Store.GetFills(userId, order.OrderId) {
+			trades = append(trades, UserTrade{Order: order, Fill: fill})
+		}
+	}
+	sort.Slice(trades, func(i, j int) bool { return trades[i].Fill.Seq > trades[j].Fill.Seq })
+
+	if len(trades) > limit {
+		trades = trades[:limit]
+	}
+	return trades
+}
+
+// GetUserActiveOrders returns user's active orders
+func (s *OrderMatchingService) GetUserActiveOrders(userId store.UserId) []store.Order {
+	return s.Store.GetOrders(userId, func(order store.Order) bool { return order.Status == store.Working })
+}
+
+// GetUserCompleteOrders returns a user's complete orders
+func (s *OrderMatchingService) GetUserCompleteOrders(userId store.UserId) []store.Order {
+	return s.Store.GetOrders(userId, func(order store.Order) bool { return order.Status == store.Complete })
+}
+
+// order status filters of OrderFilter
+const (
+	ActiveOrders          = "active"           // working orders
+	CompleteOrders        = "complete"         // completely filled orders
+	CanceledOrders        = "canceled"         // canceled orders
+	PartiallyFilledOrders = "partially_filled" // working orders with some fills
+	RejectedOrders        = "rejected"         // orders rejected by the matching engine
+	AllOrders             = "all"              // orders of any status
+)
+
+// OrderFilter selects orders by asset, side, status and time, empty fields match every order
+type OrderFilter struct {
+	AssetId   store.AssetId
+	BuyOrSell *store.BuyOrSell
+	Status    string    // one of the order status filters
+	From      time.Time // orders created at or after
+	To        time.Time // orders created before
+}
+
+// matches returns if an order is selected by the filter
+func (f OrderFilter) matches(order store.Order) bool {
+	if f.AssetId != "" && order.AssetId != f.AssetId {
+		return false
+	}
+	if f.BuyOrSell != nil && order.BuyOrSell != *f.BuyOrSell {
+		return false
+	}
+	if !f.From.IsZero() && order.EventAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !order.EventAt.Before(f.To) {
+		return false
+	}
+
+	switch f.Status {
+	case ActiveOrders:
+		return order.Status == store.Working
+	case CompleteOrders:
+		return order.Status == store.Complete
+	case CanceledOrders:
+		return order.Status == store.Canceled
+	case PartiallyFilledOrders:
+		return order.Status == store.Working && order.Filled > 0
+	case RejectedOrders:
+		return order.Status == store.Rejected
+	}
+	return true
+}
+
+// IsValidStatusFilter returns if a status is one of the order status filters
+func IsValidStatusFilter(status string) bool {
+	switch status {
+	case "", ActiveOrders, CompleteOrders, CanceledOrders, PartiallyFilledOrders, RejectedOrders, AllOrders:
+		return true
+	}
+	return false
+}
+
+// CancelUserOrder cancels a user's working order and returns it.
+// It returns an empty order if the order doesn't exist or can't be canceled anymore.
+func (s *OrderMatchingService) CancelUserOrder(userId store.UserId, orderId store.OrderId) store.Order {
+	return s.cancelOrder(userId, orderId, "canceled by user")
+}
+
+// CancelUserOrders cancels all working orders of a user selected by the filter and returns them, oldest first
+func (s *OrderMatchingService) CancelUserOrders(userId store.UserId, filter OrderFilter, reason string) []store.Order {
+	orders := s.Store.GetOrders(userId, func(order store.Order) bool { return order.Status == store.Working && filter.matches(order) })
+
+	var canceled []store.Order
+	for _, order := range orders {
+		if order = s.cancelOrder(userId, order.OrderId, reason); order.OrderId != "" {
+			canceled = append(canceled, order)
+		}
+	}
+	return canceled
+}
+
+// CancelAssetOrders is the kill switch of an asset: it stops trading the asset, see OrderBooks.StopTrading, and cancels
+// every order in its order book and returns them. New orders are rejected until the asset is moved to a trading phase.
+func (s *OrderMatchingService) CancelAssetOrders(assetId store.AssetId) []store.Order {
+	var canceled []store.Order
+	entry := JournalEntry{Type: CancelAssetCommand, AssetId: assetId, Reason: killSwitchReason}
+	s.runCommand(assetId, entry, func(cmd *store.Command) {
+		canceled = s.applyCancelAsset(assetId, killSwitchReason, cmd)
+	})
+	return canceled
+}
+
+// killSwitchReason is the reason of the orders canceled by the kill switch of an asset
+const killSwitchReason = "canceled by the asset kill switch"
+
+// cancelOrder cancels a user's working order for a reason on the matching engine of its asset and returns it.
+// It returns an empty order if the order doesn't exist or can't be canceled anymore.
+func (s *OrderMatchingService) cancelOrder(userId store.UserId, orderId store.OrderId, reason string) store.Order {
+	order, ok := s.Store.GetOrder(userId, orderId)
+	if !ok || order.Status != store.Working {
+		return store.Order{}
+	}
+
+	var canceled store.Order
+	entry := JournalEntry{Type: CancelOrderCommand, UserId: userId, OrderId: orderId, Reason: reason}
+	s.runCommand(order.AssetId, entry, func(cmd *store.Command) {
+		canceled = s.applyCancel(userId, orderId, reason, cmd)
+	})
+	return canceled
+}
+
+// applyCancel cancels a user's working order for a reason as an event of a command and returns it.
+// It returns an empty order if the order doesn't exist or can't be canceled anymore.
+func (s *OrderMatchingService) applyCancel(userId store.UserId, orderId store.OrderId, reason string, cmd *store.Command) store.Order {
+	order, ok := s.Store.GetOrder(userId, orderId)
+	if !ok || order.Status != store.Working {
+		return store.Order{}
+	}
+
+	// remove order from order book, if it's not in the order book anymore it was filled
+	if !s.OrderBooks.DeleteOrder(order) {
+		return store.Order{}
+	}
+	s.Store.UpdateUserAssetOnOrderCancel(userId, order.AssetId, order.OrderId, reason, cmd.NextEvent()) // update order status to cancel
+	return s.Store.GetUserData(userId).Orders[orderId]
+}
+
+// applyCancelAsset stops trading an asset and cancels every order in its order book for a reason as events of a
+// command and returns them
+func (s *OrderMatchingService) applyCancelAsset(assetId store.AssetId, reason string, cmd *store.Command) []store.Order {
+	s.OrderBooks.StopTrading(assetId)
+
+	var canceled []store.Order
+	for _, order := range s.OrderBooks.GetOrders(assetId) {
+		if order = s.applyCancel(order.UserId, order.OrderId, reason, cmd); order.OrderId != "" {
+			canceled = append(canceled, order)
+		}
+	}
+	return canceled
+}
+
+// CreateTransfer makes a deposit or withdrawal for a user, see Store.AddTransfer
+func (s *OrderMatchingService) CreateTransfer(userId store.UserId, tr TransferReq) (store.Transfer, bool, error) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	// the transfer only takes the next sequence number once it's made, failed and retried transfers don't
+	cmd := store.NewCommand(s.seq.Last()+store.EventsPerCommand, s.Now())
+	transfer, created, err := s.Store.AddTransfer(createTransferFromTransferReq(store.TransferId(s.newId()), userId, tr, cmd.At, cmd.Seq))
+	if created {
+		s.seq.Set(cmd.Seq)
+		s.journalCommand(JournalEntry{Type: TransferCommand, Seq: cmd.Seq, At: cmd.At, Transfer: transfer})
+	}
+	return transfer, created, err
+}
+
+// createOrderId creates a unique order id with the service's id generator
+func (s *OrderMatchingService) createOrderId() store.OrderId {
+	return store.OrderId(s.newId())
+}
+
+// LastSeq returns the sequence number of the last accepted command, its events are numbered after it
+func (s *OrderMatchingService) LastSeq() uint64 {
+	return s.seq.Last()
+}
+
+// isOpenOrder returns if the order of an accepted order request is still open, i.e it's queued or working
+func (s *OrderMatchingService) isOpenOrder(or OrderReq) bool {
+	order, ok := s.Store.GetOrder(or.UserId, or.OrderId)
+	return !ok || order.Status == store.Working
+}
+
+// SaveOrderToStore stores an order in the store(db)
+func (s *OrderMatchingService) SaveOrderToStore(order store.Order) error { // TODO add delete order from db
+	return s.Store.AddUserOrder(order)
+}
+
+// ExecuteOrder tries to execute an order of a command if a match order is found
+// else adds the order to the order book.
+// The unfilled size of IOC and FOK orders doesn't rest in the book, it's canceled,
+// and so are the orders reaching an asset that doesn't accept orders anymore.
+func (s *OrderMatchingService) ExecuteOrder(order store.Order, cmd *store.Command) {
+	// the order was accepted before a command stopping the asset's orders reached the engine, e.g the kill switch
+	if phase := s.OrderBooks.GetPhase(order.AssetId); !book.AcceptsOrders(phase) {
+		reason := fmt.Sprintf("asset %s is %s, orders are not accepted", order.AssetId, phase)
+		s.Store.UpdateUserAssetOnOrderCancel(order.UserId, order.AssetId, order.OrderId, reason, cmd.NextEvent())
+		return
+	}
+
+	if halted := s.OrderBooks.ExecuteOrder(order, s.Store, cmd); halted {
+		s.haltAsset(order.AssetId, cmd)
+	}
+
+	if reason, ok := unfilledReasons[order.TimeInForce]; ok {
+		if order, _ = s.Store.GetOrder(order.UserId, order.OrderId); order.Status == store.Working {
+			s.Store.UpdateUserAssetOnOrderCancel(order.UserId, order.AssetId, order.OrderId, reason, cmd.NextEvent())
+		}
+	}
+}
+
+// unfilledReasons are the reasons the unfilled size of the orders that don't rest in the book is canceled for
+var unfilledReasons = map[store.TimeInForce]string{
+	store.IOC: "unfilled size of an IOC order",
+	store.FOK: "FOK order couldn't be filled in full",
+}
+
+// SetAssetPhase moves an asset's order book to a new trading phase
+func (s *OrderMatchingService) SetAssetPhase(assetId store.AssetId, phase book.TradingPhase) (book.PhaseTransition, error) {
+	return s.setAssetPhase(assetId, "", phase, time.Time{})
+}
+
+// setAssetPhase moves an asset's order book to a new trading phase on its matching engine, see applyPhase
+func (s *OrderMatchingService) setAssetPhase(assetId store.AssetId, from, phase book.TradingPhase, haltedAt time.Time) (book.PhaseTransition, error) {
+	var transition book.PhaseTransition
+	var err error
+	entry := JournalEntry{Type: SetPhaseCommand, AssetId: assetId, From: from, Phase: phase, HaltedAt: haltedAt}
+	s.runCommand(assetId, entry, func(cmd *store.Command) {
+		transition, err = s.applyPhase(assetId, from, phase, haltedAt, cmd)
+	})
+	return transition, err
+}
+
+// applyPhase moves an asset's order book to a new trading phase as a command, only from the phase from if it's set.
+// A haltedAt time is set when the circuit breaker resumes the order book after the halt at that time: the order book
+// only moves to the resumption auction if it's still in that halt, and a resume event is emitted at the end
+// of the auction.
+func (s *OrderMatchingService) applyPhase(assetId store.AssetId, from, phase book.TradingPhase, haltedAt time.Time, cmd *store.Command) (book.PhaseTransition, error) {
+	if !haltedAt.IsZero() && from == book.Halted && !s.OrderBooks.GetStatus(assetId).HaltedAt.Equal(haltedAt) {
+		return book.PhaseTransition{AssetId: assetId, From: s.OrderBooks.GetPhase(assetId), To: phase},
+			fmt.Errorf("asset %s isn't halted by the circuit breaker since %s anymore", assetId, haltedAt.Format(time.RFC3339Nano))
+	}
+
+	transition, err := s.OrderBooks.SetPhaseFrom(assetId, from, phase, s.Store, cmd)
+	if err == nil && !haltedAt.IsZero() && phase == book.Continuous {
+		status := s.OrderBooks.GetStatus(assetId)
+		s.emitMarketEvent(MarketEvent{Type: ResumeEvent, AssetId: assetId, Phase: status.Phase, RefPrice: status.RefPrice, EventAt: cmd.At}, cmd)
+	}
+	return transition, err
+}
+
+// GetIndicativeAuction returns the indicative auction price and volume of an asset
+func (s *OrderMatchingService) GetIndicativeAuction(assetId store.AssetId) (book.AuctionResult, bool) {
+	return s.OrderBooks.GetIndicativeAuction(assetId)
+}
+
+// CheckRisk checks a new order against the pre-trade risk rules, it returns nil if the order is within the user's limits
+func (s *OrderMatchingService) CheckRisk(or OrderReq) *RiskRejection {
+	lastPrice := s.Tickers.Get(or.AssetId, s.Now()).LastPrice
+	return s.Risk.Check(s.Store.GetUserAccount(or.UserId), lastPrice, or)
+}
+
+// GetTicker returns the 24h ticker statistics and best bid and ask orders of an asset
+func (s *OrderMatchingService) GetTicker(assetId store.AssetId) (TickerStats, store.Order, store.Order) {
+	stats := s.Tickers.Get(assetId, s.Now())
+	bestBid := s.OrderBooks.GetTopOrder(assetId, store.BUY)
+	bestAsk := s.OrderBooks.GetTopOrder(assetId, store.SELL)
+
+	return stats, bestBid, bestAsk
+}
+
+// orderRejected counts and logs an order rejected for a reason
+func (s *OrderMatchingService) orderRejected(or OrderReq, reason string, detail string) {
+	s.Metrics.OrderRejected(reason)
+	s.Logger.Info("order rejected", append(orderReqLogFields(or), "reason", reason, "detail", detail)...)
+}
+
+// logTrade logs a trade as a fill of its buy order and of its sell order, with the ids of the requests that placed them
+func (s *OrderMatchingService) logTrade(trade store.Trade) {
+	for _, fill := range []struct {
+		requestId string
+		orderId   store.OrderId
+		userId    store.UserId
+		side      store.BuyOrSell
+	}{
+		{trade.BuyRequestId, trade.BuyOrderId, trade.BuyerId, store.BUY},
+		{trade.SellRequestId, trade.SellOrderId, trade.SellerId, store.SELL},
+	} {
+		s.Logger.Info("order filled", "request_id", fill.requestId, "order_id", fill.orderId, "user_id", fill.userId,
+			"asset_id", trade.AssetId, "side", store.SideName(fill.side), "price", trade.Price, "size", trade.Size, "seq", trade.Seq)
+	}
+}
+
+// OrderRejection is the error of an order request rejected before it's queued for the matching engines
+type OrderRejection struct {
+	Reason string // reason of the orders rejected metric, e.g invalid or suspended
+	Err    error  // why the order was rejected, a *RiskRejection for the orders breaking a risk rule
+}
+
+func (r *OrderRejection) Error() string {
+	return r.Err.Error()
+}
+
+func (r *OrderRejection) Unwrap() error {
+	return r.Err
+}
+
+// PlaceOrder validates an order request, saves it and queues it for the matching engine of its asset, it returns the
+// queued request with its order id. A retry of an open order with the same client order id returns the request of the
+// open order and duplicate, without queueing it again. A rejected order returns an *OrderRejection.
+// Orders are checked and saved one at a time, in the order they're accepted, so the checks of an order see the cash,
+// assets and open orders of the orders accepted before.
+func (s *OrderMatchingService) PlaceOrder(or OrderReq) (placed OrderReq, duplicate bool, err error) {
+	or = withOrderDefaults(or)
+	if len(or.ClientOrderId) > maxClientOrderIdLength {
+		return or, false, s.reject(or, rejectInvalid, fmt.Errorf("client_order_id can't be longer than %d characters", maxClientOrderIdLength))
+	}
+
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	// a retry of an open order returns the order instead of creating a duplicate
+	if or.ClientOrderId != "" {
+		if original, ok := s.ClientOrders.Get(or.UserId, or.ClientOrderId); ok && s.isOpenOrder(original) {
+			return s.duplicateOrder(original, or)
+		}
+	}
+
+	if reason, err := s.checkOrderReq(s.Store.GetUserAccount(or.UserId), or); err != nil {
+		return or, false, s.reject(or, reason, err)
+	}
+	if max := s.RateLimiter.MaxOpenOrders(or.UserId); max > 0 && s.Store.CountOpenOrders(or.UserId) >= max {
+		return or, false, s.reject(or, RejectMaxOpenOrders, fmt.Errorf("user has reached the max of %d open orders", max))
+	}
+
+	or.OrderId = s.createOrderId()
+	if or.ClientOrderId != "" {
+		if original, reserved := s.ClientOrders.Reserve(or, s.isOpenOrder); !reserved {
+			return s.duplicateOrder(original, or)
+		}
+	}
+	if err := s.acceptOrder(or); err != nil {
+		if or.ClientOrderId != "" {
+			s.ClientOrders.Release(or)
+		}
+		return or, false, s.reject(or, queueRejectReason(err), err)
+	}
+	s.Metrics.ordersAccepted.Inc(string(or.AssetId))
+	s.Logger.Info("order queued", orderReqLogFields(or)...)
+	return or, false, nil
+}
+
+// checkOrderReq checks an order request against a user's data: the user must be active, the asset must accept orders
+// and list the order's limit and size, the user must have the cash or assets of the order and the order must be within
+// the user's risk limits. It returns the reason and the error of the rejection, or a nil error.
+func (s *OrderMatchingService) checkOrderReq(userData store.UserData, or OrderReq) (string, error) {
+	if userData.Status == store.Suspended {
+		return RejectSuspended, fmt.Errorf("user %s is suspended", or.UserId)
+	}
+	if phase := s.OrderBooks.GetPhase(or.AssetId); !book.AcceptsOrders(phase) {
+		return RejectPhase, fmt.Errorf("asset %s is %s, orders are not accepted", or.AssetId, phase)
+	}
+	err := validateOrderReq(userData, or, s.feeReserve(or))
+	if err == nil {
+		err = s.Instruments.ValidateOrder(or)
+	}
+	if err != nil {
+		return rejectInvalid, err
+	}
+	lastPrice := s.Tickers.Get(or.AssetId, s.Now()).LastPrice
+	if rejection := s.Risk.Check(userData, lastPrice, or); rejection != nil {
+		return strings.ToLower(string(rejection.Code)), rejection
+	}
+	return "", nil
+}
+
+// duplicateOrder returns the open order retried by an order request reusing its client order id,
+// a different order reusing the client order id is rejected
+func (s *OrderMatchingService) duplicateOrder(original OrderReq, or OrderReq) (OrderReq, bool, error) {
+	if !isSameOrderReq(original, or) {
+		return or, false, s.reject(or, RejectDuplicate, fmt.Errorf("client_order_id %s is already used by open order %s", or.ClientOrderId, original.OrderId))
+	}
+	return original, true, nil
+}
+
+// reject counts and logs an order request rejected before it's queued, and returns its rejection
+func (s *OrderMatchingService) reject(or OrderReq, reason string, err error) error {
+	s.orderRejected(or, reason, err.Error())
+	return &OrderRejection{Reason: reason, Err: err}
+}
+
+// AmendOrder amends the limit or size of a user's working order.
+// The order is canceled and replaced by a new order with the same client order id and side, so the replacement
+// loses the time priority of the order. The replacement is checked against the cash or assets the order releases,
+// and the amend is queued for the matching engine of the order's asset, before the order is canceled, so the order
+// keeps working if the replacement is rejected or the queue is full. The engine cancels the order and places the
+// replacement as one command, it returns ErrOrderNotWorking if the order was filled or canceled in the meantime.
+func (s *OrderMatchingService) AmendOrder(userId store.UserId, orderId store.OrderId, req AmendOrderReq) (OrderReq, error) {
+	if req.Limit < 0 || req.Size < 0 {
+		return OrderReq{}, errors.New("limit and size can't be negative")
+	}
+	amend, err := s.acceptAmend(userId, orderId, req)
+	if err != nil {
+		return amend.placed, err
+	}
+	<-amend.done
+	return amend.placed, amend.err
+}
+
+// amendment is an amend queued for a matching engine, its replacement and error are set once it ran
+type amendment struct {
+	placed OrderReq
+	err    error
+	done   chan struct{}
+}
+
+// amendReason is the reason of the orders canceled by an amend
+const amendReason = "amended by user"
+
+// acceptAmend checks an amend of a user's working order and queues it for the matching engine of the order's asset
+func (s *OrderMatchingService) acceptAmend(userId store.UserId, orderId store.OrderId, req AmendOrderReq) (*amendment, error) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	amend := &amendment{done: make(chan struct{})}
+	original, ok := s.Store.GetOrder(userId, orderId)
+	if !ok {
+		return amend, ErrOrderNotFound
+	}
+	if original.Status != store.Working {
+		return amend, ErrOrderNotWorking
+	}
+	replacement := withOrderDefaults(amendOrderReqToOrderReq(original, req))
+	if reason, err := s.checkOrderReq(withOrderCanceled(s.Store.GetUserAccount(userId), original), replacement); err != nil {
+		amend.placed = replacement
+		return amend, s.reject(replacement, reason, err)
+	}
+	if err := s.queueError(original.AssetId); err != nil {
+		amend.placed = replacement
+		return amend, s.reject(replacement, queueRejectReason(err), err)
+	}
+
+	replacementId := s.createOrderId()
+	entry := JournalEntry{Type: AmendOrderCommand, UserId: userId, OrderId: orderId, Reason: amendReason}
+	run := func(cmd *store.Command) {
+		amend.placed, amend.err = s.applyAmend(userId, orderId, req, replacementId, cmd)
+	}
+	s.engineQueue(original.AssetId) <- engineCommand{cmd: s.journalRun(entry), run: run, done: amend.done}
+	s.queued++
+	return amend, nil
+}
+
+// applyAmend cancels a user's working order and replaces it by the order amended by req, with the id replacementId,
+// as a command run by the matching engine of the order's asset. It returns the replacement's request, and
+// ErrOrderNotWorking if the order was filled or canceled before, or an *OrderRejection if the replacement was rejected.
+func (s *OrderMatchingService) applyAmend(userId store.UserId, orderId store.OrderId, req AmendOrderReq, replacementId store.OrderId, cmd *store.Command) (OrderReq, error) {
+	// the replacement is saved like a new order, while no other order is accepted
+	s.queueMu.Lock()
+	order := s.applyCancel(userId, orderId, amendReason, cmd)
+	if order.OrderId == "" {
+		s.queueMu.Unlock()
+		return OrderReq{}, ErrOrderNotWorking
+	}
+	or := withOrderDefaults(amendOrderReqToOrderReq(order, req))
+	or.OrderId = replacementId
+	if or.ClientOrderId != "" {
+		s.ClientOrders.Reserve(or, s.isOpenOrder) // the canceled order held the client order id
+	}
+	suspended := s.Store.GetUserStatus(userId) == store.Suspended
+	replacement := s.saveOrder(or, cmd)
+	s.journal.setOrder(cmd.Seq, replacement)
+	s.queueMu.Unlock()
+
+	if replacement.Status == store.Rejected {
+		reason := rejectInsufficientFunds
+		if suspended {
+			reason = RejectSuspended
+		}
+		return or, &OrderRejection{Reason: reason, Err: errors.New(replacement.Reason)}
+	}
+	s.Metrics.ordersAccepted.Inc(string(or.AssetId))
+	s.Logger.Info("order amended", append(orderReqLogFields(or), "amended_order_id", orderId)...)
+	s.matchOrder(replacement, cmd)
+	return or, nil
+}
+
+// SubmitOrder saves an order without checking it and queues it for the matching engine of its asset, see acceptOrder
+func (s *OrderMatchingService) SubmitOrder(or OrderReq) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	return s.acceptOrder(or)
+}
+
+// acceptOrder numbers, saves and journals an order and queues it for the matching engine of its asset without
+// blocking, the engine is started with the first command of the asset. Every asset has its own queue and goroutine,
+// so the commands of an asset are processed in the order they were accepted while a busy asset never holds up other
+// assets. Orders rejected when they're saved aren't queued. It returns errQueueFull if too many commands are queued,
+// errEngineQueueFull if the queue of the asset is full, and errShuttingDown once the service is closed.
+// Callers must hold queueMu.
+func (s *OrderMatchingService) acceptOrder(or OrderReq) error {
+	if err := s.queueError(or.AssetId); err != nil {
+		return err
+	}
+	queue := s.engineQueue(or.AssetId)
+
+	cmd := s.sequence()
+	order := s.saveOrder(or, cmd)
+	if order.OrderId != "" {
+		s.journalCommand(JournalEntry{Type: NewOrderCommand, Seq: cmd.Seq, At: cmd.At, Order: order})
+	}
+	if order.Status == store.Working {
+		queue <- engineCommand{cmd: cmd, order: order}
+		s.queued++
+		s.ordersQueued = true
+	}
+	return nil
+}
+
+// queueError returns why a command can't be queued for the matching engine of an asset, or nil if it can.
+// Callers must hold queueMu.
+func (s *OrderMatchingService) queueError(assetId store.AssetId) error {
+	if s.closed {
+		return errShuttingDown
+	}
+	if s.queued >= s.orderQueueSize {
+		return errQueueFull
+	}
+	if queue := s.engineQueue(assetId); len(queue) == cap(queue) {
+		return errEngineQueueFull
+	}
+	return nil
+}
+
+// queueRejectReason returns the reason of the orders rejected metric of a queueError
+func queueRejectReason(err error) string {
+	if errors.Is(err, errQueueFull) || errors.Is(err, errEngineQueueFull) {
+		return RejectQueueFull
+	}
+	return RejectShuttingDown
+}
+
+// engineQueue returns the queue of the matching engine of an asset, starting the engine if it isn't running yet.
+// Callers must hold queueMu.
+func (s *OrderMatchingService) engineQueue(assetId store.AssetId) chan engineCommand {
+	queue, ok := s.queues[assetId]
+	if !ok {
+		queue = make(chan engineCommand, s.engineQueueSize)
+		s.queues[assetId] = queue
+		s.engines.Add(1)
+		go s.processAssetCommands(queue)
+	}
+	return queue
+}
+
+// runCommand numbers and journals a command and runs it on the matching engine of an asset, after the commands
+// of the asset accepted before it. It waits until the command ran, and for room in the queue of the asset if it's
+// full. Once the service is closed, the command runs from the caller's goroutine after the engines stopped.
+func (s *OrderMatchingService) runCommand(assetId store.AssetId, entry JournalEntry, run func(cmd *store.Command)) {
+	s.queueMu.Lock()
+	for !s.closed && len(s.queues[assetId]) == s.engineQueueSize {
+		s.dequeued.Wait()
+	}
+
+	if s.closed {
+		s.queueMu.Unlock()
+		<-s.done
+		s.queueMu.Lock()
+		defer s.queueMu.Unlock()
+
+		run(s.journalRun(entry))
+		return
+	}
+
+	done := make(chan struct{})
+	s.engineQueue(assetId) <- engineCommand{cmd: s.journalRun(entry), run: run, done: done}
+	s.queued++
+	s.queueMu.Unlock()
+	<-done
+}
+
+// journalRun numbers and journals a command run on a matching engine and returns it. Callers must hold queueMu.
+func (s *OrderMatchingService) journalRun(entry JournalEntry) *store.Command {
+	cmd := s.sequence()
+	entry.Seq, entry.At = cmd.Seq, cmd.At
+	s.journalCommand(entry)
+	return cmd
+}
+
+// Flush waits until every queued command was processed, including the commands queued while it waits.
+// It makes the orders placed so far visible in the store, e.g to read them synchronously in tests.
+func (s *OrderMatchingService) Flush() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	for s.queued > 0 {
+		s.dequeued.Wait()
+	}
+}
+
+// queuedOrders returns the number of commands queued or being processed
+func (s *OrderMatchingService) queuedOrders() int {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	return s.queued
+}
+
+// Close stops accepting new orders and closes the queues, the queued commands are still processed
+func (s *OrderMatchingService) Close() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		for _, queue := range s.queues {
+			close(queue)
+		}
+		go func() {
+			s.engines.Wait()
+			s.doneOnce.Do(func() { close(s.done) })
+		}()
+		s.dequeued.Broadcast() // commands waiting for room in a queue run once the engines stopped
+	}
+}
+
+// QueueSizes returns the max number of queued orders and the size of the queue of every matching engine
+func (s *OrderMatchingService) QueueSizes() (orderQueueSize int, engineQueueSize int) {
+	return s.orderQueueSize, s.engineQueueSize
+}
+
+// Shutdown stops accepting new orders and waits until every queued command was processed or ctx is done
+func (s *OrderMatchingService) Shutdown(ctx context.Context) error {
+	s.Close()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,1005 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+func TestOrderMatchingService_InitExchange(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	req := store.InitExchangeReq{
+		UserId: userId1,
+		Assets: []store.Asset{{AssetId: assetId1, Size: 10}, {AssetId: assetId2, Size: 15}},
+		Cash:   1000,
+	}
+
+	s.InitExchange([]store.InitExchangeReq{req})
+
+	actual := s.Store.GetUserData(req.UserId)
+	assert.Equal(t, userId1, actual.UserId)
+	assert.Equal(t, store.Usd(1000), actual.Cash)
+	assert.Equal(t, 10, actual.Assets[assetId1])
+	assert.Equal(t, 15, actual.Assets[assetId2])
+	assert.Empty(t, actual.Orders)
+}
+
+func TestOrderMatchingService_SaveOrderToStore(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	orderReq := OrderReq{
+		UserId:    userId1,
+		Limit:     100,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	order := createOrderFromOrderReq(orderReq, time.Now(), 1)
+
+	s.SaveOrderToStore(order)
+
+	actual := s.Store.GetUserData(userId1).Orders[order.OrderId]
+	assert.Equal(t, order, actual)
+}
+
+func TestOrderMatchingService_ProcessOrderReqs(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	buyOrderReq1 := OrderReq{
+		UserId:    userId1,
+		Limit:     101,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	buyOrderReq2 := OrderReq{
+		UserId:    userId1,
+		Limit:     100,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	buyOrderReq3 := OrderReq{
+		UserId:    userId1,
+		Limit:     100,
+		AssetId:   assetId2, // different asset to buy
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	s.SubmitOrder(buyOrderReq1)
+	s.SubmitOrder(buyOrderReq2)
+	s.SubmitOrder(buyOrderReq3)
+
+	s.Flush()
+
+	// assert state of Store is as expected
+	userData1 := s.Store.GetUserData(userId1)
+
+	assert.Equal(t, 3, len(userData1.Orders))
+	assert.Equal(t, store.Usd(6990), userData1.Cash) // assert available cash is updated
+	assert.Equal(t, 100, userData1.Assets[assetId1])
+	assert.Equal(t, 100, userData1.Assets[assetId2])
+
+	// assert state of order book
+	assert.Equal(t, 2, len(s.OrderBooks.GetAssetIds())) // assert there are 2 order books, one each for assertId1, assertId2
+	buyOrder1 := s.OrderBooks.GetTopOrder(assetId1, store.BUY)
+	buyOrder2 := s.OrderBooks.GetOrders(assetId1)[1]
+	assert.Equal(t, store.Usd(101), buyOrder1.Limit)
+	assert.Equal(t, 10, buyOrder1.Size)
+	assert.Equal(t, store.Usd(100), buyOrder2.Limit)
+	assert.Equal(t, 10, buyOrder2.Size)
+	buyOrder3 := s.OrderBooks.GetTopOrder(assetId2, store.BUY)
+	assert.Equal(t, store.Usd(100), buyOrder3.Limit)
+	assert.Equal(t, 10, buyOrder3.Size)
+
+	// create a sell order, assert it executed on a buy order
+	sellOrderReq1 := OrderReq{
+		UserId:    userId2,
+		Limit:     100,
+		AssetId:   assetId1,
+		Size:      15,
+		BuyOrSell: store.SELL,
+	}
+
+	sellOrderReq2 := OrderReq{
+		UserId:    userId2,
+		Limit:     102,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.SELL,
+	}
+
+	sellOrderReq3 := OrderReq{
+		UserId:    userId2,
+		Limit:     100,
+		AssetId:   assetId2,
+		Size:      10,
+		BuyOrSell: store.SELL,
+	}
+
+	s.SubmitOrder(sellOrderReq1)
+	s.SubmitOrder(sellOrderReq2)
+	s.SubmitOrder(sellOrderReq3)
+
+	s.Flush()
+
+	// assert state of Store is as expected
+	userData1 = s.Store.GetUserData(userId1)
+
+	assert.Equal(t, store.Complete, userData1.Orders[buyOrder1.OrderId].Status) // assert buy order 1 was completely executed
+	assert.Equal(t, store.Working, userData1.Orders[buyOrder2.OrderId].Status)  // assert buy order 3 was partially executed and still in working status
+	assert.Equal(t, store.Complete, userData1.Orders[buyOrder3.OrderId].Status) // assert buy order 3 was completely executed
+	assert.Equal(t, store.Usd(7000), userData1.Cash)                            // the buy order at 101 filled at 100 gets 10 back
+	assert.Equal(t, 115, userData1.Assets[assetId1])                            // bought 15 assets of asset1
+	assert.Equal(t, 110, userData1.Assets[assetId2])                            // bought 10 assets of asset1
+
+	// user2
+	userData2 := s.Store.GetUserData(userId2)
+	assert.Equal(t, store.Usd(12500), userData2.Cash)
+	assert.Equal(t, 75, userData2.Assets[assetId1]) // - 15 assets in flight or sold
+	assert.Equal(t, 90, userData2.Assets[assetId2])
+	assert.Equal(t, 3, len(userData2.Orders))
+	sellOrder1 := s.OrderBooks.GetTopOrder(assetId1, store.SELL)
+	assert.Equal(t, store.Working, userData2.Orders[sellOrder1.OrderId].Status) // assert buy order 1 was completely executed
+
+	// assert state of order book
+	assert.Equal(t, 1, countBookOrders(s, assetId1, store.BUY)) // assert only 1 buy order left for asset1
+	topBuyOrderAsset1 := s.OrderBooks.GetTopOrder(assetId1, store.BUY)
+	assert.Equal(t, store.Usd(100), topBuyOrderAsset1.Limit)
+	assert.Equal(t, 5, topBuyOrderAsset1.Size)
+	assert.Equal(t, 0, countBookOrders(s, assetId2, store.BUY)) // no buy order left for asset2 order book
+
+	assert.Equal(t, 1, countBookOrders(s, assetId1, store.BUY)) // assert only 1 sell order left for asset1
+	topSellOrderAsset1 := s.OrderBooks.GetTopOrder(assetId1, store.SELL)
+	assert.Equal(t, store.Usd(102), topSellOrderAsset1.Limit)
+	assert.Equal(t, 10, topSellOrderAsset1.Size)
+
+	// perform a buy order to execute a sell order in the order book
+	buyOrderReq4 := OrderReq{
+		UserId:    userId1,
+		Limit:     103,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	s.SubmitOrder(buyOrderReq4)
+
+	s.Flush()
+
+	assert.Equal(t, 1, countBookOrders(s, assetId1, store.BUY))
+	assert.Equal(t, 0, countBookOrders(s, assetId1, store.SELL))
+}
+
+func TestOrderMatchingService_GetUserActiveOrders(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	assert.Empty(t, s.GetUserActiveOrders(userId1)) // user has no orders
+	assert.Empty(t, s.GetUserActiveOrders(userId2)) // user not present in db
+
+	buyOrderReq1 := OrderReq{
+		UserId:    userId1,
+		Limit:     101,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	sellOrderReq2 := OrderReq{
+		UserId:    userId1,
+		Limit:     120,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.SELL,
+	}
+
+	s.SubmitOrder(buyOrderReq1)
+	s.SubmitOrder(sellOrderReq2)
+
+	s.Flush()
+
+	activeOrders := s.GetUserActiveOrders(userId1)
+	assert.Equal(t, 2, len(activeOrders))
+	for _, order := range activeOrders {
+		assert.Equal(t, store.Working, order.Status)
+	}
+}
+
+func TestOrderMatchingService_GetUserCompleteOrder(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	assert.Empty(t, s.GetUserCompleteOrders(userId1)) // user has no orders
+	assert.Empty(t, s.GetUserCompleteOrders(userId1)) // user not present in db
+
+	buyOrderReq1 := OrderReq{
+		UserId:    userId1,
+		Limit:     101,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	buyOrderReq2 := OrderReq{
+		UserId:    userId1,
+		Limit:     99,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	sellOrderReq2 := OrderReq{
+		UserId:    userId2,
+		Limit:     100,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.SELL,
+	}
+
+	s.SubmitOrder(buyOrderReq1)
+	s.SubmitOrder(buyOrderReq2)
+	s.SubmitOrder(sellOrderReq2)
+
+	s.Flush()
+
+	completeOrders := s.GetUserCompleteOrders(userId1)
+	assert.Equal(t, 1, len(completeOrders))
+	assert.Equal(t, store.Usd(101), completeOrders[0].Limit)
+	assert.Equal(t, store.BUY, completeOrders[0].BuyOrSell)
+
+	completeOrders2 := s.GetUserCompleteOrders(userId2)
+	assert.Equal(t, 1, len(completeOrders2))
+	assert.Equal(t, store.Usd(100), completeOrders2[0].Limit)
+	assert.Equal(t, store.SELL, completeOrders2[0].BuyOrSell)
+}
+
+func TestOrderMatchingService_CancelUserOrder(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	assert.Empty(t, s.CancelUserOrder(userId1, "wrong")) // user has no orders
+	assert.Empty(t, s.CancelUserOrder(userId2, "wrong")) // user not present in db
+
+	buyOrderReq := OrderReq{
+		UserId:    userId1,
+		Limit:     101,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.BUY,
+	}
+
+	sellOrderReq := OrderReq{
+		UserId:    userId1,
+		Limit:     120,
+		AssetId:   assetId1,
+		Size:      10,
+		BuyOrSell: store.SELL,
+	}
+
+	s.SubmitOrder(buyOrderReq)
+	s.SubmitOrder(sellOrderReq)
+
+	s.Flush()
+
+	// assert state of order book
+	assert.Equal(t, 1, countBookOrders(s, assetId1, store.BUY))
+	assert.Equal(t, 1, countBookOrders(s, assetId1, store.SELL))
+
+	activeOrders := s.GetUserActiveOrders(userId1)
+	buyOrder := getOrder(activeOrders, store.BUY)
+	sellOrder := getOrder(activeOrders, store.SELL)
+
+	s.CancelUserOrder(userId1, buyOrder.OrderId)
+
+	// assert store data
+	activeOrders = s.GetUserActiveOrders(userId1)
+	assert.Equal(t, 1, len(activeOrders))
+	assert.Equal(t, sellOrder.OrderId, activeOrders[0].OrderId)
+	assert.Equal(t, store.Usd(10000), s.Store.GetUserData(buyOrder.UserId).Cash)
+	assert.Equal(t, store.Canceled, s.Store.GetUserData(buyOrder.UserId).Orders[buyOrder.OrderId].Status)
+
+	// assert state of order book
+	assert.Equal(t, 0, countBookOrders(s, assetId1, store.BUY))
+	assert.Equal(t, 1, countBookOrders(s, assetId1, store.SELL))
+}
+
+func TestOrderMatchingService_CancelUserOrder_PartiallyFilled(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.SELL})
+	s.Flush()
+
+	buyOrder := s.GetUserActiveOrders(userId1)[0]
+	sellOrder := s.GetUserCompleteOrders(userId2)[0]
+
+	// only the unfilled part of the order is refunded
+	canceled := s.CancelUserOrder(userId1, buyOrder.OrderId)
+	assert.Equal(t, store.Canceled, canceled.Status)
+	assert.Equal(t, 4, canceled.Filled)
+	assert.Equal(t, store.Usd(9600), s.Store.GetUserData(userId1).Cash)
+	assert.Equal(t, 104, s.Store.GetUserData(userId1).Assets[assetId1])
+
+	// canceled and complete orders can't be canceled
+	assert.Empty(t, s.CancelUserOrder(userId1, buyOrder.OrderId))
+	assert.Empty(t, s.CancelUserOrder(userId2, sellOrder.OrderId))
+	assert.Equal(t, store.Usd(9600), s.Store.GetUserData(userId1).Cash)
+	assert.Equal(t, store.Complete, s.Store.GetUserData(userId2).Orders[sellOrder.OrderId].Status)
+}
+
+func TestOrderMatchingService_TimeInForce(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	// the unfilled size of an IOC order is canceled instead of resting in the book
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.SELL})
+	s.Flush()
+	placed, _, err := s.PlaceOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY, TimeInForce: store.IOC})
+	assert.NoError(t, err)
+	s.Flush()
+	order := s.Store.GetUserData(userId1).Orders[placed.OrderId]
+	assert.Equal(t, store.Canceled, order.Status)
+	assert.Equal(t, 4, order.Filled)
+	assert.Equal(t, "unfilled size of an IOC order", order.Reason)
+	assert.Equal(t, store.Usd(9600), s.Store.GetUserData(userId1).Cash)
+	assert.Empty(t, s.OrderBooks.GetOrders(assetId1))
+
+	// a FOK order is only matched if it can be filled in full
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.SELL})
+	s.Flush()
+	placed, _, err = s.PlaceOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 5, BuyOrSell: store.BUY, TimeInForce: store.FOK})
+	assert.NoError(t, err)
+	s.Flush()
+	order = s.Store.GetUserData(userId1).Orders[placed.OrderId]
+	assert.Equal(t, store.Canceled, order.Status)
+	assert.Equal(t, 0, order.Filled)
+	assert.Equal(t, store.Usd(9600), s.Store.GetUserData(userId1).Cash)
+	assert.Equal(t, 1, len(s.OrderBooks.GetOrders(assetId1)))
+
+	placed, _, err = s.PlaceOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.BUY, TimeInForce: store.FOK})
+	assert.NoError(t, err)
+	s.Flush()
+	order = s.Store.GetUserData(userId1).Orders[placed.OrderId]
+	assert.Equal(t, store.Complete, order.Status)
+	assert.Equal(t, store.Limit, order.OrderType)
+	assert.Empty(t, s.OrderBooks.GetOrders(assetId1))
+
+	// orders built without unmarshalling are validated too
+	_, _, err = s.PlaceOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 1, BuyOrSell: 2})
+	assert.Error(t, err)
+	_, _, err = s.PlaceOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY, TimeInForce: "DAY"})
+	assert.Error(t, err)
+}
+
+func TestOrderMatchingService_CancelUserOrders(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 90, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 110, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 90, AssetId: assetId2, Size: 10, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 110, AssetId: assetId2, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 120, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.Flush()
+
+	sell := store.SELL
+	canceled := s.CancelUserOrders(userId1, OrderFilter{AssetId: assetId1, BuyOrSell: &sell}, "canceled by user")
+	assert.Equal(t, 1, len(canceled))
+	assert.Equal(t, store.Usd(110), canceled[0].Limit)
+	assert.Equal(t, 3, s.Store.CountOpenOrders(userId1))
+
+	canceled = s.CancelUserOrders(userId1, OrderFilter{AssetId: assetId2}, "canceled by user")
+	assert.Equal(t, 2, len(canceled))
+	assert.Equal(t, store.BUY, canceled[0].BuyOrSell)
+	assert.Equal(t, store.SELL, canceled[1].BuyOrSell)
+	assert.Equal(t, 1, s.Store.CountOpenOrders(userId1))
+
+	// kill switch cancels every order in the asset
+	canceled = s.CancelAssetOrders(assetId1)
+	assert.Equal(t, 2, len(canceled))
+	assert.Equal(t, 0, s.Store.CountOpenOrders(userId1))
+	assert.Equal(t, 0, s.Store.CountOpenOrders(userId2))
+	assert.Empty(t, s.OrderBooks.GetOrders(assetId1))
+	assert.Equal(t, store.Usd(10000), s.Store.GetUserData(userId1).Cash)
+	assert.Equal(t, 100, s.Store.GetUserData(userId2).Assets[assetId1])
+
+	// the asset is halted, orders placed after the kill are rejected until it's resumed
+	assert.Equal(t, book.Halted, s.OrderBooks.GetPhase(assetId1))
+	_, _, err := s.PlaceOrder(OrderReq{UserId: userId1, Limit: 90, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	var rejection *OrderRejection
+	assert.True(t, errors.As(err, &rejection))
+	assert.Equal(t, RejectPhase, rejection.Reason)
+	assert.NoError(t, s.SubmitOrder(OrderReq{OrderId: "late", UserId: userId1, Limit: 90, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY}))
+	s.Flush()
+	order, _ := s.Store.GetOrder(userId1, "late")
+	assert.Equal(t, store.Canceled, order.Status) // accepted without the checks, canceled by the engine
+	assert.Empty(t, s.OrderBooks.GetOrders(assetId1))
+	assert.Equal(t, store.Usd(10000), s.Store.GetUserData(userId1).Cash)
+
+	_, err = s.SetAssetPhase(assetId1, book.Auction)
+	assert.NoError(t, err)
+	_, _, err = s.PlaceOrder(OrderReq{UserId: userId1, Limit: 90, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	assert.NoError(t, err)
+}
+
+func TestOrderMatchingService_GetUserOrders(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	start := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	statuses := []store.OrderStatus{store.Working, store.Complete, store.Canceled, store.Working, store.Working, store.Complete, store.Canceled, store.Working, store.Working, store.Complete}
+	for i, status := range statuses {
+		order := store.Order{
+			OrderId:   store.OrderId(fmt.Sprintf("o%d", i)),
+			UserId:    userId1,
+			Limit:     1,
+			AssetId:   assetId1,
+			Size:      2,
+			BuyOrSell: store.BuyOrSell(i % 2),
+			EventAt:   start.Add(time.Duration(i) * time.Minute),
+			Status:    status,
+			Filled:    i % 3,
+		}
+		if i >= 8 {
+			order.AssetId = assetId2
+		}
+		s.Store.AddUserOrder(order)
+	}
+
+	orderIds := func(orders []store.Order) []store.OrderId {
+		ids := []store.OrderId{}
+		for _, o := range orders {
+			ids = append(ids, o.OrderId)
+		}
+		return ids
+	}
+
+	// pages of 3 orders, oldest first
+	orders, cursor, err := s.GetUserOrders(userId1, OrderFilter{}, "", 3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []store.OrderId{"o0", "o1", "o2"}, orderIds(orders))
+	orders, cursor, err = s.GetUserOrders(userId1, OrderFilter{}, cursor, 3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []store.OrderId{"o3", "o4", "o5"}, orderIds(orders))
+	orders, cursor, err = s.GetUserOrders(userId1, OrderFilter{}, cursor, 3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []store.OrderId{"o6", "o7", "o8"}, orderIds(orders))
+	orders, cursor, err = s.GetUserOrders(userId1, OrderFilter{}, cursor, 3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []store.OrderId{"o9"}, orderIds(orders))
+	assert.Empty(t, cursor)
+
+	// newest first
+	orders, cursor, _ = s.GetUserOrders(userId1, OrderFilter{}, "", 4, true)
+	assert.Equal(t, []store.OrderId{"o9", "o8", "o7", "o6"}, orderIds(orders))
+	orders, _, _ = s.GetUserOrders(userId1, OrderFilter{}, cursor, 4, true)
+	assert.Equal(t, []store.OrderId{"o5", "o4", "o3", "o2"}, orderIds(orders))
+
+	sell := store.SELL
+	tests := []struct {
+		name     string
+		filter   OrderFilter
+		expected []store.OrderId
+	}{
+		{"active", OrderFilter{Status: ActiveOrders}, []store.OrderId{"o0", "o3", "o4", "o7", "o8"}},
+		{"complete", OrderFilter{Status: CompleteOrders}, []store.OrderId{"o1", "o5", "o9"}},
+		{"canceled", OrderFilter{Status: CanceledOrders}, []store.OrderId{"o2", "o6"}},
+		{"partially filled", OrderFilter{Status: PartiallyFilledOrders}, []store.OrderId{"o4", "o7", "o8"}},
+		{"asset", OrderFilter{AssetId: assetId2}, []store.OrderId{"o8", "o9"}},
+		{"side", OrderFilter{BuyOrSell: &sell, Status: AllOrders}, []store.OrderId{"o1", "o3", "o5", "o7", "o9"}},
+		{"time range", OrderFilter{From: start.Add(2 * time.Minute), To: start.Add(5 * time.Minute)}, []store.OrderId{"o2", "o3", "o4"}},
+		{"active in time range", OrderFilter{Status: ActiveOrders, From: start.Add(90 * time.Second), To: start.Add(7 * time.Minute)}, []store.OrderId{"o3", "o4"}},
+	}
+	for _, test := range tests {
+		orders, cursor, err := s.GetUserOrders(userId1, test.filter, "", 100, false)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, orderIds(orders), test.name)
+		assert.Empty(t, cursor, test.name)
+	}
+
+	// pages of filtered orders
+	orders, cursor, _ = s.GetUserOrders(userId1, OrderFilter{Status: ActiveOrders}, "", 2, false)
+	assert.Equal(t, []store.OrderId{"o0", "o3"}, orderIds(orders))
+	orders, _, _ = s.GetUserOrders(userId1, OrderFilter{Status: ActiveOrders}, cursor, 2, false)
+	assert.Equal(t, []store.OrderId{"o4", "o7"}, orderIds(orders))
+
+	_, _, err = s.GetUserOrders(userId1, OrderFilter{}, "not a cursor", 2, false)
+	assert.Error(t, err)
+
+	orders, cursor, err = s.GetUserOrders("unknown", OrderFilter{}, "", 2, false)
+	assert.NoError(t, err)
+	assert.Empty(t, orders)
+	assert.Empty(t, cursor)
+}
+
+func TestOrderMatchingService_CreateUser(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	req := store.InitExchangeReq{UserId: userId1, Assets: []store.Asset{{AssetId: assetId1, Size: 10}}, Cash: 1000}
+	userData, created := s.CreateUser(req)
+	assert.True(t, created)
+	assert.Equal(t, store.Active, userData.Status)
+	assert.Equal(t, store.Usd(1000), userData.Cash)
+
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 5, BuyOrSell: store.SELL})
+	s.Flush()
+
+	// creating the user again doesn't wipe their balances and orders
+	userData, created = s.CreateUser(store.InitExchangeReq{UserId: userId1, Cash: 50})
+	assert.False(t, created)
+	assert.Equal(t, store.Usd(1000), userData.Cash)
+	assert.Equal(t, 5, userData.Assets[assetId1])
+	assert.Equal(t, 1, len(userData.Orders))
+	assert.Equal(t, []store.UserId{userId1}, s.Store.GetUserIds())
+}
+
+func TestOrderMatchingService_SuspendUser(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 120, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.Flush()
+
+	assert.NoError(t, s.SuspendUser(userId1))
+	assert.Equal(t, store.Suspended, s.Store.GetUserData(userId1).Status)
+	assert.Equal(t, 0, s.Store.CountOpenOrders(userId1))
+	assert.Equal(t, store.Usd(10000), s.Store.GetUserData(userId1).Cash)
+	assert.Equal(t, 100, s.Store.GetUserData(userId1).Assets[assetId1])
+	assert.Equal(t, 0, countBookOrders(s, assetId1, store.BUY))
+	assert.Equal(t, 0, countBookOrders(s, assetId1, store.SELL))
+
+	assert.NoError(t, s.ResumeUser(userId1))
+	assert.Equal(t, store.Active, s.Store.GetUserData(userId1).Status)
+
+	// orders accepted before the user was suspended are rejected by the matching engine
+	s.Store.SetUserStatus(userId1, store.Suspended)
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.Flush()
+	orders, _, _ := s.GetUserOrders(userId1, OrderFilter{Status: RejectedOrders}, "", 10, false)
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, "user was suspended", orders[0].Reason)
+	assert.Equal(t, store.Usd(10000), s.Store.GetUserData(userId1).Cash)
+
+	assert.Error(t, s.SuspendUser("unknown"))
+	assert.Error(t, s.ResumeUser("unknown"))
+}
+
+func TestOrderMatchingService_DeleteUser(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	s.InitExchange([]store.InitExchangeReq{
+		{UserId: userId1, Cash: 100},
+		{UserId: userId2, Assets: []store.Asset{{AssetId: assetId1, Size: 1}}},
+		{UserId: "userId3", Assets: []store.Asset{{AssetId: assetId1, Size: 0}}},
+	})
+
+	assert.Error(t, s.DeleteUser(userId1))
+	assert.Error(t, s.DeleteUser(userId2))
+	assert.NoError(t, s.DeleteUser("userId3"))
+	assert.Equal(t, []store.UserId{userId1, userId2}, s.Store.GetUserIds())
+}
+
+func TestOrderMatchingService_ProcessOrderReqs_Assets(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	// both orders passed validation, but the user only has the cash for one of them
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 60, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId2, Size: 60, BuyOrSell: store.BUY})
+	for i := 0; i < 10; i++ {
+		s.SubmitOrder(OrderReq{UserId: userId2, Limit: 200, AssetId: store.AssetId(fmt.Sprintf("ASSET%d", i)), Size: 0, BuyOrSell: store.SELL})
+	}
+	s.Flush()
+
+	userData := s.Store.GetUserData(userId1)
+	assert.Equal(t, store.Usd(4000), userData.Cash)
+	statuses := map[store.OrderStatus]int{}
+	for _, order := range userData.Orders {
+		statuses[order.Status]++
+	}
+	assert.Equal(t, map[store.OrderStatus]int{store.Working: 1, store.Rejected: 1}, statuses)
+	assert.Equal(t, 10, len(s.Store.GetUserData(userId2).Orders))
+}
+
+func TestOrderMatchingService_SubmitOrder(t *testing.T) {
+	s := NewOrderMatchingService()
+	setupTestUsers(s)
+
+	// block the matching engine of the asset until the queues are full
+	orderBook := s.OrderBooks.OrderBook(assetId1)
+	orderBook.Lock()
+	accepted := 0
+	var err error
+	for err == nil {
+		if err = s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY}); err == nil {
+			accepted++
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+	assert.Equal(t, errQueueFull, err)
+	assert.True(t, accepted >= DefaultOrderQueueSize)
+	orderBook.Unlock()
+
+	// queued orders are drained on shutdown, new ones are rejected
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, s.Shutdown(ctx))
+	assert.Equal(t, accepted, len(s.Store.GetUserData(userId1).Orders))
+	assert.Equal(t, errShuttingDown, s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY}))
+	s.Close() // closing twice is a no-op
+	assert.NoError(t, s.Shutdown(ctx))
+}
+
+func TestOrderMatchingService_SubmitOrder_Assets(t *testing.T) {
+	s := NewOrderMatchingService(WithQueueSizes(10, 2))
+	defer s.Close()
+	setupTestUsers(s)
+
+	// block the matching engine of an asset until its queue is full
+	orderBook := s.OrderBooks.OrderBook(assetId1)
+	orderBook.Lock()
+	defer orderBook.Unlock()
+	var err error
+	for err == nil {
+		err = s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY})
+		time.Sleep(10 * time.Microsecond)
+	}
+	assert.Equal(t, errEngineQueueFull, err)
+
+	// the orders of another asset are still queued and matched
+	assert.NoError(t, s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId2, Size: 10, BuyOrSell: store.SELL}))
+	assert.NoError(t, s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId2, Size: 10, BuyOrSell: store.BUY}))
+	assert.Eventually(t, func() bool {
+		return s.Store.GetUserData(userId1).Assets[assetId2] == 110
+	}, time.Second, time.Millisecond)
+}
+
+func TestOrderMatchingService_SubmitOrder_EngineQueueFull(t *testing.T) {
+	s := NewOrderMatchingService(WithQueueSizes(100, 2))
+	defer s.Close()
+	setupTestUsers(s)
+
+	// the queue of the asset's engine fills up long before the queue of every order
+	orderBook := s.OrderBooks.OrderBook(assetId1)
+	orderBook.Lock()
+	accepted := 0
+	var err error
+	for err == nil {
+		if err = s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY}); err == nil {
+			accepted++
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+	assert.Equal(t, errEngineQueueFull, err)
+	assert.True(t, accepted <= 3) // the order being matched and the 2 queued orders
+	orderBook.Unlock()
+
+	// the asset's orders are accepted again once its engine caught up
+	assert.Eventually(t, func() bool {
+		return s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY}) == nil
+	}, time.Second, time.Millisecond)
+}
+
+func TestOrderMatchingService_AmendOrder(t *testing.T) {
+	journal := NewJournal()
+	s := NewOrderMatchingService(WithQueueSizes(100, 2), WithIdGenerator(store.NewSequentialIds("id-")), WithJournal(journal))
+	setupTestUsers(s)
+
+	placed, _, err := s.PlaceOrder(OrderReq{UserId: userId1, ClientOrderId: "bid", Limit: 90, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	assert.NoError(t, err)
+	s.Flush()
+
+	// the order keeps working if the amend can't be queued
+	blocked, release := make(chan struct{}), make(chan struct{})
+	go s.runCommand(assetId1, JournalEntry{Type: CancelOrderCommand}, func(*store.Command) { // blocks the engine
+		close(blocked)
+		<-release
+	})
+	<-blocked
+	for err == nil {
+		err = s.SubmitOrder(OrderReq{UserId: userId2, Limit: 200, AssetId: assetId1, Size: 1, BuyOrSell: store.SELL})
+		time.Sleep(10 * time.Microsecond)
+	}
+	_, err = s.AmendOrder(userId1, placed.OrderId, AmendOrderReq{Limit: 95})
+	assert.Equal(t, errEngineQueueFull, errors.Unwrap(err))
+	close(release)
+	s.Flush()
+	order, _ := s.Store.GetOrder(userId1, placed.OrderId)
+	assert.Equal(t, store.Working, order.Status)
+
+	// the order is canceled and replaced in one command
+	amended, err := s.AmendOrder(userId1, placed.OrderId, AmendOrderReq{Limit: 95})
+	assert.NoError(t, err)
+	order, _ = s.Store.GetOrder(userId1, placed.OrderId)
+	assert.Equal(t, store.Canceled, order.Status)
+	replacement, _ := s.Store.GetOrder(userId1, amended.OrderId)
+	assert.Equal(t, store.Working, replacement.Status)
+	assert.Equal(t, store.Usd(95), replacement.Limit)
+	assert.Equal(t, order.UpdateSeq-1, replacement.Seq)
+	clientOrder, _ := s.ClientOrders.Get(userId1, "bid")
+	assert.Equal(t, amended, clientOrder)
+	_, err = s.AmendOrder(userId1, placed.OrderId, AmendOrderReq{Limit: 95})
+	assert.Equal(t, ErrOrderNotWorking, err)
+
+	// the order keeps working once the service is shut down
+	s.Close()
+	_, err = s.AmendOrder(userId1, amended.OrderId, AmendOrderReq{Limit: 99})
+	assert.Equal(t, errShuttingDown, errors.Unwrap(err))
+	replacement, _ = s.Store.GetOrder(userId1, amended.OrderId)
+	assert.Equal(t, store.Working, replacement.Status)
+
+	// replaying the amend places the same replacement
+	r := NewOrderMatchingService()
+	defer r.Close()
+	assert.NoError(t, r.Replay(journal.Entries()))
+	assert.Equal(t, s.Store.GetUserData(userId1), r.Store.GetUserData(userId1))
+}
+
+func TestOrderMatchingService_GetUserOrders_ClockGoesBack(t *testing.T) {
+	start := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	now := start
+	s := NewOrderMatchingService(WithClock(func() time.Time { return now }), WithIdGenerator(store.NewSequentialIds("id-")))
+	defer s.Close()
+
+	setupTestUsers(s)
+	for _, at := range []time.Duration{3, 1, 4, 2} { // the clock was set back between the orders
+		now = start.Add(at * time.Minute)
+		s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY})
+	}
+	s.Flush()
+
+	orders, cursor, err := s.GetUserOrders(userId1, OrderFilter{From: start.Add(2 * time.Minute)}, "", 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(orders))
+	assert.Equal(t, store.OrderId("id-1"), orders[0].OrderId)
+	assert.Equal(t, store.OrderId("id-3"), orders[1].OrderId)
+	orders, cursor, _ = s.GetUserOrders(userId1, OrderFilter{From: start.Add(2 * time.Minute)}, cursor, 2, false)
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, store.OrderId("id-4"), orders[0].OrderId)
+	assert.Empty(t, cursor)
+}
+
+func TestOrderMatchingService_CountOpenOrders(t *testing.T) {
+	s := NewOrderMatchingService(WithIdGenerator(store.NewSequentialIds("id-")))
+	defer s.Close()
+
+	setupTestUsers(s)
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 90, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1000000, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY}) // rejected at save
+	s.Flush()
+	assert.Equal(t, 2, s.Store.CountOpenOrders(userId1))
+
+	// partially filled orders are still open, filled and canceled orders aren't
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.SELL})
+	s.Flush()
+	assert.Equal(t, 2, s.Store.CountOpenOrders(userId1))
+	assert.Equal(t, 0, s.Store.CountOpenOrders(userId2))
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 6, BuyOrSell: store.SELL})
+	s.Flush()
+	assert.Equal(t, 1, s.Store.CountOpenOrders(userId1))
+	s.CancelUserOrder(userId1, "id-2")
+	assert.Equal(t, 0, s.Store.CountOpenOrders(userId1))
+
+	// restored users are counted again
+	userData := s.Store.GetUserData(userId1)
+	order := userData.Orders["id-2"]
+	order.Status = store.Working
+	userData.Orders["id-2"] = order
+	s.Store.SetUserData(userData)
+	assert.Equal(t, 1, s.Store.CountOpenOrders(userId1))
+	s.Store.DeleteUser(userId1)
+	assert.Equal(t, 0, s.Store.CountOpenOrders(userId1))
+}
+
+func TestOrderMatchingService_PlaceOrder_MaxOpenOrders(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+	setupTestUsers(s)
+	s.RateLimiter = NewRateLimiter(RateLimitConfig{
+		Tiers:       map[string]RateLimitTier{"default": {RateLimit: RateLimit{RequestsPerSecond: 100, Burst: 100}, MaxOpenOrders: 2}},
+		DefaultTier: "default",
+	})
+
+	// orders still queued count as open orders
+	orderBook := s.OrderBooks.OrderBook(assetId1)
+	orderBook.Lock()
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, s.SubmitOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId1, Size: 1, BuyOrSell: store.BUY}))
+	}
+	_, _, err := s.PlaceOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId2, Size: 1, BuyOrSell: store.BUY})
+	assert.EqualError(t, err, "user has reached the max of 2 open orders")
+	assert.Error(t, s.DeleteUser(userId1))
+	orderBook.Unlock()
+
+	s.Flush()
+	assert.Equal(t, 2, s.Store.CountOpenOrders(userId1))
+	s.CancelUserOrders(userId1, OrderFilter{}, "canceled by user")
+	_, _, err = s.PlaceOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId2, Size: 1, BuyOrSell: store.BUY})
+	assert.NoError(t, err)
+}
+
+func TestOrderMatchingService_PlaceOrder_MaxOpenOrdersConcurrent(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+	setupTestUsers(s)
+	s.RateLimiter = NewRateLimiter(RateLimitConfig{
+		Tiers:       map[string]RateLimitTier{"default": {RateLimit: RateLimit{RequestsPerSecond: 1000, Burst: 1000}, MaxOpenOrders: 5}},
+		DefaultTier: "default",
+	})
+
+	// orders placed at the same time are checked one at a time, so the max can't be overshot
+	var accepted int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assetId := []store.AssetId{assetId1, assetId2}[i%2]
+			if _, _, err := s.PlaceOrder(OrderReq{UserId: userId1, Limit: 1, AssetId: assetId, Size: 1, BuyOrSell: store.BUY}); err == nil {
+				atomic.AddInt64(&accepted, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	s.Flush()
+
+	assert.Equal(t, int64(5), accepted)
+	assert.Equal(t, 5, s.Store.CountOpenOrders(userId1))
+}
+
+func TestOrderMatchingService_AuctionFillRefund(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+	setupTestUsers(s)
+
+	_, err := s.SetAssetPhase(assetId1, book.Auction)
+	assert.NoError(t, err)
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 101, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 102, AssetId: assetId1, Size: 15, BuyOrSell: store.BUY})
+	s.Flush()
+	assert.Equal(t, store.Usd(8470), s.Store.GetUserData(userId1).Cash) // 15 assets reserved at 102
+
+	transition, err := s.SetAssetPhase(assetId1, book.Continuous)
+	assert.NoError(t, err)
+	assert.Equal(t, store.Usd(101), transition.Auction.Price)
+	assert.Equal(t, store.Usd(8485), s.Store.GetUserData(userId1).Cash) // bought at 101, 1 given back per asset
+	assert.Equal(t, 115, s.Store.GetUserData(userId1).Assets[assetId1])
+}
+
+func TestOrderMatchingService_DeterministicReplay(t *testing.T) {
+	start := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	replay := func(journal *Journal) *OrderMatchingService {
+		var ticks int64
+		clock := func() time.Time {
+			return start.Add(time.Duration(atomic.AddInt64(&ticks, 1)) * time.Millisecond)
+		}
+		s := NewOrderMatchingService(WithClock(clock), WithIdGenerator(store.NewSequentialIds("id-")), WithJournal(journal))
+		setupTestUsers(s)
+
+		s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.BUY})
+		s.SubmitOrder(OrderReq{UserId: userId1, Limit: 90, AssetId: assetId1, Size: 5, BuyOrSell: store.BUY})
+		s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.SELL})
+		s.Flush()
+		_, _, err := s.CreateTransfer(userId2, TransferReq{Type: store.Deposit, Amount: 500, IdempotencyKey: "k1"})
+		assert.NoError(t, err)
+		s.CancelUserOrder(userId1, "id-2")
+		return s
+	}
+
+	s1 := replay(nil)
+	defer s1.Close()
+	journal := NewJournal()
+	s2 := replay(journal)
+	defer s2.Close()
+
+	assert.Equal(t, s1.Store.GetUserIds(), s2.Store.GetUserIds())
+	for _, userId := range s1.Store.GetUserIds() {
+		assert.Equal(t, s1.Store.GetUserData(userId), s2.Store.GetUserData(userId))
+	}
+	assert.Equal(t, uint64(7*store.EventsPerCommand), s1.LastSeq())
+
+	// every command gets the next sequence number and its events are numbered after it
+	order := s1.Store.GetUserData(userId1).Orders["id-1"]
+	assert.Equal(t, store.OrderId("id-1"), order.OrderId)
+	assert.Equal(t, uint64(3*store.EventsPerCommand), order.Seq)
+	assert.Equal(t, uint64(5*store.EventsPerCommand+1), order.UpdateSeq) // filled by the trade of the sell order
+	assert.Equal(t, start.Add(3*time.Millisecond), order.EventAt)
+	assert.Equal(t, uint64(5*store.EventsPerCommand), s1.Store.GetUserData(userId2).Orders["id-3"].Seq)
+	assert.Equal(t, uint64(6*store.EventsPerCommand), s1.Store.GetUserData(userId2).Transfers[0].Seq)
+	assert.Equal(t, store.TransferId("id-4"), s1.Store.GetUserData(userId2).Transfers[0].TransferId)
+	assert.Equal(t, uint64(7*store.EventsPerCommand+1), s1.Store.GetUserData(userId1).Orders["id-2"].UpdateSeq)
+
+	// replaying the journal rebuilds the same state without the checks and the clock
+	entries := journal.Entries()
+	assert.Equal(t, 7, len(entries))
+	s3 := NewOrderMatchingService()
+	defer s3.Close()
+	assert.NoError(t, s3.Replay(entries))
+	assert.Equal(t, s1.LastSeq(), s3.LastSeq())
+	assert.Equal(t, s1.Store.GetUserIds(), s3.Store.GetUserIds())
+	for _, userId := range s1.Store.GetUserIds() {
+		assert.Equal(t, s1.Store.GetUserData(userId), s3.Store.GetUserData(userId))
+	}
+	assert.Error(t, s3.Replay(entries))
+}
+
+func setupTestUsers(s *OrderMatchingService) {
+	req1 := store.InitExchangeReq{
+		UserId: userId1,
+		Assets: []store.Asset{{AssetId: assetId1, Size: 100}, {AssetId: assetId2, Size: 100}},
+		Cash:   10000,
+	}
+
+	req2 := store.InitExchangeReq{
+		UserId: userId2,
+		Assets: []store.Asset{{AssetId: assetId1, Size: 100}, {AssetId: assetId2, Size: 100}},
+		Cash:   10000,
+	}
+
+	s.InitExchange([]store.InitExchangeReq{req1, req2})
+}
+
+func getOrder(orders []store.Order, orderType store.BuyOrSell) store.Order {
+	for _, o := range orders {
+		if o.BuyOrSell == orderType {
+			return o
+		}
+	}
+	return store.Order{}
+}
+
+// countBookOrders returns the number of orders of a side in an asset's order book, read under the book's lock
+func countBookOrders(s *OrderMatchingService, assetId store.AssetId, side store.BuyOrSell) int {
+	count := 0
+	for _, order := range s.OrderBooks.GetOrders(assetId) {
+		if order.BuyOrSell == side {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"stockexchange/book"
+	"stockexchange/store"
+)
+
+// SessionSchedule represents the daily trading session schedule of an asset
+type SessionSchedule struct {
+	AssetId  store.AssetId    `json:"asset_id"` // asset the schedule applies to
+	Location string           `json:"location"` // IANA time zone of the schedule, e.g America/New_York. Defaults to UTC
+	Phases   []ScheduledPhase `json:"phases"`   // phases of the session, in the order they start
+}
+
+// ScheduledPhase represents a trading phase starting at a time of day
+type ScheduledPhase struct {
+	At    string            `json:"at"`    // time of day the phase starts, e.g 09:30
+	Phase book.TradingPhase `json:"phase"` // trading phase to move to
+}
+
+// LoadSessionSchedules reads the session schedules from a JSON file
+func LoadSessionSchedules(path string) ([]SessionSchedule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []SessionSchedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("invalid session schedule file %s: %v", path, err)
+	}
+	for _, schedule := range schedules {
+		if err := schedule.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+// validate returns an error if the schedule has an unknown time zone, phase or time of day
+func (ss SessionSchedule) validate() error {
+	if ss.AssetId == "" {
+		return fmt.Errorf("session schedule is missing asset_id")
+	}
+	if _, err := time.LoadLocation(ss.Location); err != nil {
+		return fmt.Errorf("session schedule for %s: %v", ss.AssetId, err)
+	}
+	if len(ss.Phases) == 0 {
+		return fmt.Errorf("session schedule for %s has no phases", ss.AssetId)
+	}
+
+	previous := -1
+	for _, p := range ss.Phases {
+		if !book.IsValidPhase(p.Phase) {
+			return fmt.Errorf("session schedule for %s: unknown phase %s", ss.AssetId, p.Phase)
+		}
+		minute, err := parseTimeOfDay(p.At)
+		if err != nil {
+			return fmt.Errorf("session schedule for %s: %v", ss.AssetId, err)
+		}
+		if minute <= previous {
+			return fmt.Errorf("session schedule for %s: phases must be in increasing time order", ss.AssetId)
+		}
+		previous = minute
+	}
+	return nil
+}
+
+// phaseAt returns the phase the schedule is in at time t.
+// Before the first phase of the day, the schedule is still in the last phase of the previous day.
+func (ss SessionSchedule) phaseAt(t time.Time) book.TradingPhase {
+	location, _ := time.LoadLocation(ss.Location)
+	t = t.In(location)
+	minute := t.Hour()*60 + t.Minute()
+
+	phase := ss.Phases[len(ss.Phases)-1].Phase
+	for _, p := range ss.Phases {
+		start, _ := parseTimeOfDay(p.At)
+		if start > minute {
+			break
+		}
+		phase = p.Phase
+	}
+	return phase
+}
+
+// parseTimeOfDay parses a time of day in the format 15:04 and returns it in minutes since midnight
+func parseTimeOfDay(at string) (int, error) {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", at)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// SessionScheduler moves order books through their trading phases following their session schedules
+type SessionScheduler struct {
+	service   *OrderMatchingService
+	schedules []SessionSchedule
+	applied   map[store.AssetId]book.TradingPhase // last phase applied by the scheduler for each asset
+	now       func() time.Time
+}
+
+// NewSessionScheduler returns a scheduler moving the assets of s through the phases of their schedules
+func NewSessionScheduler(s *OrderMatchingService, schedules []SessionSchedule) *SessionScheduler {
+	return &SessionScheduler{
+		service:   s,
+		schedules: schedules,
+		applied:   make(map[store.AssetId]book.TradingPhase),
+		now:       s.Now,
+	}
+}
+
+// Run initialises every scheduled order book to its current phase and then applies
+// scheduled phase changes every interval until stop is closed
+func (ss *SessionScheduler) Run(interval time.Duration, stop <-chan struct{}) {
+	for _, schedule := range ss.schedules {
+		phase := schedule.phaseAt(ss.now())
+		ss.service.OrderBooks.ForcePhase(schedule.AssetId, phase)
+		ss.applied[schedule.AssetId] = phase
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ss.tick()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tick applies the phase changes that became due since the last tick.
+// Only scheduled phase changes are applied, so manual transitions made through the admin API
+// stay in effect until the next scheduled phase starts.
+func (ss *SessionScheduler) tick() {
+	for _, schedule := range ss.schedules {
+		phase := schedule.phaseAt(ss.now())
+		if ss.applied[schedule.AssetId] == phase {
+			continue
+		}
+		ss.applied[schedule.AssetId] = phase
+
+		if _, err := ss.service.SetAssetPhase(schedule.AssetId, phase); err != nil {
+			ss.service.Logger.Error("session scheduler failed to change the phase", "asset_id", schedule.AssetId, "phase", phase, "error", err)
+		}
+	}
+}
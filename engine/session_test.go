@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/book"
+)
+
+func TestSessionSchedule_PhaseAt(t *testing.T) {
+	schedule := SessionSchedule{
+		AssetId:  assetId1,
+		Location: "America/New_York",
+		Phases: []ScheduledPhase{
+			{At: "04:00", Phase: book.PreOpen},
+			{At: "09:25", Phase: book.Auction},
+			{At: "09:30", Phase: book.Continuous},
+			{At: "15:55", Phase: book.Auction},
+			{At: "16:00", Phase: book.PostClose},
+			{At: "20:00", Phase: book.Closed},
+		},
+	}
+	assert.NoError(t, schedule.validate())
+
+	location, _ := time.LoadLocation("America/New_York")
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, 6, 1, hour, minute, 0, 0, location)
+	}
+
+	assert.Equal(t, book.Closed, schedule.phaseAt(at(3, 59))) // still in the last phase of the previous day
+	assert.Equal(t, book.PreOpen, schedule.phaseAt(at(4, 0)))
+	assert.Equal(t, book.Auction, schedule.phaseAt(at(9, 29)))
+	assert.Equal(t, book.Continuous, schedule.phaseAt(at(12, 0)))
+	assert.Equal(t, book.Auction, schedule.phaseAt(at(15, 58)))
+	assert.Equal(t, book.PostClose, schedule.phaseAt(at(16, 0)))
+	assert.Equal(t, book.Closed, schedule.phaseAt(at(23, 59)))
+	assert.Equal(t, book.Continuous, schedule.phaseAt(at(12, 0).UTC())) // times are converted to the schedule's time zone
+}
+
+func TestSessionSchedule_Validate(t *testing.T) {
+	assert.Error(t, SessionSchedule{AssetId: assetId1}.validate())
+	assert.Error(t, SessionSchedule{AssetId: assetId1, Phases: []ScheduledPhase{{At: "9am", Phase: book.Continuous}}}.validate())
+	assert.Error(t, SessionSchedule{AssetId: assetId1, Phases: []ScheduledPhase{{At: "09:00", Phase: "OPEN"}}}.validate())
+	assert.Error(t, SessionSchedule{AssetId: assetId1, Location: "Nowhere", Phases: []ScheduledPhase{{At: "09:00", Phase: book.Continuous}}}.validate())
+	assert.Error(t, SessionSchedule{AssetId: assetId1, Phases: []ScheduledPhase{{At: "10:00", Phase: book.Continuous}, {At: "09:00", Phase: book.Auction}}}.validate())
+}
+
+func TestSessionScheduler_Tick(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	now := time.Date(2021, 6, 1, 9, 0, 0, 0, time.UTC)
+	scheduler := NewSessionScheduler(s, []SessionSchedule{{
+		AssetId: assetId1,
+		Phases: []ScheduledPhase{
+			{At: "08:00", Phase: book.PreOpen},
+			{At: "09:25", Phase: book.Auction},
+			{At: "09:30", Phase: book.Continuous},
+			{At: "16:00", Phase: book.PostClose},
+			{At: "20:00", Phase: book.Closed},
+		},
+	}})
+	scheduler.now = func() time.Time { return now }
+
+	stop := make(chan struct{})
+	close(stop)
+	scheduler.Run(time.Hour, stop) // initialise phases and return
+	assert.Equal(t, book.PreOpen, s.OrderBooks.GetPhase(assetId1))
+
+	now = now.Add(26 * time.Minute)
+	scheduler.tick()
+	assert.Equal(t, book.Auction, s.OrderBooks.GetPhase(assetId1))
+
+	// manual transitions stay in effect until the next scheduled phase
+	_, err := s.SetAssetPhase(assetId1, book.Halted)
+	assert.NoError(t, err)
+	scheduler.tick()
+	assert.Equal(t, book.Halted, s.OrderBooks.GetPhase(assetId1))
+
+	now = now.Add(5 * time.Minute)
+	scheduler.tick() // HALTED can't move to CONTINUOUS
+	assert.Equal(t, book.Halted, s.OrderBooks.GetPhase(assetId1))
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"sync"
+
+	"stockexchange/store"
+)
+
+// streamBufferSize is the number of trades buffered for a streaming subscriber, trades are dropped when it's full
+const streamBufferSize = 100
+
+// TradeStream fans out executed trades to streaming subscribers
+type TradeStream struct {
+	subscribers map[chan store.Trade]struct{}
+	sync.Mutex
+}
+
+func newTradeStream() *TradeStream {
+	return &TradeStream{
+		subscribers: make(map[chan store.Trade]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every trade executed from now on
+func (ts *TradeStream) Subscribe() chan store.Trade {
+	ts.Lock()
+	defer ts.Unlock()
+
+	ch := make(chan store.Trade, streamBufferSize)
+	ts.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe stops sending trades to a channel returned by Subscribe
+func (ts *TradeStream) Unsubscribe(ch chan store.Trade) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	delete(ts.subscribers, ch)
+}
+
+// AddTrade sends a trade to every subscriber.
+// It never blocks the matching engine, trades are dropped for subscribers that are too slow.
+func (ts *TradeStream) AddTrade(trade store.Trade) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	for ch := range ts.subscribers {
+		select {
+		case ch <- trade:
+		default:
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestTradeStream(t *testing.T) {
+	ts := newTradeStream()
+	ch := ts.Subscribe()
+
+	for i := 0; i < streamBufferSize+10; i++ {
+		ts.AddTrade(store.Trade{AssetId: assetId1, Size: i})
+	}
+	assert.Equal(t, streamBufferSize, len(ch)) // slow subscribers don't block and miss trades
+	assert.Equal(t, 0, (<-ch).Size)
+
+	ts.Unsubscribe(ch)
+	ts.AddTrade(store.Trade{AssetId: assetId1})
+	assert.Equal(t, streamBufferSize-1, len(ch))
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"stockexchange/store"
+)
+
+// tickerWindow is the rolling window of the ticker statistics
+const tickerWindow = 24 * time.Hour
+
+// tickerBucketPeriod is the period of the buckets the rolling window is split into.
+// Statistics leave the window one bucket at a time.
+const tickerBucketPeriod = time.Minute
+
+// TickerStats represents the last price and rolling 24h statistics of an asset
+type TickerStats struct {
+	AssetId     store.AssetId
+	LastPrice   store.Usd // price of the last trade, 0 if the asset never traded
+	LastTradeAt time.Time // time of the last trade
+	Open        store.Usd // price of the first trade in the window
+	High        store.Usd // highest trade price in the window
+	Low         store.Usd // lowest trade price in the window
+	Volume      int       // number of assets traded in the window
+	Trades      int       // number of trades in the window
+}
+
+// Change returns the price change over the window, in Usd cents
+func (ts TickerStats) Change() store.Usd {
+	if ts.Trades == 0 {
+		return 0
+	}
+	return ts.LastPrice - ts.Open
+}
+
+// ChangePercent returns the price change over the window, in percent
+func (ts TickerStats) ChangePercent() float64 {
+	if ts.Trades == 0 || ts.Open == 0 {
+		return 0
+	}
+	return float64(ts.Change()) * 100 / float64(ts.Open)
+}
+
+// tickerBucket holds the statistics of the trades of one bucket period
+type tickerBucket struct {
+	start  time.Time
+	open   store.Usd
+	high   store.Usd
+	low    store.Usd
+	volume int
+	trades int
+}
+
+// assetTicker maintains the ticker statistics of an asset incrementally.
+// Totals are updated on every trade and when a bucket leaves the window, so reads don't scan the trades.
+type assetTicker struct {
+	stats   TickerStats
+	buckets []tickerBucket // buckets in the window, oldest first
+}
+
+// addTrade adds a trade to the statistics
+func (at *assetTicker) addTrade(trade store.Trade) {
+	at.evict(trade.ExecutedAt)
+
+	start := trade.ExecutedAt.Truncate(tickerBucketPeriod)
+	if n := len(at.buckets); n == 0 || at.buckets[n-1].start.Before(start) {
+		at.buckets = append(at.buckets, tickerBucket{start: start, open: trade.Price, high: trade.Price, low: trade.Price})
+	}
+	bucket := &at.buckets[len(at.buckets)-1]
+	if trade.Price > bucket.high {
+		bucket.high = trade.Price
+	}
+	if trade.Price < bucket.low {
+		bucket.low = trade.Price
+	}
+	bucket.volume += trade.Size
+	bucket.trades++
+
+	stats := &at.stats
+	if stats.Trades == 0 {
+		stats.Open, stats.High, stats.Low = trade.Price, trade.Price, trade.Price
+	}
+	if trade.Price > stats.High {
+		stats.High = trade.Price
+	}
+	if trade.Price < stats.Low {
+		stats.Low = trade.Price
+	}
+	stats.Volume += trade.Size
+	stats.Trades++
+	stats.LastPrice = trade.Price
+	stats.LastTradeAt = trade.ExecutedAt
+}
+
+// evict removes the buckets that left the window at time now from the statistics
+func (at *assetTicker) evict(now time.Time) {
+	cutoff := now.Add(-tickerWindow)
+
+	evicted := 0
+	for evicted < len(at.buckets) && !at.buckets[evicted].start.Add(tickerBucketPeriod).After(cutoff) {
+		at.stats.Volume -= at.buckets[evicted].volume
+		at.stats.Trades -= at.buckets[evicted].trades
+		evicted++
+	}
+	if evicted == 0 {
+		return
+	}
+	at.buckets = at.buckets[evicted:]
+
+	// recompute the window's open, high and low from the remaining buckets
+	at.stats.Open, at.stats.High, at.stats.Low = 0, 0, 0
+	for i, bucket := range at.buckets {
+		if i == 0 || bucket.high > at.stats.High {
+			at.stats.High = bucket.high
+		}
+		if i == 0 || bucket.low < at.stats.Low {
+			at.stats.Low = bucket.low
+		}
+	}
+	if len(at.buckets) > 0 {
+		at.stats.Open = at.buckets[0].open
+	}
+}
+
+// Tickers maintains the ticker statistics of every asset from executed trades
+type Tickers struct {
+	tickers map[store.AssetId]*assetTicker
+	sync.Mutex
+}
+
+func newTickers() *Tickers {
+	return &Tickers{
+		tickers: make(map[store.AssetId]*assetTicker),
+	}
+}
+
+// AddTrade adds a trade to the statistics of the trade's asset
+func (t *Tickers) AddTrade(trade store.Trade) {
+	t.Lock()
+	defer t.Unlock()
+
+	ticker, ok := t.tickers[trade.AssetId]
+	if !ok {
+		ticker = &assetTicker{stats: TickerStats{AssetId: trade.AssetId}}
+		t.tickers[trade.AssetId] = ticker
+	}
+	ticker.addTrade(trade)
+}
+
+// Get returns the ticker statistics of an asset at time now
+func (t *Tickers) Get(assetId store.AssetId, now time.Time) TickerStats {
+	t.Lock()
+	defer t.Unlock()
+
+	ticker, ok := t.tickers[assetId]
+	if !ok {
+		return TickerStats{AssetId: assetId}
+	}
+	ticker.evict(now)
+	return ticker.stats
+}
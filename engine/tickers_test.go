@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestTickers_AddTrade(t *testing.T) {
+	tickers := newTickers()
+	start := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+
+	tickers.AddTrade(store.Trade{AssetId: assetId1, Price: 100, Size: 10, ExecutedAt: start})
+	tickers.AddTrade(store.Trade{AssetId: assetId1, Price: 120, Size: 5, ExecutedAt: start.Add(time.Hour)})
+	tickers.AddTrade(store.Trade{AssetId: assetId1, Price: 90, Size: 5, ExecutedAt: start.Add(2 * time.Hour)})
+	tickers.AddTrade(store.Trade{AssetId: assetId1, Price: 110, Size: 10, ExecutedAt: start.Add(3 * time.Hour)})
+
+	stats := tickers.Get(assetId1, start.Add(3*time.Hour))
+	assert.Equal(t, TickerStats{
+		AssetId:     assetId1,
+		LastPrice:   110,
+		LastTradeAt: start.Add(3 * time.Hour),
+		Open:        100,
+		High:        120,
+		Low:         90,
+		Volume:      30,
+		Trades:      4,
+	}, stats)
+	assert.Equal(t, store.Usd(10), stats.Change())
+	assert.Equal(t, 10.0, stats.ChangePercent())
+
+	// first two trades leave the 24h window
+	stats = tickers.Get(assetId1, start.Add(25*time.Hour+time.Minute))
+	assert.Equal(t, store.Usd(90), stats.Open)
+	assert.Equal(t, store.Usd(110), stats.High)
+	assert.Equal(t, store.Usd(90), stats.Low)
+	assert.Equal(t, 15, stats.Volume)
+	assert.Equal(t, 2, stats.Trades)
+	assert.Equal(t, store.Usd(20), stats.Change())
+
+	// no trades in the window, last price is kept
+	stats = tickers.Get(assetId1, start.Add(48*time.Hour))
+	assert.Equal(t, store.Usd(110), stats.LastPrice)
+	assert.Equal(t, 0, stats.Volume)
+	assert.Equal(t, 0, stats.Trades)
+	assert.Equal(t, store.Usd(0), stats.High)
+	assert.Equal(t, store.Usd(0), stats.Change())
+
+	assert.Equal(t, TickerStats{AssetId: assetId2}, tickers.Get(assetId2, start))
+}
+
+func TestOrderMatchingService_GetTicker(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 105, AssetId: assetId1, Size: 10, BuyOrSell: store.SELL})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 100, AssetId: assetId1, Size: 4, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId2, Limit: 95, AssetId: assetId1, Size: 3, BuyOrSell: store.BUY})
+
+	s.Flush()
+
+	stats, bestBid, bestAsk := s.GetTicker(assetId1)
+	assert.Equal(t, store.Usd(100), stats.LastPrice)
+	assert.Equal(t, store.Usd(95), bestBid.Limit)
+	assert.Equal(t, 3, bestBid.Size)
+	assert.Equal(t, store.Usd(100), bestAsk.Limit)
+	assert.Equal(t, 6, bestAsk.Size)
+	assert.Equal(t, 4, stats.Volume)
+	assert.Equal(t, 1, stats.Trades)
+}
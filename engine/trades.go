@@ -0,0 +1,11 @@
+package engine
+
+import (
+	"stockexchange/store"
+)
+
+// UserTrade is a fill of one of a user's orders
+type UserTrade struct {
+	Order store.Order
+	Fill  store.Fill
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"stockexchange/store"
+)
+
+func TestStore_AddTransfer(t *testing.T) {
+	s := NewOrderMatchingService()
+	defer s.Close()
+
+	setupTestUsers(s)
+
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 100, AssetId: assetId1, Size: 50, BuyOrSell: store.BUY})
+	s.SubmitOrder(OrderReq{UserId: userId1, Limit: 200, AssetId: assetId1, Size: 60, BuyOrSell: store.SELL})
+	s.Flush()
+
+	deposit := store.Transfer{TransferId: "t1", UserId: userId1, TransferType: store.Deposit, Amount: 1000, IdempotencyKey: "k1"}
+	transfer, created, err := s.Store.AddTransfer(deposit)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, deposit, transfer)
+	assert.Equal(t, store.Usd(6000), s.Store.GetUserData(userId1).Cash)
+
+	// retrying with the same key returns the original transfer
+	transfer, created, err = s.Store.AddTransfer(store.Transfer{TransferId: "t2", UserId: userId1, TransferType: store.Deposit, Amount: 1000, IdempotencyKey: "k1"})
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, store.TransferId("t1"), transfer.TransferId)
+	assert.Equal(t, store.Usd(6000), s.Store.GetUserData(userId1).Cash)
+
+	_, _, err = s.Store.AddTransfer(store.Transfer{TransferId: "t3", UserId: userId1, TransferType: store.Withdrawal, Amount: 1000, IdempotencyKey: "k1"})
+	assert.Equal(t, store.ErrIdempotencyKeyReused, err)
+
+	// withdrawals can't take cash or assets reserved by working orders
+	_, _, err = s.Store.AddTransfer(store.Transfer{TransferId: "t4", UserId: userId1, TransferType: store.Withdrawal, Amount: 6001, IdempotencyKey: "k4"})
+	assert.Error(t, err)
+	_, _, err = s.Store.AddTransfer(store.Transfer{TransferId: "t5", UserId: userId1, TransferType: store.Withdrawal, AssetId: assetId1, Amount: 41, IdempotencyKey: "k5"})
+	assert.Error(t, err)
+
+	_, _, err = s.Store.AddTransfer(store.Transfer{TransferId: "t6", UserId: userId1, TransferType: store.Withdrawal, Amount: 6000, IdempotencyKey: "k6"})
+	assert.NoError(t, err)
+	_, _, err = s.Store.AddTransfer(store.Transfer{TransferId: "t7", UserId: userId1, TransferType: store.Withdrawal, AssetId: assetId1, Amount: 40, IdempotencyKey: "k7"})
+	assert.NoError(t, err)
+	_, _, err = s.Store.AddTransfer(store.Transfer{TransferId: "t8", UserId: userId1, TransferType: store.Deposit, AssetId: "AAPL", Amount: 5, IdempotencyKey: "k8"})
+	assert.NoError(t, err)
+
+	userData := s.Store.GetUserData(userId1)
+	assert.Equal(t, store.Usd(0), userData.Cash)
+	assert.Equal(t, 0, userData.Assets[assetId1])
+	assert.Equal(t, 5, userData.Assets["AAPL"])
+	assert.Equal(t, 4, len(userData.Transfers))
+
+	reservedCash, reservedAssets := s.Store.GetReserved(userId1)
+	assert.Equal(t, store.Usd(5000), reservedCash)
+	assert.Equal(t, map[store.AssetId]int{assetId1: 60}, reservedAssets)
+
+	_, _, err = s.Store.AddTransfer(store.Transfer{TransferId: "t9", UserId: "unknown", TransferType: store.Deposit, Amount: 1, IdempotencyKey: "k9"})
+	assert.Error(t, err)
+}
@@ -6,4 +6,5 @@ require (
 	github.com/gorilla/mux v1.8.0
 	github.com/lithammer/shortuuid/v3 v3.0.6
 	github.com/stretchr/testify v1.7.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
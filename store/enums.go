@@ -0,0 +1,113 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalJSON marshals a side to "BUY" or "SELL"
+func (side BuyOrSell) MarshalJSON() ([]byte, error) {
+	return json.Marshal(SideName(side))
+}
+
+// UnmarshalJSON unmarshals a side, see parseSide
+func (side *BuyOrSell) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := parseSide(value)
+	if err != nil {
+		return err
+	}
+	*side = parsed
+	return nil
+}
+
+// UnmarshalYAML unmarshals a side, see parseSide
+func (side *BuyOrSell) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	parsed, err := parseSide(value)
+	if err != nil {
+		return err
+	}
+	*side = parsed
+	return nil
+}
+
+// parseSide parses the JSON or YAML value of a side, BUY or SELL.
+// Any other value is rejected, it never defaults to a side.
+func parseSide(value interface{}) (BuyOrSell, error) {
+	switch value {
+	case "BUY":
+		return BUY, nil
+	case "SELL":
+		return SELL, nil
+	}
+	return BUY, fmt.Errorf("invalid side %v, must be BUY or SELL", value)
+}
+
+// IsValid returns if an order type is known
+func (t OrderType) IsValid() bool {
+	return t == Limit
+}
+
+// UnmarshalJSON unmarshals an order type, unknown types are rejected
+func (t *OrderType) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid order type %s, must be %s", data, Limit)
+	}
+	return t.set(value)
+}
+
+// UnmarshalYAML unmarshals an order type, unknown types are rejected
+func (t *OrderType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	return t.set(value)
+}
+
+func (t *OrderType) set(value string) error {
+	if !OrderType(value).IsValid() {
+		return fmt.Errorf("invalid order type %q, must be %s", value, Limit)
+	}
+	*t = OrderType(value)
+	return nil
+}
+
+// IsValid returns if a time in force is known
+func (tif TimeInForce) IsValid() bool {
+	return tif == GTC || tif == IOC || tif == FOK
+}
+
+// UnmarshalJSON unmarshals a time in force, unknown values are rejected
+func (tif *TimeInForce) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid time in force %s, must be %s, %s or %s", data, GTC, IOC, FOK)
+	}
+	return tif.set(value)
+}
+
+// UnmarshalYAML unmarshals a time in force, unknown values are rejected
+func (tif *TimeInForce) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	return tif.set(value)
+}
+
+func (tif *TimeInForce) set(value string) error {
+	if !TimeInForce(value).IsValid() {
+		return fmt.Errorf("invalid time in force %q, must be %s, %s or %s", value, GTC, IOC, FOK)
+	}
+	*tif = TimeInForce(value)
+	return nil
+}
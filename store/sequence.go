@@ -0,0 +1,81 @@
+package store
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/lithammer/shortuuid/v3"
+)
+
+// Deterministic replays
+//
+// Every command accepted by the exchange, e.g new users, orders, cancels, phase changes and transfers, gets the next
+// number of an exchange-wide sequence and the time of the service's clock when it's accepted. The events of a command,
+// i.e its trades, cancels of unfilled IOC and FOK orders and market events, are numbered after the command and happen
+// at its time. The matching engines of different assets process their commands concurrently, numbering the events of
+// every command after the command keeps the numbers and times the same whatever order the engines run in, so replaying
+// a journal of the commands in sequence order rebuilds the same state.
+
+// Clock returns the current time
+type Clock func() time.Time
+
+// IdGenerator returns a new unique id
+type IdGenerator func() string
+
+// RandomIds generates random unique ids
+// Note, in a prod environment, this could be improved to ensure uniqueness in a distributed system.
+func RandomIds() string {
+	return shortuuid.New()
+}
+
+// NewSequentialIds returns an id generator of sequential ids starting with a prefix, e.g id-1, id-2
+func NewSequentialIds(prefix string) IdGenerator {
+	var last uint64
+	return func() string {
+		return fmt.Sprintf("%s%d", prefix, atomic.AddUint64(&last, 1))
+	}
+}
+
+// EventsPerCommand is the spacing of the sequence numbers of commands, the events of a command take the numbers
+// up to the next command, e.g the trades of the command 3000000 are numbered 3000001, 3000002...
+const EventsPerCommand = 1000000
+
+// Sequence hands out monotonic exchange-wide sequence numbers of commands, starting at EventsPerCommand
+type Sequence struct {
+	last uint64
+}
+
+// Next returns the sequence number of the next command
+func (s *Sequence) Next() uint64 {
+	return atomic.AddUint64(&s.last, EventsPerCommand)
+}
+
+// Last returns the sequence number of the last command, 0 if there was none
+func (s *Sequence) Last() uint64 {
+	return atomic.LoadUint64(&s.last)
+}
+
+// Set makes seq the sequence number of the last command, e.g once a journal was replayed
+func (s *Sequence) Set(seq uint64) {
+	atomic.StoreUint64(&s.last, seq)
+}
+
+// Command is an accepted command being processed, it numbers and times the events of the command
+type Command struct {
+	Seq    uint64    // sequence number of the command
+	At     time.Time // time the command was accepted
+	events uint64    // number of events of the command so far
+}
+
+// NewCommand returns the command with sequence number seq accepted at time at
+func NewCommand(seq uint64, at time.Time) *Command {
+	return &Command{Seq: seq, At: at}
+}
+
+// NextEvent returns the sequence number of the next event of the command.
+// A command is processed by a single matching engine, so it's not safe for concurrent use.
+func (c *Command) NextEvent() uint64 {
+	c.events++
+	return c.Seq + c.events
+}
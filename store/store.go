@@ -0,0 +1,548 @@
+// Package store keeps the users of the exchange in memory: their cash, assets, orders, fills and transfers.
+// It's shared by the order books of every asset and safe for concurrent use.
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type InitExchangeReq struct {
+	UserId UserId  `json:"user_id" yaml:"user_id"`
+	Assets []Asset `json:"assets" yaml:"assets"`
+	Cash   Usd     `json:"cash" yaml:"cash"`
+}
+
+type Asset struct {
+	AssetId AssetId `json:"asset_id" yaml:"asset_id"`
+	Size    int     `json:"size" yaml:"size"`
+}
+
+// SideName returns the name of the side of an order, BUY or SELL
+func SideName(side BuyOrSell) string {
+	if side == BUY {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+// type definitions
+type UserId string
+
+type AssetId string
+
+type OrderId string
+
+type BuyOrSell int
+
+type Usd int // in cents
+
+type OrderType string
+
+type TimeInForce string
+
+type OrderStatus string
+
+type UserStatus string
+
+// enums
+const (
+	BUY  BuyOrSell = iota // 0
+	SELL                  // 1
+)
+
+const (
+	Limit OrderType = "LIMIT" // trades at the limit price or better
+)
+
+const (
+	GTC TimeInForce = "GTC" // good till canceled, the unfilled size rests in the book
+	IOC TimeInForce = "IOC" // immediate or cancel, the unfilled size is canceled
+	FOK TimeInForce = "FOK" // fill or kill, the order is canceled unless it's filled in full at once
+)
+
+const (
+	Working  OrderStatus = "WORKING"
+	Complete OrderStatus = "COMPLETE"
+	Canceled OrderStatus = "CANCELED"
+	Rejected OrderStatus = "REJECTED"
+)
+
+const (
+	Active    UserStatus = "ACTIVE"    // user can trade
+	Suspended UserStatus = "SUSPENDED" // user can't place new orders
+)
+
+// Order struct represents an order
+type Order struct {
+	OrderId       OrderId     // id of val
+	ClientOrderId string      // id of val chosen by the user
+	UserId        UserId      // id of user who owns the val
+	Limit         Usd         // limit price, in Usd cents
+	AssetId       AssetId     // asset to trade
+	Size          int         // number of assets
+	BuyOrSell     BuyOrSell   // buy or sell val
+	OrderType     OrderType   // type of val, LIMIT
+	TimeInForce   TimeInForce // how long the val stays in the book, GTC, IOC or FOK
+	EventAt       time.Time   // time when val was created
+	Status        OrderStatus // status of the val
+	Filled        int         // total number of assets filled during a trade
+	Reason        string      // why the order was canceled or rejected
+	Seq           uint64      // sequence number of the command that created the order
+	UpdateSeq     uint64      // sequence number of the last fill or cancel of the order
+	RequestId     string      // id of the request that placed the order
+	FeeReserve    Usd         // cash reserved for the fees of a buy order and not charged yet, released once it's closed
+}
+
+// UserData struct represents a struct for storing user assets and orders
+type UserData struct {
+	UserId    UserId
+	Status    UserStatus         // whether the user can trade
+	Cash      Usd                // cash amount in Usd e.g $100 -> 10000 Usd
+	Assets    map[AssetId]int    // map of AssetId -> size of asset
+	Orders    map[OrderId]Order  // map of OrderId -> val metadata
+	OrderIds  []OrderId          // ids of orders in the order they were created, i.e by seq
+	Fills     map[OrderId][]Fill // map of OrderId -> fills of the order, oldest first
+	Transfers []Transfer         // deposits and withdrawals, oldest first
+}
+
+// Store acts the database. An in memory db
+// It's safe for concurrent use, every method reads or updates a user's data atomically,
+// so the cash and assets of a user stay consistent while the orders of different assets are matched in parallel.
+type Store struct {
+	db         map[UserId]UserData
+	openOrders map[UserId]int // number of working orders of every user, kept up to date by setOrder
+	mu         sync.RWMutex   // synchronize access to the db
+}
+
+// NewStore returns an empty store
+func NewStore() *Store {
+	return &Store{
+		db:         make(map[UserId]UserData),
+		openOrders: make(map[UserId]int),
+	}
+}
+
+// CreateUser crates a new user for the exchange.
+// Existing users are left unchanged, it returns false if the user already exists.
+func (s *Store) CreateUser(req InitExchangeReq) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.db[req.UserId]; ok {
+		return false
+	}
+
+	userData := UserData{
+		UserId: req.UserId,
+		Status: Active,
+		Cash:   req.Cash,
+		Assets: make(map[AssetId]int),   // init assets map for every user
+		Orders: make(map[OrderId]Order), // init orders map for every user
+		Fills:  make(map[OrderId][]Fill),
+	}
+	for _, asset := range req.Assets {
+		userData.Assets[asset.AssetId] = asset.Size
+	}
+	s.db[req.UserId] = userData
+	return true
+}
+
+// HasUser returns if a user exists
+func (s *Store) HasUser(userId UserId) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.db[userId]
+	return ok
+}
+
+// GetUserIds returns the ids of all users, sorted
+func (s *Store) GetUserIds() []UserId {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userIds := make([]UserId, 0, len(s.db))
+	for userId := range s.db {
+		userIds = append(userIds, userId)
+	}
+	sort.Slice(userIds, func(i, j int) bool { return userIds[i] < userIds[j] })
+	return userIds
+}
+
+// SetUserStatus sets whether a user can trade
+func (s *Store) SetUserStatus(userId UserId, status UserStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userData := s.getUserData(userId)
+	userData.Status = status
+	s.db[userId] = userData
+}
+
+// DeleteUser deletes a user and their orders
+func (s *Store) DeleteUser(userId UserId) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.db, userId)
+	delete(s.openOrders, userId)
+}
+
+// GetUserData gets a copy of a user's data, later updates of the user's data don't change it
+func (s *Store) GetUserData(userId UserId) UserData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getUserData(userId).copy()
+}
+
+// getUserData gets a user's data for an update, the store must be locked
+func (s *Store) getUserData(userId UserId) UserData {
+	return s.db[userId]
+}
+
+// copy returns a copy of a user's data that doesn't share its maps and slices
+func (u UserData) copy() UserData {
+	if u.UserId == "" {
+		return u
+	}
+
+	c := u
+	c.Assets = make(map[AssetId]int, len(u.Assets))
+	for assetId, size := range u.Assets {
+		c.Assets[assetId] = size
+	}
+	c.Orders = make(map[OrderId]Order, len(u.Orders))
+	for orderId, order := range u.Orders {
+		c.Orders[orderId] = order
+	}
+	c.OrderIds = append([]OrderId(nil), u.OrderIds...)
+	if u.Fills != nil {
+		c.Fills = make(map[OrderId][]Fill, len(u.Fills))
+		for orderId, fills := range u.Fills {
+			c.Fills[orderId] = append([]Fill(nil), fills...)
+		}
+	}
+	c.Transfers = append([]Transfer(nil), u.Transfers...)
+	return c
+}
+
+// SetUserData replaces the data of a user, e.g to restore a user from a snapshot
+func (s *Store) SetUserData(userData UserData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.db[userData.UserId] = userData.copy()
+	count := 0
+	for _, order := range userData.Orders {
+		if order.Status == Working {
+			count++
+		}
+	}
+	s.openOrders[userData.UserId] = count
+}
+
+// setOrder sets an order of a user's data and counts the user's working orders. The store must be locked.
+func (s *Store) setOrder(userData UserData, order Order) {
+	if previous, ok := userData.Orders[order.OrderId]; ok && previous.Status == Working {
+		s.openOrders[userData.UserId]--
+	}
+	if order.Status == Working {
+		s.openOrders[userData.UserId]++
+	}
+	userData.Orders[order.OrderId] = order
+}
+
+// CountOpenOrders returns the number of working orders of a user
+func (s *Store) CountOpenOrders(userId UserId) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.openOrders[userId]
+}
+
+// GetUserStatus returns whether a user can trade, empty if the user doesn't exist
+func (s *Store) GetUserStatus(userId UserId) UserStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getUserData(userId).Status
+}
+
+// GetCash returns the cash of a user available to new orders and withdrawals
+func (s *Store) GetCash(userId UserId) Usd {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getUserData(userId).Cash
+}
+
+// GetAssetSize returns the size of an asset a user has available to new sell orders, and if the user owns the asset
+func (s *Store) GetAssetSize(userId UserId, assetId AssetId) (int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	size, ok := s.getUserData(userId).Assets[assetId]
+	return size, ok
+}
+
+// GetOrder returns an order of a user, and if it exists
+func (s *Store) GetOrder(userId UserId, orderId OrderId) (Order, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, ok := s.getUserData(userId).Orders[orderId]
+	return order, ok
+}
+
+// GetFills returns a copy of the fills of a user's order, oldest first
+func (s *Store) GetFills(userId UserId, orderId OrderId) []Fill {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Fill(nil), s.getUserData(userId).Fills[orderId]...)
+}
+
+// GetOrders returns the orders of a user selected by match, in the order they were created.
+// match is called with the store locked, it must not use the store.
+func (s *Store) GetOrders(userId UserId, match func(Order) bool) []Order {
+	orders, _ := s.ScanOrders(userId, -1, false, -1, match)
+	return orders
+}
+
+// ScanOrders returns up to limit orders of a user selected by match, or every order if limit is negative. The orders
+// are looked at in the order they were created, or in reverse if desc, starting at the position start in the user's
+// orders, or at the first order if start is negative. It also returns the position of the next order to look at,
+// -1 once every order was looked at. match is called with the store locked, it must not use the store.
+func (s *Store) ScanOrders(userId UserId, start int, desc bool, limit int, match func(Order) bool) ([]Order, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userData := s.getUserData(userId)
+	i, step := start, 1
+	if desc {
+		step = -1
+	}
+	if i < 0 {
+		i = 0
+		if desc {
+			i = len(userData.OrderIds) - 1
+		}
+	}
+
+	orders := []Order{}
+	for ; i >= 0 && i < len(userData.OrderIds) && len(orders) != limit; i += step {
+		if order := userData.Orders[userData.OrderIds[i]]; match(order) {
+			orders = append(orders, order)
+		}
+	}
+	if i < 0 || i >= len(userData.OrderIds) {
+		return orders, -1
+	}
+	return orders, i
+}
+
+// GetUserAccount returns a copy of a user's status, cash, assets and working orders, without the other orders, fills
+// and transfers, e.g to check a new order without copying the user's history
+func (s *Store) GetUserAccount(userId UserId) UserData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userData := s.getUserData(userId)
+	if userData.UserId == "" {
+		return userData
+	}
+	account := UserData{
+		UserId: userData.UserId,
+		Status: userData.Status,
+		Cash:   userData.Cash,
+		Assets: make(map[AssetId]int, len(userData.Assets)),
+		Orders: make(map[OrderId]Order, s.openOrders[userId]),
+	}
+	for assetId, size := range userData.Assets {
+		account.Assets[assetId] = size
+	}
+	for orderId, order := range userData.Orders {
+		if order.Status == Working {
+			account.Orders[orderId] = order
+		}
+	}
+	return account
+}
+
+// AddUserOrder adds an order to a user's data and reserves the cash or assets it needs.
+// It returns an error, and doesn't add the order, if the user doesn't have them anymore,
+// e.g an order of another asset used them after the order was validated.
+func (s *Store) AddUserOrder(order Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addUserOrder(order, true)
+}
+
+// RestoreUserOrder adds an order to a user's data and reserves the cash or assets it needs without checking the user
+// has them, e.g to replay an order that was added when the user had them
+func (s *Store) RestoreUserOrder(order Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addUserOrder(order, false)
+}
+
+// addUserOrder adds an order and reserves what it needs, checking the user has it if check. The store must be locked.
+func (s *Store) addUserOrder(order Order, check bool) error {
+	userData := s.getUserData(order.UserId)
+	if cost := GetTotalAssetCost(order.Limit, order.Size) + order.FeeReserve; check && order.BuyOrSell == BUY && cost > userData.Cash {
+		return fmt.Errorf("order cost %d, with fees, is over the available cash of %d", cost, userData.Cash)
+	}
+	if check && order.BuyOrSell == SELL && order.Size > userData.Assets[order.AssetId] {
+		return fmt.Errorf("order size %d is over the %d available assets of %s", order.Size, userData.Assets[order.AssetId], order.AssetId)
+	}
+
+	if _, ok := userData.Orders[order.OrderId]; !ok {
+		userData.OrderIds = append(userData.OrderIds, order.OrderId)
+	}
+	s.setOrder(userData, order)
+	if order.BuyOrSell == BUY { // decrease user's available cash on every new buy order created, fees included
+		newCash := userData.Cash - GetTotalAssetCost(order.Limit, order.Size) - order.FeeReserve
+		userData.Cash = newCash
+	} else { // decrease user's asset size on every new sell order
+		userData.Assets[order.AssetId] -= order.Size
+	}
+
+	s.db[order.UserId] = userData
+	return nil
+}
+
+// AddRejectedOrder adds an order rejected by the matching engine to a user's data, nothing is reserved for it
+func (s *Store) AddRejectedOrder(order Order, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userData := s.getUserData(order.UserId)
+	if userData.UserId == "" {
+		return
+	}
+
+	order.Status = Rejected
+	order.Reason = reason
+	userData.OrderIds = append(userData.OrderIds, order.OrderId)
+	s.setOrder(userData, order)
+
+	s.db[order.UserId] = userData
+}
+
+// AddTrade records a trade as a fill of its buy order and of its sell order
+// and charges the buyer and the seller their fees
+func (s *Store) AddTrade(trade Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addFill(trade.BuyerId, trade.BuyOrderId, trade, trade.BuyFee)
+	s.addFill(trade.SellerId, trade.SellOrderId, trade, trade.SellFee)
+}
+
+func (s *Store) addFill(userId UserId, orderId OrderId, trade Trade, fee Usd) {
+	userData := s.getUserData(userId)
+	if userData.Fills == nil {
+		return
+	}
+	order, ok := userData.Orders[orderId]
+	if fee != 0 {
+		// the fee is paid from the fees reserved by the order first
+		fromReserve := fee
+		if fromReserve > order.FeeReserve {
+			fromReserve = order.FeeReserve
+		}
+		order.FeeReserve -= fromReserve
+		userData.Cash -= fee - fromReserve
+		s.db[userId] = userData
+	}
+	userData.Fills[orderId] = append(userData.Fills[orderId], Fill{Price: trade.Price, Size: trade.Size, ExecutedAt: trade.ExecutedAt, Fee: fee, Seq: trade.Seq})
+	if ok {
+		order.UpdateSeq = trade.Seq
+		s.setOrder(userData, order)
+	}
+}
+
+// UpdateUserAssetOnSuccessBuy updates a user's assets size and order status upon a success buy event at price.
+// The order reserved the cash of its fill at its limit, the difference with the price is given back to the user.
+func (s *Store) UpdateUserAssetOnSuccessBuy(userId UserId, assetId AssetId, orderId OrderId, price Usd, tradeAssetSize int, status OrderStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userData := s.getUserData(userId)
+	userData.Assets[assetId] += tradeAssetSize // increase asset size for newly bought asset
+
+	order := userData.Orders[orderId]
+	userData.Cash += GetTotalAssetCost(order.Limit-price, tradeAssetSize)
+	order.Status = status
+	order.Filled += tradeAssetSize
+	if status == Complete { // release the fees the order reserved and wasn't charged
+		userData.Cash += order.FeeReserve
+		order.FeeReserve = 0
+	}
+
+	s.setOrder(userData, order)
+
+	s.db[userId] = userData
+}
+
+// UpdateUserAssetOnSuccessSell updates a user's assets available cash and order status upon a success sale event
+func (s *Store) UpdateUserAssetOnSuccessSell(userId UserId, orderId OrderId, cashGain Usd, status OrderStatus, tradeAssetSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userData := s.getUserData(userId)
+	userData.Cash += cashGain // increase cash available after newly sold asset
+
+	order := userData.Orders[orderId]
+	order.Status = status
+	order.Filled += tradeAssetSize
+
+	s.setOrder(userData, order)
+
+	s.db[userId] = userData
+}
+
+// UpdateUserAsset updates a user's assets upon a buy or sell event
+func (s *Store) UpdateUserAsset(order Order, matchedPrice Usd, tradeAssetsSize int, orderType BuyOrSell, status OrderStatus) {
+	if (orderType == BUY && order.BuyOrSell == SELL) || (orderType == SELL && order.BuyOrSell == SELL) {
+		s.UpdateUserAssetOnSuccessSell(order.UserId, order.OrderId, GetTotalAssetCost(matchedPrice, tradeAssetsSize), status, tradeAssetsSize)
+	} else {
+		s.UpdateUserAssetOnSuccessBuy(order.UserId, order.AssetId, order.OrderId, matchedPrice, tradeAssetsSize, status)
+	}
+}
+
+// UpdateUserAssetOnOrderCancel updates a user's order status open a cancel order event with sequence number seq
+func (s *Store) UpdateUserAssetOnOrderCancel(userId UserId, assetId AssetId, orderId OrderId, reason string, seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userData := s.getUserData(userId)
+	order := userData.Orders[orderId]
+
+	// if order is a buy order, reallocate back cash deducted for the unfilled part of the buy order
+	if order.BuyOrSell == BUY {
+		userData.Cash += GetTotalAssetCost(order.Limit, order.Size-order.Filled) + order.FeeReserve
+		order.FeeReserve = 0
+		// else sell order, reallocate back unfilled asset size deducted from sell order
+	} else {
+		userData.Assets[assetId] += order.Size - order.Filled
+	}
+
+	order.Status = Canceled // mark order as canceled
+	order.Reason = reason
+	order.UpdateSeq = seq
+	s.setOrder(userData, order)
+
+	s.db[userId] = userData
+}
+
+// GetTotalAssetCost returns the cost of size assets at the limit price, in Usd cents
+func GetTotalAssetCost(limit Usd, size int) Usd {
+	return Usd(int(limit) * size)
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"time"
+)
+
+// Trade represents a match between a buy order and a sell order
+type Trade struct {
+	AssetId     AssetId
+	Price       Usd // price the assets traded at, in Usd cents
+	Size        int // number of assets traded
+	BuyOrderId  OrderId
+	SellOrderId OrderId
+	BuyerId     UserId
+	SellerId    UserId
+	ExecutedAt  time.Time
+	Seq         uint64 // exchange-wide sequence number of the trade
+	BuyFee      Usd    // fee charged to the buyer, in Usd cents
+	SellFee     Usd    // fee charged to the seller, in Usd cents
+
+	BuyRequestId  string // id of the request that placed the buy order, to trace the order
+	SellRequestId string // id of the request that placed the sell order, to trace the order
+	BuyIsTaker    bool   // whether the buy order matched as it arrived
+	SellIsTaker   bool   // whether the sell order matched as it arrived
+}
+
+// Fill represents a trade from the point of view of one of its orders
+type Fill struct {
+	Price      Usd // price the assets traded at, in Usd cents
+	Size       int // number of assets traded
+	ExecutedAt time.Time
+	Fee        Usd    // fee charged for the fill, in Usd cents
+	Seq        uint64 // sequence number of the trade
+}
+
+// NewTrade returns the trade of size assets matched at price between a buy and a sell order
+func NewTrade(buyOrder, sellOrder Order, price Usd, size int) Trade {
+	return Trade{
+		AssetId:     buyOrder.AssetId,
+		Price:       price,
+		Size:        size,
+		BuyOrderId:  buyOrder.OrderId,
+		SellOrderId: sellOrder.OrderId,
+		BuyerId:     buyOrder.UserId,
+		SellerId:    sellOrder.UserId,
+
+		BuyRequestId:  buyOrder.RequestId,
+		SellRequestId: sellOrder.RequestId,
+	}
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type TransferId string
+
+type TransferType string
+
+const (
+	Deposit    TransferType = "DEPOSIT"    // moves cash or assets into a user's account
+	Withdrawal TransferType = "WITHDRAWAL" // moves cash or assets out of a user's account
+)
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is reused for a different transfer
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different transfer")
+
+// Transfer represents a deposit or withdrawal of cash or an asset
+type Transfer struct {
+	TransferId     TransferId
+	UserId         UserId
+	TransferType   TransferType
+	AssetId        AssetId // asset transferred, empty for cash
+	Amount         int     // cash in Usd cents or number of assets
+	IdempotencyKey string  // key identifying retries of the same transfer
+	EventAt        time.Time
+	Seq            uint64 // sequence number of the command that created the transfer
+}
+
+// sameAs returns if two transfers move the same amount of the same cash or asset in the same direction
+func (t Transfer) sameAs(other Transfer) bool {
+	return t.TransferType == other.TransferType && t.AssetId == other.AssetId && t.Amount == other.Amount
+}
+
+// AddTransfer moves cash or assets in or out of a user's account and records the transfer.
+// Withdrawals can only take from the cash and assets available to trade, never from what open orders reserve.
+// If the idempotency key was already used for the same transfer, the original transfer is returned
+// unchanged along with false.
+func (s *Store) AddTransfer(transfer Transfer) (Transfer, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addTransfer(transfer, true)
+}
+
+// RestoreTransfer moves cash or assets in or out of a user's account and records the transfer without checking
+// the user has what's withdrawn, e.g to replay a transfer that was made when the user had it
+func (s *Store) RestoreTransfer(transfer Transfer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addTransfer(transfer, false)
+}
+
+// addTransfer makes a transfer, checking withdrawals if check. The store must be locked.
+func (s *Store) addTransfer(transfer Transfer, check bool) (Transfer, bool, error) {
+	userData := s.getUserData(transfer.UserId)
+	if userData.UserId == "" {
+		return Transfer{}, false, fmt.Errorf("userId: %s not an actual user", transfer.UserId)
+	}
+
+	for _, t := range userData.Transfers {
+		if t.IdempotencyKey == transfer.IdempotencyKey {
+			if !t.sameAs(transfer) {
+				return Transfer{}, false, ErrIdempotencyKeyReused
+			}
+			return t, false, nil
+		}
+	}
+
+	if check && transfer.TransferType == Withdrawal {
+		if transfer.AssetId == "" && Usd(transfer.Amount) > userData.Cash {
+			return Transfer{}, false, fmt.Errorf("user only has %d cash available to withdraw", userData.Cash)
+		}
+		if transfer.AssetId != "" && transfer.Amount > userData.Assets[transfer.AssetId] {
+			return Transfer{}, false, fmt.Errorf("user only has %d of asset %s available to withdraw", userData.Assets[transfer.AssetId], transfer.AssetId)
+		}
+	}
+
+	amount := transfer.Amount
+	if transfer.TransferType == Withdrawal {
+		amount = -amount
+	}
+	if transfer.AssetId == "" {
+		userData.Cash += Usd(amount)
+	} else {
+		userData.Assets[transfer.AssetId] += amount
+	}
+	userData.Transfers = append(userData.Transfers, transfer)
+
+	s.db[transfer.UserId] = userData
+	return transfer, true, nil
+}
+
+// GetTransfers returns a copy of a user's deposits and withdrawals, oldest first
+func (s *Store) GetTransfers(userId UserId) []Transfer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Transfer(nil), s.getUserData(userId).Transfers...)
+}
+
+// GetReserved returns the cash and assets a user's working orders reserve
+func (s *Store) GetReserved(userId UserId) (Usd, map[AssetId]int) {
+	cash := Usd(0)
+	assets := make(map[AssetId]int)
+	for _, order := range s.GetOrders(userId, func(order Order) bool { return order.Status == Working }) {
+		if order.BuyOrSell == BUY {
+			cash += GetTotalAssetCost(order.Limit, order.Size-order.Filled) + order.FeeReserve
+		} else {
+			assets[order.AssetId] += order.Size - order.Filled
+		}
+	}
+	return cash, assets
+}